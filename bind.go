@@ -0,0 +1,386 @@
+package lr
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultMultipartMemory 解析multipart表单时保存在内存中的最大字节数
+const defaultMultipartMemory = 32 << 20
+
+var (
+	ErrBindTarget = errors.New("lr: Bind的参数必须是指向结构体的非nil指针")
+
+	timeType         = reflect.TypeOf(time.Time{})
+	durationType     = reflect.TypeOf(time.Duration(0))
+	fileHeaderType   = reflect.TypeOf((*multipart.FileHeader)(nil))
+	textUnmarshaller = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// BindFieldError 单个字段绑定失败的信息
+type BindFieldError struct {
+	// 结构体中的字段名，嵌套的字段用.连接
+	Field string `json:"field"`
+	// 数据来源 path、query、form、header、default
+	Source string `json:"source"`
+	// 来源中的key
+	Key string `json:"key"`
+	// 失败原因
+	Err error `json:"-"`
+}
+
+func (e *BindFieldError) Error() string {
+	return fmt.Sprintf("lr: 字段%s绑定失败(%s:%s): %v", e.Field, e.Source, e.Key, e.Err)
+}
+
+func (e *BindFieldError) Unwrap() error {
+	return e.Err
+}
+
+// BindErrors 所有绑定失败的字段
+type BindErrors []*BindFieldError
+
+func (e BindErrors) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, fe := range e {
+		msgs = append(msgs, fe.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Bind 按照结构体标签把请求数据绑定到val
+// 先根据Content-Type解析body(json、xml)，然后按照path、query、form、header标签覆盖对应的字段，
+// 最后对没有取到值的字段使用default标签，time.Time的格式通过time_format标签指定，默认RFC3339
+//
+//	type Req struct {
+//		ID    int       `path:"id"`
+//		Page  int       `query:"page" default:"1"`
+//		Tags  []string  `query:"tag"`
+//		Token string    `header:"X-Token"`
+//		Since time.Time `query:"since" time_format:"2006-01-02"`
+//	}
+func (c *Context) Bind(val any) error {
+	rv := reflect.ValueOf(val)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return ErrBindTarget
+	}
+
+	if err := c.bindBody(val); err != nil {
+		return err
+	}
+
+	var errs BindErrors
+	for _, f := range cachedBindFields(rv.Elem().Type()) {
+		if err := c.bindField(rv.Elem(), f); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// bindBody 根据Content-Type解析请求体，表单数据在bindField中按照form标签处理
+func (c *Context) bindBody(val any) error {
+	if c.Req.Body == nil || c.Req.Body == http.NoBody || c.Req.ContentLength == 0 {
+		return nil
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(c.Req.Header.Get("Content-Type"))
+	switch {
+	case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+		return c.decodeJSON(val)
+	case mediaType == "application/xml" || mediaType == "text/xml" || strings.HasSuffix(mediaType, "+xml"):
+		return c.BindXML(val)
+	case mediaType == "multipart/form-data":
+		return c.Req.ParseMultipartForm(defaultMultipartMemory)
+	case mediaType == "application/x-www-form-urlencoded":
+		return c.Req.ParseForm()
+	}
+	return nil
+}
+
+func (c *Context) bindField(root reflect.Value, f bindField) *BindFieldError {
+	var (
+		source, key string
+		vals        []string
+	)
+	for _, src := range bindSources {
+		tag := f.tags[src]
+		if tag == "" {
+			continue
+		}
+		if found := c.bindValues(src, tag); len(found) > 0 {
+			source, key, vals = src, tag, found
+			break
+		}
+	}
+
+	// 文件字段单独处理
+	if files, ok := c.bindFiles(f); ok {
+		field := fieldByIndexAlloc(root, f.index)
+		if field.Type() == fileHeaderType {
+			field.Set(reflect.ValueOf(files[0]))
+		} else {
+			field.Set(reflect.ValueOf(files))
+		}
+		return nil
+	}
+
+	if len(vals) == 0 {
+		if f.defaultVal == "" || !fieldIsZero(root, f.index) {
+			return nil
+		}
+		source, key = "default", f.defaultVal
+		vals = []string{f.defaultVal}
+		if isMultiValue(f.typ) {
+			vals = strings.Split(f.defaultVal, ",")
+		}
+	}
+
+	if err := setFieldValues(fieldByIndexAlloc(root, f.index), vals, f.timeFormat); err != nil {
+		return &BindFieldError{Field: f.name, Source: source, Key: key, Err: err}
+	}
+	return nil
+}
+
+func (c *Context) bindValues(source, key string) []string {
+	switch source {
+	case "path":
+		if val, ok := c.pathParams[key]; ok {
+			return []string{val}
+		}
+	case "query":
+		if c.queryCache == nil {
+			c.queryCache = c.Req.URL.Query()
+		}
+		return c.queryCache[key]
+	case "form":
+		if c.Req.Form == nil {
+			_ = c.Req.ParseForm()
+		}
+		if vals := c.Req.Form[key]; len(vals) > 0 {
+			return vals
+		}
+		if c.Req.MultipartForm != nil {
+			return c.Req.MultipartForm.Value[key]
+		}
+	case "header":
+		return c.Req.Header.Values(key)
+	}
+	return nil
+}
+
+func (c *Context) bindFiles(f bindField) ([]*multipart.FileHeader, bool) {
+	key := f.tags["form"]
+	if key == "" || c.Req.MultipartForm == nil {
+		return nil, false
+	}
+	if f.typ != fileHeaderType && f.typ != reflect.SliceOf(fileHeaderType) {
+		return nil, false
+	}
+	files := c.Req.MultipartForm.File[key]
+	return files, len(files) > 0
+}
+
+// bindSources 同一个字段有多个标签时的取值顺序
+var bindSources = []string{"path", "query", "form", "header"}
+
+// bindField 结构体中需要绑定的字段的元数据
+type bindField struct {
+	// 字段名，嵌入结构体的字段用.连接
+	name string
+	// reflect.Value.FieldByIndex使用的下标
+	index []int
+	typ   reflect.Type
+	// key是数据来源，value是标签的值
+	tags       map[string]string
+	defaultVal string
+	timeFormat string
+}
+
+var bindFieldCache sync.Map
+
+// cachedBindFields 解析结构体的字段，结果按照类型缓存
+func cachedBindFields(typ reflect.Type) []bindField {
+	if fields, ok := bindFieldCache.Load(typ); ok {
+		return fields.([]bindField)
+	}
+	fields := parseBindFields(typ, nil, "")
+	bindFieldCache.Store(typ, fields)
+	return fields
+}
+
+func parseBindFields(typ reflect.Type, parent []int, prefix string) []bindField {
+	var fields []bindField
+	for i := 0; i < typ.NumField(); i++ {
+		sf := typ.Field(i)
+		index := append(append([]int{}, parent...), i)
+
+		tags := map[string]string{}
+		for _, src := range bindSources {
+			if tag, ok := sf.Tag.Lookup(src); ok && tag != "-" {
+				tags[src] = tag
+			}
+		}
+		defaultVal := sf.Tag.Get("default")
+
+		// 没有标签的嵌入结构体需要展开
+		if sf.Anonymous && len(tags) == 0 {
+			embedded := sf.Type
+			if embedded.Kind() == reflect.Pointer {
+				// 未导出的嵌入指针无法分配
+				if !sf.IsExported() {
+					continue
+				}
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct && embedded != timeType {
+				fields = append(fields, parseBindFields(embedded, index, prefix+sf.Name+".")...)
+				continue
+			}
+		}
+
+		if !sf.IsExported() || (len(tags) == 0 && defaultVal == "") {
+			continue
+		}
+
+		fields = append(fields, bindField{
+			name:       prefix + sf.Name,
+			index:      index,
+			typ:        sf.Type,
+			tags:       tags,
+			defaultVal: defaultVal,
+			timeFormat: sf.Tag.Get("time_format"),
+		})
+	}
+	return fields
+}
+
+// fieldByIndexAlloc 和FieldByIndex一样，但是遇到nil的嵌入指针会先分配
+func fieldByIndexAlloc(v reflect.Value, index []int) reflect.Value {
+	for i, idx := range index {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(idx)
+	}
+	return v
+}
+
+// fieldIsZero 判断字段是否为零值，路径上有nil的嵌入指针也认为是零值
+func fieldIsZero(v reflect.Value, index []int) bool {
+	for i, idx := range index {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				return true
+			}
+			v = v.Elem()
+		}
+		v = v.Field(idx)
+	}
+	return v.IsZero()
+}
+
+func isMultiValue(typ reflect.Type) bool {
+	return typ.Kind() == reflect.Slice && typ.Elem().Kind() != reflect.Uint8
+}
+
+// setFieldValues 把字符串转换成字段的类型，切片使用所有的值，其他类型使用第一个值
+func setFieldValues(field reflect.Value, vals []string, timeFormat string) error {
+	if field.Kind() == reflect.Pointer {
+		elem := reflect.New(field.Type().Elem())
+		if err := setFieldValues(elem.Elem(), vals, timeFormat); err != nil {
+			return err
+		}
+		field.Set(elem)
+		return nil
+	}
+
+	if isMultiValue(field.Type()) && !reflect.PointerTo(field.Type()).Implements(textUnmarshaller) {
+		slice := reflect.MakeSlice(field.Type(), len(vals), len(vals))
+		for i, val := range vals {
+			if err := setFieldValues(slice.Index(i), []string{val}, timeFormat); err != nil {
+				return err
+			}
+		}
+		field.Set(slice)
+		return nil
+	}
+
+	return setScalar(field, vals[0], timeFormat)
+}
+
+func setScalar(field reflect.Value, val string, timeFormat string) error {
+	switch field.Type() {
+	case timeType:
+		if timeFormat == "" {
+			timeFormat = time.RFC3339
+		}
+		t, err := time.Parse(timeFormat, val)
+		if err != nil {
+			return err
+		}
+		field.Set(reflect.ValueOf(t))
+		return nil
+	case durationType:
+		d, err := time.ParseDuration(val)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(d))
+		return nil
+	}
+
+	if field.CanAddr() && field.Addr().Type().Implements(textUnmarshaller) {
+		return field.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(val))
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(val)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(val)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(val, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(val, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(val, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetFloat(f)
+	case reflect.Slice:
+		// 只有[]byte会走到这里
+		field.SetBytes([]byte(val))
+	default:
+		return fmt.Errorf("不支持的类型%s", field.Type())
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package lr
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type bindPage struct {
+	Page int `query:"page" default:"1"`
+	Size int `query:"size" default:"20"`
+}
+
+type bindUserReq struct {
+	bindPage
+	ID      int64         `path:"id"`
+	Tags    []string      `query:"tag"`
+	Token   string        `header:"X-Token"`
+	Since   time.Time     `query:"since" time_format:"2006-01-02"`
+	Timeout *int          `query:"timeout"`
+	TTL     time.Duration `query:"ttl"`
+	Name    string        `json:"name"`
+	Age     int           `json:"age"`
+}
+
+func TestContext_Bind(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost,
+		"/user/1?tag=a&tag=b&size=5&since=2023-08-01&timeout=3&ttl=1m",
+		strings.NewReader(`{"name":"Tom","age":18}`))
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	req.Header.Set("X-Token", "secret")
+	ctx := &Context{Req: req, pathParams: map[string]string{"id": "42"}}
+
+	var val bindUserReq
+	require.NoError(t, ctx.Bind(&val))
+
+	timeout := 3
+	assert.Equal(t, bindUserReq{
+		bindPage: bindPage{Page: 1, Size: 5},
+		ID:       42,
+		Tags:     []string{"a", "b"},
+		Token:    "secret",
+		Since:    time.Date(2023, 8, 1, 0, 0, 0, 0, time.UTC),
+		Timeout:  &timeout,
+		TTL:      time.Minute,
+		Name:     "Tom",
+		Age:      18,
+	}, val)
+}
+
+func TestContext_BindForm(t *testing.T) {
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	require.NoError(t, writer.WriteField("name", "Tom"))
+	require.NoError(t, writer.WriteField("ids", "1"))
+	require.NoError(t, writer.WriteField("ids", "2"))
+	part, err := writer.CreateFormFile("avatar", "a.png")
+	require.NoError(t, err)
+	_, err = part.Write([]byte("png"))
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+
+	req := httptest.NewRequest(http.MethodPost, "/user", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	ctx := &Context{Req: req}
+
+	var val struct {
+		Name   string                `form:"name"`
+		IDs    []uint                `form:"ids"`
+		Avatar *multipart.FileHeader `form:"avatar"`
+	}
+	require.NoError(t, ctx.Bind(&val))
+	assert.Equal(t, "Tom", val.Name)
+	assert.Equal(t, []uint{1, 2}, val.IDs)
+	require.NotNil(t, val.Avatar)
+	assert.Equal(t, "a.png", val.Avatar.Filename)
+}
+
+func TestContext_BindErrors(t *testing.T) {
+	testCases := []struct {
+		name    string
+		val     any
+		wantErr error
+		fields  []string
+	}{
+		{
+			name:    "不是指针",
+			val:     bindPage{},
+			wantErr: ErrBindTarget,
+		},
+		{
+			name:    "nil指针",
+			val:     (*bindPage)(nil),
+			wantErr: ErrBindTarget,
+		},
+		{
+			name: "字段转换失败",
+			val: &struct {
+				Page  int       `query:"page"`
+				Since time.Time `query:"since"`
+				OK    bool      `query:"ok"`
+			}{},
+			fields: []string{"Page", "Since"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/?page=abc&since=yesterday&ok=true", nil)
+			ctx := &Context{Req: req}
+			err := ctx.Bind(tc.val)
+			if tc.wantErr != nil {
+				assert.Equal(t, tc.wantErr, err)
+				return
+			}
+			var errs BindErrors
+			require.ErrorAs(t, err, &errs)
+			fields := make([]string, 0, len(errs))
+			for _, fe := range errs {
+				assert.Equal(t, "query", fe.Source)
+				fields = append(fields, fe.Field)
+			}
+			assert.Equal(t, tc.fields, fields)
+		})
+	}
+}
@@ -0,0 +1,194 @@
+package lr
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SetETag 设置响应的ETag，没有引号时自动加上，例如v1会被设置为"v1"，弱校验使用W/"v1"
+func (c *Context) SetETag(etag string) {
+	if !strings.HasPrefix(etag, `"`) && !strings.HasPrefix(etag, `W/"`) {
+		etag = `"` + etag + `"`
+	}
+	c.Resp.Header().Set("ETag", etag)
+}
+
+// SetLastModified 设置资源的最后修改时间，精度为秒，零值会被忽略
+func (c *Context) SetLastModified(t time.Time) {
+	if t.IsZero() || t.Equal(time.Unix(0, 0)) {
+		return
+	}
+	c.Resp.Header().Set("Last-Modified", t.UTC().Format(http.TimeFormat))
+}
+
+// CheckPreconditions 按照RFC 9110 13.2.2的顺序，使用响应中的ETag和Last-Modified校验条件请求头
+// 不满足时设置304或者412并清空响应数据，返回true表示handler应该直接返回
+//
+//	h.PUT("/article/:id", func(ctx *lr.Context) {
+//		article := svc.Get(id)
+//		ctx.SetETag(article.Version)
+//		if ctx.CheckPreconditions() {
+//			return
+//		}
+//		// 更新article
+//	})
+func (c *Context) CheckPreconditions() bool {
+	status := c.evaluatePreconditions()
+	if status == 0 {
+		return false
+	}
+	c.Status = status
+	c.RespData = nil
+	return true
+}
+
+func (c *Context) evaluatePreconditions() int {
+	var (
+		reqHeader    = c.Req.Header
+		etag         = c.Resp.Header().Get("ETag")
+		lastModified time.Time
+		safe         = c.Req.Method == http.MethodGet || c.Req.Method == http.MethodHead
+	)
+	if lm := c.Resp.Header().Get("Last-Modified"); lm != "" {
+		lastModified, _ = http.ParseTime(lm)
+	}
+
+	if im := reqHeader.Get("If-Match"); im != "" {
+		if !matchETag(im, etag, true) {
+			return http.StatusPreconditionFailed
+		}
+	} else if ius := reqHeader.Get("If-Unmodified-Since"); ius != "" && !lastModified.IsZero() {
+		if t, err := http.ParseTime(ius); err == nil && lastModified.After(t) {
+			return http.StatusPreconditionFailed
+		}
+	}
+
+	if inm := reqHeader.Get("If-None-Match"); inm != "" {
+		if matchETag(inm, etag, false) {
+			if safe {
+				return http.StatusNotModified
+			}
+			return http.StatusPreconditionFailed
+		}
+	} else if ims := reqHeader.Get("If-Modified-Since"); ims != "" && safe && !lastModified.IsZero() {
+		if t, err := http.ParseTime(ims); err == nil && !lastModified.After(t) {
+			return http.StatusNotModified
+		}
+	}
+	return 0
+}
+
+// matchETag header是逗号分隔的ETag列表或者*，strong为true时弱ETag不会匹配
+// 调用者已经确认资源存在，*总是匹配，即使没有设置ETag
+func matchETag(header, etag string, strong bool) bool {
+	if strings.TrimSpace(header) == "*" {
+		return true
+	}
+	if etag == "" {
+		return false
+	}
+
+	weak := strings.HasPrefix(etag, "W/")
+	if strong && weak {
+		return false
+	}
+	opaque := strings.TrimPrefix(etag, "W/")
+	for _, candidate := range splitQuoted(header, ',') {
+		candidate = strings.TrimSpace(candidate)
+		if strong && strings.HasPrefix(candidate, "W/") {
+			continue
+		}
+		if strings.TrimPrefix(candidate, "W/") == opaque {
+			return true
+		}
+	}
+	return false
+}
+
+// applyRange 按照Range请求头截取RespData，只支持单个范围，多个范围或者格式错误时返回完整内容
+// If-Range和响应中的ETag或者Last-Modified不一致时同样返回完整内容
+func (c *Context) applyRange() {
+	rng := c.Req.Header.Get("Range")
+	if rng == "" || c.Status != http.StatusOK || c.Req.Method != http.MethodGet {
+		return
+	}
+	if ir := c.Req.Header.Get("If-Range"); ir != "" && !c.ifRangeMatch(ir) {
+		return
+	}
+
+	size := int64(len(c.RespData))
+	start, end, ok := parseRange(rng, size)
+	if !ok {
+		return
+	}
+	if start < 0 {
+		c.Resp.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", size))
+		c.Resp.Header().Del("Content-Type")
+		c.Status = http.StatusRequestedRangeNotSatisfiable
+		c.RespData = nil
+		return
+	}
+	c.Resp.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, size))
+	c.Status = http.StatusPartialContent
+	c.RespData = c.RespData[start : end+1]
+}
+
+// ifRangeMatch If-Range是ETag时使用强比较，是时间时必须和Last-Modified完全一致
+func (c *Context) ifRangeMatch(ir string) bool {
+	if strings.HasPrefix(ir, `"`) || strings.HasPrefix(ir, "W/") {
+		return !strings.HasPrefix(ir, "W/") && matchETag(ir, c.Resp.Header().Get("ETag"), true)
+	}
+	lm := c.Resp.Header().Get("Last-Modified")
+	return lm != "" && lm == ir
+}
+
+// parseRange 解析bytes=a-b、bytes=a-、bytes=-n，ok为false时忽略Range；
+// 范围无法满足时start为-1
+func parseRange(header string, size int64) (start, end int64, ok bool) {
+	if !strings.HasPrefix(header, "bytes=") {
+		return 0, 0, false
+	}
+	spec := strings.TrimPrefix(header, "bytes=")
+	if strings.Contains(spec, ",") {
+		return 0, 0, false
+	}
+	first, last, found := strings.Cut(strings.TrimSpace(spec), "-")
+	if !found {
+		return 0, 0, false
+	}
+	if first == "" {
+		n, err := strconv.ParseInt(last, 10, 64)
+		if err != nil || n < 0 {
+			return 0, 0, false
+		}
+		if n == 0 || size == 0 {
+			return -1, 0, true
+		}
+		if n > size {
+			n = size
+		}
+		return size - n, size - 1, true
+	}
+
+	start, err := strconv.ParseInt(first, 10, 64)
+	if err != nil || start < 0 {
+		return 0, 0, false
+	}
+	end = size - 1
+	if last != "" {
+		end, err = strconv.ParseInt(last, 10, 64)
+		if err != nil || end < start {
+			return 0, 0, false
+		}
+		if end > size-1 {
+			end = size - 1
+		}
+	}
+	if start >= size {
+		return -1, 0, true
+	}
+	return start, end, true
+}
@@ -0,0 +1,144 @@
+package lr
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContext_CheckPreconditions(t *testing.T) {
+	modified := time.Date(2023, 5, 1, 10, 0, 0, 0, time.UTC)
+	testCases := []struct {
+		name       string
+		method     string
+		etag       string
+		headers    map[string]string
+		wantStatus int
+	}{
+		{
+			name:       "没有条件",
+			method:     http.MethodGet,
+			etag:       `"v1"`,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "没有ETag时If-Match: *依然匹配",
+			method:     http.MethodPut,
+			headers:    map[string]string{"If-Match": "*"},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "没有ETag时If-None-Match: *",
+			method:     http.MethodPut,
+			headers:    map[string]string{"If-None-Match": "*"},
+			wantStatus: http.StatusPreconditionFailed,
+		},
+		{
+			name:       "If-None-Match命中",
+			method:     http.MethodGet,
+			etag:       `"v1"`,
+			headers:    map[string]string{"If-None-Match": `"v0", W/"v1"`},
+			wantStatus: http.StatusNotModified,
+		},
+		{
+			name:       "If-None-Match未命中",
+			method:     http.MethodGet,
+			etag:       `"v2"`,
+			headers:    map[string]string{"If-None-Match": `"v1"`},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "If-None-Match优先于If-Modified-Since",
+			method:     http.MethodGet,
+			etag:       `"v2"`,
+			headers:    map[string]string{"If-None-Match": `"v1"`, "If-Modified-Since": modified.Format(http.TimeFormat)},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "If-Modified-Since未修改",
+			method:     http.MethodGet,
+			headers:    map[string]string{"If-Modified-Since": modified.Add(time.Hour).Format(http.TimeFormat)},
+			wantStatus: http.StatusNotModified,
+		},
+		{
+			name:       "If-Modified-Since已修改",
+			method:     http.MethodGet,
+			headers:    map[string]string{"If-Modified-Since": modified.Add(-time.Hour).Format(http.TimeFormat)},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "If-Match命中",
+			method:     http.MethodPut,
+			etag:       `"v1"`,
+			headers:    map[string]string{"If-Match": `"v1"`},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "If-Match弱ETag不匹配",
+			method:     http.MethodPut,
+			etag:       `W/"v1"`,
+			headers:    map[string]string{"If-Match": `W/"v1"`},
+			wantStatus: http.StatusPreconditionFailed,
+		},
+		{
+			name:       "If-Unmodified-Since已修改",
+			method:     http.MethodDelete,
+			headers:    map[string]string{"If-Unmodified-Since": modified.Add(-time.Second).Format(http.TimeFormat)},
+			wantStatus: http.StatusPreconditionFailed,
+		},
+		{
+			name:       "If-None-Match星号用于创建",
+			method:     http.MethodPut,
+			etag:       `"v1"`,
+			headers:    map[string]string{"If-None-Match": "*"},
+			wantStatus: http.StatusPreconditionFailed,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			h := NewHTTPServer("tcp", ":8081")
+			executed := false
+			h.addRouter(tc.method, "/article", func(ctx *Context) {
+				if tc.etag != "" {
+					ctx.SetETag(tc.etag)
+				}
+				ctx.SetLastModified(modified)
+				if ctx.CheckPreconditions() {
+					return
+				}
+				executed = true
+				_ = ctx.RespString(http.StatusOK, "article")
+			})
+
+			req := httptest.NewRequest(tc.method, "/article", nil)
+			for key, val := range tc.headers {
+				req.Header.Set(key, val)
+			}
+			recorder := httptest.NewRecorder()
+			h.ServeHTTP(recorder, req)
+
+			assert.Equal(t, tc.wantStatus, recorder.Code)
+			assert.Equal(t, tc.wantStatus == http.StatusOK, executed)
+			assert.Equal(t, modified.Format(http.TimeFormat), recorder.Header().Get("Last-Modified"))
+			if tc.wantStatus != http.StatusOK {
+				assert.Empty(t, recorder.Body.String())
+			}
+		})
+	}
+}
+
+func TestContext_SetETag(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	ctx := &Context{Resp: recorder}
+	ctx.SetETag("v1")
+	assert.Equal(t, `"v1"`, recorder.Header().Get("ETag"))
+	ctx.SetETag(`W/"v2"`)
+	assert.Equal(t, `W/"v2"`, recorder.Header().Get("ETag"))
+
+	ctx.SetLastModified(time.Time{})
+	assert.Empty(t, recorder.Header().Get("Last-Modified"))
+}
@@ -1,12 +1,15 @@
 package lr
 
 import (
-	"encoding/json"
+	"context"
 	"encoding/xml"
 	"errors"
+	"net"
 	"net/http"
 	"net/url"
 	"strconv"
+	"sync"
+	"time"
 )
 
 type Context struct {
@@ -26,6 +29,42 @@ type Context struct {
 	RespData []byte
 	// 模版渲染引擎
 	TplEngine TemplateEngine
+	// 按照扩展名选择的模版引擎
+	tplEngines map[string]TemplateEngine
+	// 流式渲染的缓冲区大小，为0时不使用流式渲染
+	streamBufSize int
+	// 每次渲染结束之后的回调
+	renderObserver func(ctx *Context, m RenderMetrics)
+	// 内容协商可以使用的响应格式
+	renderers []Renderer
+	// json编解码配置
+	jsonCfg *JSONConfig
+	// 保护keys，handler中启动的goroutine也可能读写
+	mu sync.RWMutex
+	// 中间件和handler之间传递的数据
+	keys map[string]any
+	// 是否终止了后续的中间件和handler
+	aborted bool
+	// handler返回的错误
+	err error
+	// 错误处理
+	errHandler ErrorHandler
+	// 响应是否已经直接写回客户端(例如SSE、WebSocket)，为true时不再由框架统一刷新响应
+	committed bool
+	// 可信的代理，用于解析客户端的真实地址
+	trustedProxies []*net.IPNet
+	// 命名路由，用于生成地址
+	routeNames map[string]string
+	// 本次请求通过AddFlash保存的消息
+	outFlashes []string
+	// 上一个请求保存的消息，读取之后缓存
+	inFlashes *[]string
+	// flash消息cookie的签名，为nil时使用defaultFlashCodec
+	flashCookieCodec CookieCodec
+	// 国际化的消息目录
+	i18n *I18nBundle
+	// 当前请求的语言，第一次使用时检测
+	locale string
 }
 
 func (c *Context) RespJsonOK(val any) error {
@@ -37,18 +76,54 @@ func (c *Context) respJson(val any, status int) error {
 		return errors.New("返回值为nil")
 	}
 
-	bytes, err := json.Marshal(&val)
+	bytes, err := c.marshalJSON(val)
 	if err != nil {
 		return err
 	}
 
-	c.RespData = bytes
+	c.setResp(status, "application/json", bytes)
+	return nil
+}
+
+// Set 保存请求范围内的数据，例如鉴权中间件把用户信息交给handler
+// 数据保存在Context上，handler返回之后中间件(例如accesslog)依然可以读取
+func (c *Context) Set(key string, val any) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.keys == nil {
+		c.keys = make(map[string]any, 4)
+	}
+	c.keys[key] = val
+}
 
-	c.Resp.Header().Set("Content-Type", "application/json")
-	c.Resp.Header().Set("Content-Length", strconv.Itoa(len(bytes)))
-	c.Status = status
+// Get 获取Set保存的数据
+func (c *Context) Get(key string) (any, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	val, ok := c.keys[key]
+	return val, ok
+}
 
-	return nil
+// Keys 所有Set保存的数据的副本
+func (c *Context) Keys() map[string]any {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	res := make(map[string]any, len(c.keys))
+	for key, val := range c.keys {
+		res[key] = val
+	}
+	return res
+}
+
+// GetAs 获取Set保存的数据并转换为T，key不存在或者类型不匹配时返回false
+func GetAs[T any](c *Context, key string) (T, bool) {
+	val, ok := c.Get(key)
+	if !ok {
+		var zero T
+		return zero, false
+	}
+	res, ok := val.(T)
+	return res, ok
 }
 
 // BindJson 绑定json
@@ -61,8 +136,7 @@ func (c *Context) BindJson(val any) error {
 		return errors.New("body不能为nil")
 	}
 
-	decode := json.NewDecoder(c.Req.Body)
-	return decode.Decode(&val)
+	return c.decodeJSON(val)
 }
 
 func (c *Context) BindXML(val any) error {
@@ -70,8 +144,12 @@ func (c *Context) BindXML(val any) error {
 		return errors.New("输入不能为nil")
 	}
 
-	decode := xml.NewDecoder(c.Req.Body)
-	return decode.Decode(&val)
+	if c.Req.Body == nil {
+		return errors.New("body不能为nil")
+	}
+
+	decode := xml.NewDecoder(c.limitBody(c.jsonConfig().MaxRequestBytes))
+	return wrapBodyError(decode.Decode(val))
 }
 
 // FormValue 获取表单中指定key的内容，多次调用ParseForm()，只会解析一次，不会每次都解析
@@ -88,6 +166,19 @@ func (c *Context) FormValue(key string) StringValue {
 	}
 }
 
+// FormValues 获取表单中指定key的所有值，包括multipart表单
+func (c *Context) FormValues(key string) ([]string, error) {
+	if err := c.Req.ParseMultipartForm(defaultMultipartMemory); err != nil && !errors.Is(err, http.ErrNotMultipart) {
+		return nil, err
+	}
+
+	vals, ok := c.Req.Form[key]
+	if !ok {
+		return nil, ErrKeyNotFound
+	}
+	return vals, nil
+}
+
 // MatchedPath 获取请求最终匹配到的路径
 func (c *Context) MatchedPath() string {
 	return c.matchedPath
@@ -95,28 +186,37 @@ func (c *Context) MatchedPath() string {
 
 // QueryValue 根据key获取Query中的值
 func (c *Context) QueryValue(key string) StringValue {
-	if c.queryCache == nil {
-		c.queryCache = c.Req.URL.Query()
-	}
-
-	val, ok := c.queryCache[key]
-	if !ok || len(c.queryCache) == 0 {
+	vals, err := c.QueryValues(key)
+	if err != nil {
 		return StringValue{
-			err: errors.New("key不存在"),
+			err: err,
 		}
 	}
 
 	return StringValue{
-		val: val[0],
+		val: vals[0],
 		err: nil,
 	}
 }
 
+// QueryValues 根据key获取Query中的所有值，例如?tag=a&tag=b
+func (c *Context) QueryValues(key string) ([]string, error) {
+	if c.queryCache == nil {
+		c.queryCache = c.Req.URL.Query()
+	}
+
+	vals, ok := c.queryCache[key]
+	if !ok || len(vals) == 0 {
+		return nil, ErrKeyNotFound
+	}
+	return vals, nil
+}
+
 func (c *Context) PathValue(key string) StringValue {
 	val, ok := c.pathParams[key]
 	if !ok || len(c.pathParams) == 0 {
 		return StringValue{
-			err: errors.New("key不存在"),
+			err: ErrKeyNotFound,
 		}
 	}
 
@@ -126,16 +226,78 @@ func (c *Context) PathValue(key string) StringValue {
 	}
 }
 
+// HeaderValue 获取请求头中的值，有多个值时返回第一个
+func (c *Context) HeaderValue(key string) StringValue {
+	if len(c.Req.Header.Values(key)) == 0 {
+		return StringValue{
+			err: ErrKeyNotFound,
+		}
+	}
+
+	return StringValue{
+		val: c.Req.Header.Get(key),
+	}
+}
+
+// CookieValue 获取cookie的值
+func (c *Context) CookieValue(name string) StringValue {
+	cookie, err := c.Req.Cookie(name)
+	if err != nil {
+		return StringValue{
+			err: ErrKeyNotFound,
+		}
+	}
+
+	return StringValue{
+		val: cookie.Value,
+	}
+}
+
+var (
+	ErrKeyNotFound   = errors.New("key不存在")
+	ErrValueRequired = errors.New("值不能为空")
+)
+
+// StringValue 请求中取到的字符串，通过各个方法转换为需要的类型
+// Default和Required可以链式调用
+//
+//	page, err := ctx.QueryValue("page").Default("1").Int()
+//	id, err := ctx.PathValue("id").Required().Int64()
 type StringValue struct {
 	val string
 	err error
 }
 
+// Default key不存在或者值为空时使用默认值，其他错误会保留
+func (s StringValue) Default(val string) StringValue {
+	if errors.Is(s.err, ErrKeyNotFound) || (s.err == nil && s.val == "") {
+		return StringValue{val: val}
+	}
+	return s
+}
+
+// Required 值为空时返回ErrValueRequired
+func (s StringValue) Required() StringValue {
+	if s.err == nil && s.val == "" {
+		return StringValue{err: ErrValueRequired}
+	}
+	return s
+}
+
 // String 获取字符串类型的返回值
 func (s StringValue) String() (string, error) {
 	return s.val, s.err
 }
 
+// Bool 获取bool类型的返回值，支持1、t、true、0、f、false等
+func (s StringValue) Bool() (bool, error) {
+	if s.err != nil {
+		return false, s.err
+	}
+
+	return strconv.ParseBool(s.val)
+}
+
 // Int 获取int类型的返回值
 func (s StringValue) Int() (int, error) {
 	if s.err != nil {
@@ -160,7 +322,7 @@ func (s StringValue) Int32() (int32, error) {
 		return 0, s.err
 	}
 
-	val, err := strconv.ParseUint(s.val, 10, 32)
+	val, err := strconv.ParseInt(s.val, 10, 32)
 	if err != nil {
 		return 0, err
 	}
@@ -168,6 +330,48 @@ func (s StringValue) Int32() (int32, error) {
 	return int32(val), nil
 }
 
+// Int16 获取int16类型的返回值
+func (s StringValue) Int16() (int16, error) {
+	if s.err != nil {
+		return 0, s.err
+	}
+
+	val, err := strconv.ParseInt(s.val, 10, 16)
+	if err != nil {
+		return 0, err
+	}
+
+	return int16(val), nil
+}
+
+// Int8 获取int8类型的返回值
+func (s StringValue) Int8() (int8, error) {
+	if s.err != nil {
+		return 0, s.err
+	}
+
+	val, err := strconv.ParseInt(s.val, 10, 8)
+	if err != nil {
+		return 0, err
+	}
+
+	return int8(val), nil
+}
+
+// Uint 获取uint类型的返回值
+func (s StringValue) Uint() (uint, error) {
+	if s.err != nil {
+		return 0, s.err
+	}
+
+	val, err := strconv.ParseUint(s.val, 10, 0)
+	if err != nil {
+		return 0, err
+	}
+
+	return uint(val), nil
+}
+
 // Uint64 获取uint64类型的返回值
 func (s StringValue) Uint64() (uint64, error) {
 	if s.err != nil {
@@ -210,7 +414,25 @@ func (s StringValue) Float64() (float64, error) {
 		return 0, s.err
 	}
 
-	return strconv.ParseFloat(s.val, 32)
+	return strconv.ParseFloat(s.val, 64)
+}
+
+// Duration 获取time.Duration类型的返回值，格式和time.ParseDuration一致，例如1h30m
+func (s StringValue) Duration() (time.Duration, error) {
+	if s.err != nil {
+		return 0, s.err
+	}
+
+	return time.ParseDuration(s.val)
+}
+
+// Time 按照layout解析时间，例如time.RFC3339、"2006-01-02"
+func (s StringValue) Time(layout string) (time.Time, error) {
+	if s.err != nil {
+		return time.Time{}, s.err
+	}
+
+	return time.Parse(layout, s.val)
 }
 
 // Render 模版渲染的方法
@@ -218,13 +440,67 @@ func (s StringValue) Float64() (float64, error) {
 // @param data需要被模版渲染的数据
 // @return []byte 模版渲染后的数据
 func (c *Context) Render(tplName string, data any) error {
-	var err error
-	c.RespData, err = c.TplEngine.Render(c.Req.Context(), tplName, data)
+	return c.renderWithMetrics(tplName, "", data)
+}
+
+func (c *Context) renderWithMetrics(tplName, cacheKey string, data any) error {
+	start := time.Now()
+	m := RenderMetrics{Template: tplName}
+	err := c.render(tplName, cacheKey, data, &m)
+	if c.renderObserver != nil {
+		m.Duration = time.Since(start)
+		m.Err = err
+		c.renderObserver(c, m)
+	}
+	return err
+}
+
+func (c *Context) render(tplName, cacheKey string, data any, m *RenderMetrics) error {
+	engine, err := c.templateEngine(tplName)
 	if err != nil {
 		c.Status = http.StatusInternalServerError
 		return err
 	}
-	c.Status = http.StatusOK
+	// 把Set保存的数据交给模版引擎，引擎通过ContextValues读取
+	tplCtx := context.WithValue(c.Req.Context(), contextValuesKey{}, c.Keys())
+	if cacheKey != "" {
+		tplCtx = WithRenderCacheKey(tplCtx, cacheKey)
+	}
+
+	// 直接使用RenderCache后面的引擎，这样ContextAwareEngine和流式渲染依然有效
+	if cache, ok := engine.(*RenderCache); ok {
+		engine = cache.engine
+		if cacheKey != "" {
+			c.RespData, m.CacheHit, err = cache.do(tplCtx, tplName, cacheKey, func() ([]byte, error) {
+				return c.renderBytes(tplCtx, engine, tplName, data)
+			})
+			return c.renderDone(err, m)
+		}
+	}
+
+	if c.streamBufSize > 0 {
+		if ok, err := c.renderStream(tplCtx, engine, tplName, data, m); ok {
+			return err
+		}
+	}
+	c.RespData, err = c.renderBytes(tplCtx, engine, tplName, data)
+	return c.renderDone(err, m)
+}
 
+func (c *Context) renderBytes(ctx context.Context, engine TemplateEngine, tplName string, data any) ([]byte, error) {
+	if aware, ok := engine.(ContextAwareEngine); ok {
+		return aware.RenderView(ctx, tplName, c.ViewData(data), c.TemplateFuncs())
+	}
+	return engine.Render(ctx, tplName, data)
+}
+
+func (c *Context) renderDone(err error, m *RenderMetrics) error {
+	if err != nil {
+		c.RespData = nil
+		c.Status = http.StatusInternalServerError
+		return err
+	}
+	c.Status = http.StatusOK
+	m.Bytes = len(c.RespData)
 	return nil
 }
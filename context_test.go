@@ -0,0 +1,199 @@
+package lr
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// valuesTemplateEngine 把ContextValues中的数据渲染出来
+type valuesTemplateEngine struct{}
+
+func (valuesTemplateEngine) Render(ctx context.Context, tplName string, data any) ([]byte, error) {
+	user, _ := ContextValues(ctx)["user"].(string)
+	return []byte(tplName + ":" + user), nil
+}
+
+func TestContext_SetGet(t *testing.T) {
+	var afterHandler map[string]any
+	h := NewHTTPServer("tcp", ":8081", Use(func(next HandleFunc) HandleFunc {
+		return func(ctx *Context) {
+			ctx.Set("user", "Tom")
+			ctx.Set("uid", 42)
+			next(ctx)
+			afterHandler = ctx.Keys()
+		}
+	}), Template(valuesTemplateEngine{}))
+
+	h.GET("/profile", func(ctx *Context) {
+		uid, ok := GetAs[int](ctx, "uid")
+		assert.True(t, ok)
+		assert.Equal(t, 42, uid)
+
+		_, ok = GetAs[string](ctx, "uid")
+		assert.False(t, ok)
+		_, ok = ctx.Get("missing")
+		assert.False(t, ok)
+
+		// handler中启动的goroutine并发写
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				ctx.Set("visited", true)
+			}()
+		}
+		wg.Wait()
+
+		require.NoError(t, ctx.Render("profile", nil))
+	})
+
+	recorder := httptest.NewRecorder()
+	h.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/profile", nil))
+
+	assert.Equal(t, "profile:Tom", recorder.Body.String())
+	assert.Equal(t, map[string]any{"user": "Tom", "uid": 42, "visited": true}, afterHandler)
+}
+
+func TestStringValue(t *testing.T) {
+	testCases := []struct {
+		name    string
+		val     StringValue
+		convert func(s StringValue) (any, error)
+		want    any
+		wantErr error
+	}{
+		{
+			name:    "Bool",
+			val:     StringValue{val: "true"},
+			convert: func(s StringValue) (any, error) { return s.Bool() },
+			want:    true,
+		},
+		{
+			name:    "Int8溢出",
+			val:     StringValue{val: "128"},
+			convert: func(s StringValue) (any, error) { return s.Int8() },
+			wantErr: strconv.ErrRange,
+		},
+		{
+			name:    "Int16",
+			val:     StringValue{val: "-300"},
+			convert: func(s StringValue) (any, error) { return s.Int16() },
+			want:    int16(-300),
+		},
+		{
+			name:    "Int32负数",
+			val:     StringValue{val: "-12"},
+			convert: func(s StringValue) (any, error) { return s.Int32() },
+			want:    int32(-12),
+		},
+		{
+			name:    "Uint",
+			val:     StringValue{val: "12"},
+			convert: func(s StringValue) (any, error) { return s.Uint() },
+			want:    uint(12),
+		},
+		{
+			name:    "Float64精度",
+			val:     StringValue{val: "0.1"},
+			convert: func(s StringValue) (any, error) { return s.Float64() },
+			want:    0.1,
+		},
+		{
+			name:    "Duration",
+			val:     StringValue{val: "1h30m"},
+			convert: func(s StringValue) (any, error) { return s.Duration() },
+			want:    90 * time.Minute,
+		},
+		{
+			name:    "Time",
+			val:     StringValue{val: "2023-01-02"},
+			convert: func(s StringValue) (any, error) { return s.Time("2006-01-02") },
+			want:    time.Date(2023, 1, 2, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:    "Default key不存在",
+			val:     StringValue{err: ErrKeyNotFound},
+			convert: func(s StringValue) (any, error) { return s.Default("10").Int() },
+			want:    10,
+		},
+		{
+			name:    "Default 空值",
+			val:     StringValue{},
+			convert: func(s StringValue) (any, error) { return s.Default("abc").String() },
+			want:    "abc",
+		},
+		{
+			name:    "Default 有值",
+			val:     StringValue{val: "3"},
+			convert: func(s StringValue) (any, error) { return s.Default("10").Int() },
+			want:    3,
+		},
+		{
+			name:    "Required",
+			val:     StringValue{},
+			convert: func(s StringValue) (any, error) { return s.Required().String() },
+			wantErr: ErrValueRequired,
+		},
+		{
+			name:    "Default之后Required",
+			val:     StringValue{err: ErrKeyNotFound},
+			convert: func(s StringValue) (any, error) { return s.Default("1").Required().Uint64() },
+			want:    uint64(1),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := tc.convert(tc.val)
+			if tc.wantErr != nil {
+				assert.ErrorIs(t, err, tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestContext_Values(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/user?tag=a&tag=b&page=", strings.NewReader("role=admin&role=dev"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("X-Token", "abc")
+	req.AddCookie(&http.Cookie{Name: "sid", Value: "123"})
+	ctx := &Context{Req: req, Resp: httptest.NewRecorder()}
+
+	tags, err := ctx.QueryValues("tag")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, tags)
+	_, err = ctx.QueryValues("none")
+	assert.ErrorIs(t, err, ErrKeyNotFound)
+	page, err := ctx.QueryValue("page").Default("1").Int()
+	require.NoError(t, err)
+	assert.Equal(t, 1, page)
+
+	roles, err := ctx.FormValues("role")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"admin", "dev"}, roles)
+
+	token, err := ctx.HeaderValue("X-Token").String()
+	require.NoError(t, err)
+	assert.Equal(t, "abc", token)
+	_, err = ctx.HeaderValue("X-None").String()
+	assert.ErrorIs(t, err, ErrKeyNotFound)
+
+	sid, err := ctx.CookieValue("sid").Int()
+	require.NoError(t, err)
+	assert.Equal(t, 123, sid)
+	_, err = ctx.CookieValue("none").String()
+	assert.ErrorIs(t, err, ErrKeyNotFound)
+}
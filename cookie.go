@@ -0,0 +1,154 @@
+package lr
+
+import (
+	"errors"
+	"net/http"
+	"time"
+)
+
+// maxCookieBytes 浏览器对单个cookie的限制，包括名字和属性
+const maxCookieBytes = 4096
+
+var (
+	ErrInvalidCookie  = errors.New("lr: cookie的名字或者值不合法")
+	ErrInsecureCookie = errors.New("lr: SameSite=None和Partitioned的cookie必须设置Secure")
+	ErrCookieTooLarge = errors.New("lr: cookie超过4096字节")
+)
+
+// cookieSpec 标准库的http.Cookie在go1.19中不支持Partitioned
+type cookieSpec struct {
+	http.Cookie
+	partitioned bool
+}
+
+// CookieOption 修改cookie的属性
+type CookieOption func(c *cookieSpec)
+
+// CookiePath 默认为/
+func CookiePath(path string) CookieOption {
+	return func(c *cookieSpec) {
+		c.Path = path
+	}
+}
+
+// CookieDomain 默认只在当前域名下有效
+func CookieDomain(domain string) CookieOption {
+	return func(c *cookieSpec) {
+		c.Domain = domain
+	}
+}
+
+// CookieMaxAge 有效期，默认为会话cookie，小于等于0表示删除
+func CookieMaxAge(maxAge time.Duration) CookieOption {
+	return func(c *cookieSpec) {
+		if maxAge <= 0 {
+			c.MaxAge = -1
+			c.Expires = time.Unix(0, 0)
+			return
+		}
+		c.MaxAge = int(maxAge / time.Second)
+		c.Expires = time.Now().Add(maxAge)
+	}
+}
+
+// CookieSameSite 默认为Lax
+func CookieSameSite(mode http.SameSite) CookieOption {
+	return func(c *cookieSpec) {
+		c.SameSite = mode
+	}
+}
+
+// CookieSecure 默认为true，只在本地http调试时关闭
+func CookieSecure(secure bool) CookieOption {
+	return func(c *cookieSpec) {
+		c.Secure = secure
+	}
+}
+
+// CookieHTTPOnly 默认为true，需要被js读取时关闭
+func CookieHTTPOnly(httpOnly bool) CookieOption {
+	return func(c *cookieSpec) {
+		c.HttpOnly = httpOnly
+	}
+}
+
+// CookiePartitioned CHIPS分区cookie，嵌入到第三方页面时按照顶级站点隔离
+func CookiePartitioned() CookieOption {
+	return func(c *cookieSpec) {
+		c.partitioned = true
+	}
+}
+
+// SetCookie 使用安全的默认属性写入cookie：Path=/、HttpOnly、Secure、SameSite=Lax
+//
+//	lr.SetCookie(w, "theme", "dark", lr.CookieHTTPOnly(false), lr.CookieMaxAge(30*24*time.Hour))
+func SetCookie(w http.ResponseWriter, name, value string, opts ...CookieOption) error {
+	c := &cookieSpec{
+		Cookie: http.Cookie{
+			Name:     name,
+			Value:    value,
+			Path:     "/",
+			HttpOnly: true,
+			Secure:   true,
+			SameSite: http.SameSiteLaxMode,
+		},
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	if (c.SameSite == http.SameSiteNoneMode || c.partitioned) && !c.Secure {
+		return ErrInsecureCookie
+	}
+	if err := c.Valid(); err != nil {
+		return ErrInvalidCookie
+	}
+
+	header := c.String()
+	if c.partitioned {
+		header += "; Partitioned"
+	}
+	if len(header) > maxCookieBytes {
+		return ErrCookieTooLarge
+	}
+	w.Header().Add("Set-Cookie", header)
+	return nil
+}
+
+// DeleteCookie 让浏览器删除cookie，Path和Domain需要和写入时一致
+func DeleteCookie(w http.ResponseWriter, name string, opts ...CookieOption) error {
+	return SetCookie(w, name, "", append(opts, CookieMaxAge(0))...)
+}
+
+// SetCookie 写入cookie，默认属性见SetCookie
+func (c *Context) SetCookie(name, value string, opts ...CookieOption) error {
+	return SetCookie(c.Resp, name, value, opts...)
+}
+
+// DeleteCookie 删除cookie
+func (c *Context) DeleteCookie(name string, opts ...CookieOption) error {
+	return DeleteCookie(c.Resp, name, opts...)
+}
+
+// Cookie 获取请求中的cookie，不存在时返回http.ErrNoCookie
+func (c *Context) Cookie(name string) (*http.Cookie, error) {
+	return c.Req.Cookie(name)
+}
+
+// SetSecureCookie 通过codec签名或者加密之后写入cookie
+func (c *Context) SetSecureCookie(codec CookieCodec, name, value string, opts ...CookieOption) error {
+	encoded, err := codec.Encode(name, value)
+	if err != nil {
+		return err
+	}
+	return c.SetCookie(name, encoded, opts...)
+}
+
+// SecureCookie 读取SetSecureCookie写入的cookie，被篡改或者过期时返回错误
+func (c *Context) SecureCookie(codec CookieCodec, name string) (string, error) {
+	cookie, err := c.Req.Cookie(name)
+	if err != nil {
+		return "", err
+	}
+	return codec.Decode(name, cookie.Value)
+}
@@ -0,0 +1,174 @@
+package lr
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+var (
+	ErrCookieTampered = errors.New("lr: cookie被篡改或者格式错误")
+	ErrCookieExpired  = errors.New("lr: cookie已经过期")
+)
+
+// CookieCodec 对cookie的值进行编码，name参与签名，防止把一个cookie的值挪到另一个cookie中使用
+type CookieCodec interface {
+	Encode(name, value string) (string, error)
+	Decode(name, encoded string) (string, error)
+}
+
+var cookieEncoding = base64.RawURLEncoding
+
+// CookieSigner HMAC-SHA256签名的cookie，值对客户端可见但无法篡改
+// 第一个key用于签名，所有key都可以用于校验，轮换时把新key放在最前面，旧key保留到旧cookie全部过期
+type CookieSigner struct {
+	keys   [][]byte
+	maxAge time.Duration
+}
+
+// NewCookieSigner key建议至少32字节
+func NewCookieSigner(keys ...[]byte) *CookieSigner {
+	if len(keys) == 0 {
+		panic("lr: CookieSigner至少需要一个key")
+	}
+	return &CookieSigner{keys: keys}
+}
+
+// MaxAge 服务端校验的有效期，不依赖浏览器删除过期的cookie，为0时不校验
+func (s *CookieSigner) MaxAge(maxAge time.Duration) *CookieSigner {
+	s.maxAge = maxAge
+	return s
+}
+
+// Encode 格式为base64(时间戳+值).base64(签名)
+func (s *CookieSigner) Encode(name, value string) (string, error) {
+	payload := cookieEncoding.EncodeToString(appendTimestamp([]byte(value)))
+	return payload + "." + cookieEncoding.EncodeToString(s.sign(s.keys[0], name, payload)), nil
+}
+
+func (s *CookieSigner) Decode(name, encoded string) (string, error) {
+	payload, sig, ok := strings.Cut(encoded, ".")
+	if !ok {
+		return "", ErrCookieTampered
+	}
+	mac, err := cookieEncoding.DecodeString(sig)
+	if err != nil {
+		return "", ErrCookieTampered
+	}
+
+	verified := false
+	for _, key := range s.keys {
+		if hmac.Equal(mac, s.sign(key, name, payload)) {
+			verified = true
+			break
+		}
+	}
+	if !verified {
+		return "", ErrCookieTampered
+	}
+
+	data, err := cookieEncoding.DecodeString(payload)
+	if err != nil {
+		return "", ErrCookieTampered
+	}
+	return checkTimestamp(data, s.maxAge)
+}
+
+func (s *CookieSigner) sign(key []byte, name, payload string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(name))
+	h.Write([]byte{'|'})
+	h.Write([]byte(payload))
+	return h.Sum(nil)
+}
+
+// CookieEncrypter AES-GCM加密的cookie，客户端无法读取和篡改，适合保存少量数据
+// key的轮换方式和CookieSigner一致
+type CookieEncrypter struct {
+	aeads  []cipher.AEAD
+	maxAge time.Duration
+}
+
+// NewCookieEncrypter key的长度必须是16、24或者32字节，分别对应AES-128、AES-192、AES-256
+func NewCookieEncrypter(keys ...[]byte) (*CookieEncrypter, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("lr: CookieEncrypter至少需要一个key")
+	}
+	aeads := make([]cipher.AEAD, 0, len(keys))
+	for _, key := range keys {
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, fmt.Errorf("lr: 非法的cookie加密key: %w", err)
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+		aeads = append(aeads, aead)
+	}
+	return &CookieEncrypter{aeads: aeads}, nil
+}
+
+// MaxAge 服务端校验的有效期，为0时不校验
+func (e *CookieEncrypter) MaxAge(maxAge time.Duration) *CookieEncrypter {
+	e.maxAge = maxAge
+	return e
+}
+
+// Encode 格式为base64(nonce+密文)，name作为附加数据参与认证
+func (e *CookieEncrypter) Encode(name, value string) (string, error) {
+	aead := e.aeads[0]
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+8+len(value)+aead.Overhead())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, appendTimestamp([]byte(value)), []byte(name))
+	return cookieEncoding.EncodeToString(sealed), nil
+}
+
+func (e *CookieEncrypter) Decode(name, encoded string) (string, error) {
+	data, err := cookieEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", ErrCookieTampered
+	}
+
+	for _, aead := range e.aeads {
+		if len(data) < aead.NonceSize() {
+			continue
+		}
+		nonce, ciphertext := data[:aead.NonceSize()], data[aead.NonceSize():]
+		plain, err := aead.Open(nil, nonce, ciphertext, []byte(name))
+		if err != nil {
+			continue
+		}
+		return checkTimestamp(plain, e.maxAge)
+	}
+	return "", ErrCookieTampered
+}
+
+// appendTimestamp 在值前面加上8字节的unix时间戳，用于服务端校验有效期
+func appendTimestamp(value []byte) []byte {
+	res := make([]byte, 8, 8+len(value))
+	binary.BigEndian.PutUint64(res, uint64(time.Now().Unix()))
+	return append(res, value...)
+}
+
+func checkTimestamp(data []byte, maxAge time.Duration) (string, error) {
+	if len(data) < 8 {
+		return "", ErrCookieTampered
+	}
+	issued := time.Unix(int64(binary.BigEndian.Uint64(data[:8])), 0)
+	if maxAge > 0 && time.Since(issued) > maxAge {
+		return "", ErrCookieExpired
+	}
+	return string(data[8:]), nil
+}
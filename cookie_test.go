@@ -0,0 +1,191 @@
+package lr
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetCookie(t *testing.T) {
+	testCases := []struct {
+		name    string
+		value   string
+		opts    []CookieOption
+		want    string
+		wantErr error
+	}{
+		{
+			name:  "默认属性",
+			value: "abc",
+			want:  "sid=abc; Path=/; HttpOnly; Secure; SameSite=Lax",
+		},
+		{
+			name:  "自定义属性",
+			value: "dark",
+			opts:  []CookieOption{CookiePath("/app"), CookieDomain("example.com"), CookieHTTPOnly(false), CookieSameSite(http.SameSiteStrictMode)},
+			want:  "sid=dark; Path=/app; Domain=example.com; Secure; SameSite=Strict",
+		},
+		{
+			name:  "Partitioned",
+			value: "abc",
+			opts:  []CookieOption{CookieSameSite(http.SameSiteNoneMode), CookiePartitioned()},
+			want:  "sid=abc; Path=/; HttpOnly; Secure; SameSite=None; Partitioned",
+		},
+		{
+			name:    "SameSite=None没有Secure",
+			value:   "abc",
+			opts:    []CookieOption{CookieSameSite(http.SameSiteNoneMode), CookieSecure(false)},
+			wantErr: ErrInsecureCookie,
+		},
+		{
+			name:    "非法的值",
+			value:   `a"b`,
+			wantErr: ErrInvalidCookie,
+		},
+		{
+			name:    "超过4096字节",
+			value:   strings.Repeat("a", maxCookieBytes),
+			wantErr: ErrCookieTooLarge,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			err := SetCookie(recorder, "sid", tc.value, tc.opts...)
+			if tc.wantErr != nil {
+				assert.Equal(t, tc.wantErr, err)
+				assert.Empty(t, recorder.Header().Values("Set-Cookie"))
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, recorder.Header().Get("Set-Cookie"))
+		})
+	}
+}
+
+func TestContext_Cookie(t *testing.T) {
+	h := NewHTTPServer("tcp", ":8081")
+	h.POST("/login", func(ctx *Context) {
+		require.NoError(t, ctx.SetCookie("sid", "abc", CookieMaxAge(time.Hour)))
+		require.NoError(t, ctx.DeleteCookie("old"))
+	})
+	h.GET("/profile", func(ctx *Context) {
+		c, err := ctx.Cookie("sid")
+		require.NoError(t, err)
+		_ = ctx.RespString(http.StatusOK, c.Value)
+	})
+
+	recorder := httptest.NewRecorder()
+	h.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/login", nil))
+	cookies := recorder.Result().Cookies()
+	require.Len(t, cookies, 2)
+	assert.Equal(t, 3600, cookies[0].MaxAge)
+	assert.Equal(t, "old", cookies[1].Name)
+	assert.Equal(t, -1, cookies[1].MaxAge)
+
+	req := httptest.NewRequest(http.MethodGet, "/profile", nil)
+	req.AddCookie(cookies[0])
+	recorder = httptest.NewRecorder()
+	h.ServeHTTP(recorder, req)
+	assert.Equal(t, "abc", recorder.Body.String())
+}
+
+func TestCookieCodec(t *testing.T) {
+	oldKey := []byte("0123456789abcdef0123456789abcdef")
+	newKey := []byte("fedcba9876543210fedcba9876543210")
+	oldEncrypter, err := NewCookieEncrypter(oldKey)
+	require.NoError(t, err)
+	newEncrypter, err := NewCookieEncrypter(newKey, oldKey)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name   string
+		old    CookieCodec
+		rotate CookieCodec
+	}{
+		{
+			name:   "签名",
+			old:    NewCookieSigner(oldKey),
+			rotate: NewCookieSigner(newKey, oldKey),
+		},
+		{
+			name:   "加密",
+			old:    oldEncrypter,
+			rotate: newEncrypter,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			encoded, err := tc.rotate.Encode("uid", "42|admin")
+			require.NoError(t, err)
+			val, err := tc.rotate.Decode("uid", encoded)
+			require.NoError(t, err)
+			assert.Equal(t, "42|admin", val)
+
+			// 轮换之后旧key签发的cookie仍然有效，新key签发的旧codec无法识别
+			oldEncoded, err := tc.old.Encode("uid", "7")
+			require.NoError(t, err)
+			val, err = tc.rotate.Decode("uid", oldEncoded)
+			require.NoError(t, err)
+			assert.Equal(t, "7", val)
+			_, err = tc.old.Decode("uid", encoded)
+			assert.Equal(t, ErrCookieTampered, err)
+
+			// 不能挪到其他名字的cookie中使用
+			_, err = tc.rotate.Decode("role", encoded)
+			assert.Equal(t, ErrCookieTampered, err)
+
+			// 篡改
+			tampered := []byte(encoded)
+			tampered[2] ^= 1
+			_, err = tc.rotate.Decode("uid", string(tampered))
+			assert.Equal(t, ErrCookieTampered, err)
+			_, err = tc.rotate.Decode("uid", "")
+			assert.Equal(t, ErrCookieTampered, err)
+		})
+	}
+
+	t.Run("签名的值可读", func(t *testing.T) {
+		encoded, err := NewCookieSigner(oldKey).Encode("uid", "42")
+		require.NoError(t, err)
+		payload, _, _ := strings.Cut(encoded, ".")
+		data, err := cookieEncoding.DecodeString(payload)
+		require.NoError(t, err)
+		assert.Equal(t, "42", string(data[8:]))
+	})
+}
+
+func TestCookieCodec_MaxAge(t *testing.T) {
+	signer := NewCookieSigner([]byte("0123456789abcdef0123456789abcdef")).MaxAge(time.Hour)
+	payload := cookieEncoding.EncodeToString(append([]byte{0, 0, 0, 0, 0, 0, 0, 1}, "42"...))
+	expired := payload + "." + cookieEncoding.EncodeToString(signer.sign(signer.keys[0], "uid", payload))
+	_, err := signer.Decode("uid", expired)
+	assert.Equal(t, ErrCookieExpired, err)
+
+	_, err = NewCookieEncrypter([]byte("short"))
+	assert.Error(t, err)
+}
+
+func TestContext_SecureCookie(t *testing.T) {
+	codec := NewCookieSigner([]byte("0123456789abcdef0123456789abcdef"))
+	recorder := httptest.NewRecorder()
+	ctx := &Context{Req: httptest.NewRequest(http.MethodGet, "/", nil), Resp: recorder}
+	require.NoError(t, ctx.SetSecureCookie(codec, "uid", "42"))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(recorder.Result().Cookies()[0])
+	ctx = &Context{Req: req}
+	val, err := ctx.SecureCookie(codec, "uid")
+	require.NoError(t, err)
+	assert.Equal(t, "42", val)
+
+	_, err = ctx.SecureCookie(codec, "none")
+	assert.Equal(t, http.ErrNoCookie, err)
+}
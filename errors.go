@@ -0,0 +1,141 @@
+package lr
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// HandleErrFunc 可以返回错误的handler，通过Handle适配为HandleFunc
+type HandleErrFunc func(*Context) error
+
+// ErrorHandler 把handler返回的错误转换为响应
+type ErrorHandler func(ctx *Context, err error)
+
+// HTTPError 携带http状态码和业务错误码的错误
+type HTTPError struct {
+	// http状态码
+	Status int `json:"-"`
+	// 业务错误码
+	Code int `json:"code"`
+	// 返回给客户端的错误信息
+	Message string `json:"message"`
+	// 原始错误，只用于日志，不会返回给客户端
+	Err error `json:"-"`
+}
+
+// NewHTTPError 创建HTTPError，message为空时使用状态码的标准描述
+func NewHTTPError(status, code int, message string) *HTTPError {
+	if message == "" {
+		message = http.StatusText(status)
+	}
+	return &HTTPError{
+		Status:  status,
+		Code:    code,
+		Message: message,
+	}
+}
+
+func (e *HTTPError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("lr: http错误 status=%d code=%d message=%s: %v", e.Status, e.Code, e.Message, e.Err)
+	}
+	return fmt.Sprintf("lr: http错误 status=%d code=%d message=%s", e.Status, e.Code, e.Message)
+}
+
+func (e *HTTPError) Unwrap() error {
+	return e.Err
+}
+
+// Wrap 返回携带原始错误的副本
+func (e *HTTPError) Wrap(err error) *HTTPError {
+	res := *e
+	res.Err = err
+	return &res
+}
+
+// Handle 把返回错误的handler适配为HandleFunc，返回的错误交给ErrorHandler处理
+func Handle(fn HandleErrFunc) HandleFunc {
+	return func(ctx *Context) {
+		if err := fn(ctx); err != nil {
+			ctx.handleError(err)
+		}
+	}
+}
+
+// OnError 设置统一的错误处理，为nil时使用DefaultErrorHandler
+func OnError(handler ErrorHandler) HTTPServerOptions {
+	return func(s *HTTPServer) {
+		s.errHandler = handler
+	}
+}
+
+// DefaultErrorHandler 默认的错误处理
+// Problem返回application/problem+json，HTTPError按照携带的状态码返回json，绑定和校验的错误返回400，其他错误返回500且不暴露错误细节
+func DefaultErrorHandler(ctx *Context, err error) {
+	var (
+		problem        *Problem
+		httpErr        *HTTPError
+		validationErrs ValidationErrors
+		bindErrs       BindErrors
+	)
+	switch {
+	case errors.As(err, &problem):
+		_ = ctx.RespProblem(problem)
+	case errors.As(err, &httpErr):
+		_ = ctx.RespJSON(httpErr.Status, httpErr)
+	case errors.As(err, &validationErrs), errors.As(err, &bindErrs),
+		errors.Is(err, ErrRequestTooLarge), errors.Is(err, ErrJSONTrailingData):
+		_ = ctx.RespBadRequest(err)
+	case errors.Is(err, ErrNotAcceptable):
+		// Negotiate已经设置好了406响应
+	default:
+		_ = ctx.RespJSON(http.StatusInternalServerError, NewHTTPError(http.StatusInternalServerError, http.StatusInternalServerError, ""))
+	}
+}
+
+// Abort 终止后续的中间件和handler，已经在执行的中间件不受影响
+func (c *Context) Abort() {
+	c.aborted = true
+}
+
+// AbortWithStatus 终止后续的中间件和handler，并设置响应状态码
+func (c *Context) AbortWithStatus(status int) {
+	c.Abort()
+	c.Status = status
+}
+
+// AbortWithError 终止后续的中间件和handler，并把错误交给ErrorHandler处理
+func (c *Context) AbortWithError(err error) {
+	c.Abort()
+	c.handleError(err)
+}
+
+// IsAborted 是否已经终止
+func (c *Context) IsAborted() bool {
+	return c.aborted
+}
+
+// Err handler返回的或者AbortWithError传入的错误
+func (c *Context) Err() error {
+	return c.err
+}
+
+func (c *Context) handleError(err error) {
+	c.err = err
+	handler := c.errHandler
+	if handler == nil {
+		handler = DefaultErrorHandler
+	}
+	handler(c, err)
+}
+
+// abortable 终止之后不再执行next
+func abortable(next HandleFunc) HandleFunc {
+	return func(ctx *Context) {
+		if ctx.aborted {
+			return
+		}
+		next(ctx)
+	}
+}
@@ -0,0 +1,139 @@
+package lr
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandle(t *testing.T) {
+	errNotFound := NewHTTPError(http.StatusNotFound, 10404, "用户不存在")
+	testCases := []struct {
+		name       string
+		opts       []HTTPServerOptions
+		handler    HandleErrFunc
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name: "没有错误",
+			handler: func(ctx *Context) error {
+				return ctx.RespString(http.StatusOK, "ok")
+			},
+			wantStatus: http.StatusOK,
+			wantBody:   "ok",
+		},
+		{
+			name: "HTTPError",
+			handler: func(ctx *Context) error {
+				return errNotFound.Wrap(errors.New("sql: no rows"))
+			},
+			wantStatus: http.StatusNotFound,
+			wantBody:   `{"code":10404,"message":"用户不存在"}`,
+		},
+		{
+			name: "校验错误",
+			handler: func(ctx *Context) error {
+				return Validate(struct {
+					Name string `json:"name" validate:"required"`
+				}{})
+			},
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `{"message":"请求参数错误","errors":[{"field":"name","rule":"required","message":"不能为空"}]}`,
+		},
+		{
+			name: "未知错误",
+			handler: func(ctx *Context) error {
+				return errors.New("数据库密码错误")
+			},
+			wantStatus: http.StatusInternalServerError,
+			wantBody:   `{"code":500,"message":"Internal Server Error"}`,
+		},
+		{
+			name: "自定义ErrorHandler",
+			opts: []HTTPServerOptions{OnError(func(ctx *Context, err error) {
+				_ = ctx.RespString(http.StatusTeapot, err.Error())
+			})},
+			handler: func(ctx *Context) error {
+				return errors.New("boom")
+			},
+			wantStatus: http.StatusTeapot,
+			wantBody:   "boom",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			h := NewHTTPServer("tcp", ":8081", tc.opts...)
+			h.GET("/user", Handle(tc.handler))
+			recorder := httptest.NewRecorder()
+			h.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/user", nil))
+
+			assert.Equal(t, tc.wantStatus, recorder.Code)
+			assert.Equal(t, tc.wantBody, recorder.Body.String())
+		})
+	}
+}
+
+func TestContext_Abort(t *testing.T) {
+	var trace []string
+	h := NewHTTPServer("tcp", ":8081", Use(
+		func(next HandleFunc) HandleFunc {
+			return func(ctx *Context) {
+				trace = append(trace, "first before")
+				next(ctx)
+				trace = append(trace, "first after")
+			}
+		},
+		func(next HandleFunc) HandleFunc {
+			return func(ctx *Context) {
+				if ctx.Req.Header.Get("X-Token") == "" {
+					ctx.AbortWithStatus(http.StatusUnauthorized)
+				}
+				next(ctx)
+			}
+		},
+		func(next HandleFunc) HandleFunc {
+			return func(ctx *Context) {
+				trace = append(trace, "third")
+				next(ctx)
+			}
+		},
+	))
+	h.GET("/user", func(ctx *Context) {
+		trace = append(trace, "handler")
+	})
+
+	recorder := httptest.NewRecorder()
+	h.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/user", nil))
+	assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+	assert.Equal(t, []string{"first before", "first after"}, trace)
+
+	trace = nil
+	req := httptest.NewRequest(http.MethodGet, "/user", nil)
+	req.Header.Set("X-Token", "abc")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	assert.Equal(t, []string{"first before", "third", "handler", "first after"}, trace)
+}
+
+func TestContext_AbortWithError(t *testing.T) {
+	h := NewHTTPServer("tcp", ":8081", Use(func(next HandleFunc) HandleFunc {
+		return func(ctx *Context) {
+			ctx.AbortWithError(NewHTTPError(http.StatusForbidden, 10403, ""))
+			next(ctx)
+			assert.True(t, ctx.IsAborted())
+			assert.Error(t, ctx.Err())
+		}
+	}))
+	h.GET("/user", func(ctx *Context) {
+		t.Fatal("不应该执行handler")
+	})
+
+	recorder := httptest.NewRecorder()
+	h.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/user", nil))
+	assert.Equal(t, http.StatusForbidden, recorder.Code)
+	assert.Equal(t, `{"code":10403,"message":"Forbidden"}`, recorder.Body.String())
+}
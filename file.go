@@ -1,92 +1,651 @@
 package lr
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
 	"io"
 	"mime/multipart"
 	"net/http"
+	"net/url"
 	"os"
+	"path"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/liquanhui-99/lr/storage"
+	"github.com/liquanhui-99/lr/storage/local"
+)
+
+var (
+	ErrUploadNoFile         = errors.New("lr: 没有上传文件")
+	ErrUploadTooLarge       = errors.New("lr: 上传的文件超过了大小限制")
+	ErrUploadTooManyFiles   = errors.New("lr: 上传的文件数量超过了限制")
+	ErrUploadTypeNotAllowed = errors.New("lr: 不允许上传的文件类型")
+	ErrUploadConflict       = errors.New("lr: 同名文件已经存在")
+)
+
+// defaultUploadMaxMemory 普通表单字段合计的最大字节数，和net/http的默认值一致
+const defaultUploadMaxMemory = 32 << 20
+
+// ConflictPolicy 保存的文件已经存在时的处理方式
+type ConflictPolicy int
+
+const (
+	// ConflictRename 在文件名后面加上-1、-2这样的序号，默认的方式
+	ConflictRename ConflictPolicy = iota
+	// ConflictOverwrite 覆盖已经存在的文件
+	ConflictOverwrite
+	// ConflictReject 拒绝上传，返回ErrUploadConflict
+	ConflictReject
 )
 
 // FileUploader 文件上传功能实现
+//
+// 文件内容的类型通过前512个字节探测，不信任客户端的Content-Type；默认使用随机的文件名，不信任客户端的文件名。
+// 一次请求中任意一个文件失败时，已经保存的文件会被删除。
+// 默认保存到本地的Dir目录，设置Storage之后可以保存到任意的存储，例如S3。
+//
+//	uploader := &lr.FileUploader{
+//		FileFields:   []string{"avatar", "photos"},
+//		Dir:          "uploads",
+//		MaxFileSize:  5 << 20,
+//		MaxTotalSize: 20 << 20,
+//		AllowedTypes: []string{"image/*"},
+//	}
+//	server.POST("/upload", uploader.Handler())
 type FileUploader struct {
 	// 上传文件的字段名
 	FileField string
+	// 接受的多个字段名，和FileField合并；都为空时接受所有字段
+	FileFields []string
+	// 文件保存的目录，没有设置DestPathFunc和Storage时使用
+	Dir string
+	// 保存文件的存储，设置之后Dir不再生效
+	Storage storage.Storage
 	// 用户传递方法来处理文件存储的路径，不再方法里处理，返回值是文件的存储路径
+	// 设置之后Dir、Storage和FileNameFunc不再生效
 	DestPathFunc func(*multipart.FileHeader) string
+	// 生成Dir或者Storage中的文件名，可以包含子目录，默认是随机的名字加上原来的扩展名
+	// 需要保留原来的文件名时可以使用SafeFileName
+	FileNameFunc func(*multipart.FileHeader) string
+	// 同名文件已经存在时的处理方式
+	OnConflict ConflictPolicy
+
+	// 单个文件的最大字节数，0表示不限制
+	MaxFileSize int64
+	// 整个请求体的最大字节数，0表示不限制
+	MaxTotalSize int64
+	// 一次最多上传的文件数量，0表示不限制
+	MaxFiles int
+	// 普通表单字段(不是文件)合计的最大字节数，默认32MB；文件总是写入临时文件
+	MaxMemory int64
+	// 允许的文件类型，根据文件内容探测，支持image/*这样的通配；为空时不限制
+	AllowedTypes []string
+	// 允许的扩展名，例如.png，不区分大小写；为空时不限制
+	AllowedExts []string
+}
+
+// UploadedFile 保存成功的文件
+type UploadedFile struct {
+	// 表单字段名
+	Field string `json:"field"`
+	// 客户端的文件名
+	Filename string `json:"filename"`
+	// 保存的文件名，相对于Dir，使用Storage时是存储中的key，使用DestPathFunc时是文件名
+	Name string `json:"name"`
+	// 保存在本地时的完整路径，不返回给客户端
+	Path string `json:"-"`
+	// 文件大小
+	Size int64 `json:"size"`
+	// 根据内容探测的类型
+	ContentType string `json:"content_type"`
+}
+
+// UploadResult Handler返回的json
+type UploadResult struct {
+	Files []UploadedFile `json:"files"`
 }
 
+// Handler 上传成功时返回UploadResult，失败时把*HTTPError交给ErrorHandler：
+// 超过大小限制返回413，类型不允许返回415，同名文件冲突返回409，其他客户端错误返回400
 func (f *FileUploader) Handler() HandleFunc {
+	if f.Dir == "" && f.Storage == nil && f.DestPathFunc == nil {
+		panic("lr: FileUploader需要设置Dir、Storage或者DestPathFunc")
+	}
 	return func(ctx *Context) {
-		// 处理上传的逻辑
-		file, fileHeader, err := ctx.Req.FormFile(f.FileField)
+		files, err := f.Upload(ctx)
 		if err != nil {
-			ctx.Status = http.StatusInternalServerError
-			ctx.RespData = []byte("上传失败" + err.Error())
+			ctx.handleError(f.httpError(ctx, err))
 			return
 		}
-		// 处理要保存的目标路径，打开文件
-		path := f.DestPathFunc(fileHeader)
-		newFile, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0666)
+		_ = ctx.RespJSON(http.StatusOK, UploadResult{Files: files})
+	}
+}
+
+// Upload 保存请求中的文件，可以在自己的handler中使用
+//
+// 请求体按照part逐个读取，文件先写入临时文件，超过MaxFileSize或者MaxFiles时立即停止读取，
+// 不会先把整个请求体保存下来再检查。普通表单字段在这之后可以通过FormValue读取。
+func (f *FileUploader) Upload(ctx *Context) ([]UploadedFile, error) {
+	req := ctx.Req
+	if f.MaxTotalSize > 0 {
+		req.Body = http.MaxBytesReader(ctx.Resp, req.Body, f.MaxTotalSize)
+	}
+	form, err := f.receive(req)
+	defer form.cleanup()
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			return nil, fmt.Errorf("%w: 请求体限制为%d字节", ErrUploadTooLarge, maxErr.Limit)
+		}
+		return nil, err
+	}
+
+	parts := f.collect(form.files)
+	if len(parts) == 0 {
+		return nil, ErrUploadNoFile
+	}
+
+	res := make([]UploadedFile, 0, len(parts))
+	saved := make([]storedFile, 0, len(parts))
+	for _, part := range parts {
+		file, stored, err := f.save(req.Context(), part)
 		if err != nil {
-			ctx.Status = http.StatusInternalServerError
-			ctx.RespData = []byte("上传失败" + err.Error())
-			return
+			// 只要有一个失败，整个请求都不生效；请求可能已经取消，不能使用请求的context
+			for _, s := range saved {
+				_ = s.store.Delete(context.Background(), s.key)
+			}
+			return nil, err
 		}
+		res = append(res, file)
+		saved = append(saved, stored)
+	}
+	return res, nil
+}
+
+// uploadPart 收到的文件，path是临时文件；请求已经被ParseMultipartForm解析时path为空，直接使用header
+type uploadPart struct {
+	field  string
+	header *multipart.FileHeader
+	path   string
+}
 
-		// 开始copy
-		_, err = io.CopyBuffer(newFile, file, nil)
+func (p *uploadPart) open() (io.ReadCloser, error) {
+	if p.path == "" {
+		return p.header.Open()
+	}
+	return os.Open(p.path)
+}
+
+type uploadForm struct {
+	files map[string][]*uploadPart
+}
+
+// cleanup 删除临时文件，保存之后的文件已经在存储中
+func (u *uploadForm) cleanup() {
+	for _, parts := range u.files {
+		for _, part := range parts {
+			if part.path != "" {
+				_ = os.Remove(part.path)
+			}
+		}
+	}
+}
+
+// receive 逐个读取part，需要的文件写入临时文件，普通字段保存到req.MultipartForm
+func (f *FileUploader) receive(req *http.Request) (*uploadForm, error) {
+	form := &uploadForm{files: make(map[string][]*uploadPart, 4)}
+	// 已经被中间件之类的解析过，只能使用解析的结果
+	if req.MultipartForm != nil {
+		for field, headers := range req.MultipartForm.File {
+			for _, header := range headers {
+				if f.acceptField(field) && f.MaxFileSize > 0 && header.Size > f.MaxFileSize {
+					return form, fmt.Errorf("%w: %s超过%d字节", ErrUploadTooLarge, header.Filename, f.MaxFileSize)
+				}
+				form.files[field] = append(form.files[field], &uploadPart{field: field, header: header})
+			}
+		}
+		return form, f.checkCount(form)
+	}
+
+	mr, err := req.MultipartReader()
+	if err != nil {
+		return form, err
+	}
+	valueBudget := f.MaxMemory
+	if valueBudget <= 0 {
+		valueBudget = defaultUploadMaxMemory
+	}
+	values := make(url.Values)
+	for {
+		p, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
 		if err != nil {
-			ctx.Status = http.StatusInternalServerError
-			ctx.RespData = []byte("上传失败" + err.Error())
-			return
+			return form, err
 		}
+		field := p.FormName()
+		if field == "" {
+			continue
+		}
+		if p.FileName() == "" {
+			var sb strings.Builder
+			n, err := io.Copy(&sb, io.LimitReader(p, valueBudget+1))
+			if err != nil {
+				return form, err
+			}
+			valueBudget -= n
+			if valueBudget < 0 {
+				return form, fmt.Errorf("%w: 表单字段超过了限制", ErrUploadTooLarge)
+			}
+			values.Add(field, sb.String())
+			continue
+		}
+		// 不需要的字段不保存，NextPart会跳过剩下的内容
+		if !f.acceptField(field) {
+			continue
+		}
+		part, err := f.spool(field, p)
+		if part != nil {
+			form.files[field] = append(form.files[field], part)
+		}
+		if err != nil {
+			return form, err
+		}
+		if err = f.checkCount(form); err != nil {
+			return form, err
+		}
+	}
 
-		ctx.Status = http.StatusOK
-		ctx.RespData = []byte("上传成功")
+	req.MultipartForm = &multipart.Form{Value: values}
+	if req.PostForm == nil {
+		req.PostForm = values
+	}
+	if req.Form == nil {
+		req.Form = make(url.Values, len(values))
+		for key, vals := range req.URL.Query() {
+			req.Form[key] = vals
+		}
+		for key, vals := range values {
+			req.Form[key] = append(append([]string(nil), vals...), req.Form[key]...)
+		}
+	}
+	return form, nil
+}
+
+// spool 把文件写入临时文件，超过MaxFileSize时停止读取
+func (f *FileUploader) spool(field string, p *multipart.Part) (*uploadPart, error) {
+	tmp, err := os.CreateTemp("", "lr-upload-*")
+	if err != nil {
+		return nil, err
+	}
+	part := &uploadPart{
+		field:  field,
+		header: &multipart.FileHeader{Filename: p.FileName(), Header: p.Header},
+		path:   tmp.Name(),
+	}
+	var src io.Reader = p
+	if f.MaxFileSize > 0 {
+		src = io.LimitReader(p, f.MaxFileSize+1)
+	}
+	part.header.Size, err = io.Copy(tmp, src)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return part, err
 	}
+	if f.MaxFileSize > 0 && part.header.Size > f.MaxFileSize {
+		return part, fmt.Errorf("%w: %s超过%d字节", ErrUploadTooLarge, part.header.Filename, f.MaxFileSize)
+	}
+	return part, nil
 }
 
-// FileDownloader 文件下载功能实现
+func (f *FileUploader) checkCount(form *uploadForm) error {
+	if f.MaxFiles <= 0 {
+		return nil
+	}
+	cnt := 0
+	for field, parts := range form.files {
+		if f.acceptField(field) {
+			cnt += len(parts)
+		}
+	}
+	if cnt > f.MaxFiles {
+		return fmt.Errorf("%w: 最多%d个", ErrUploadTooManyFiles, f.MaxFiles)
+	}
+	return nil
+}
+
+// storedFile 已经保存的文件，失败时用来回滚
+type storedFile struct {
+	store storage.Storage
+	key   string
+}
+
+// fields 接受的字段名，为空时接受所有字段
+func (f *FileUploader) fields() []string {
+	if f.FileField != "" {
+		return append([]string{f.FileField}, f.FileFields...)
+	}
+	return f.FileFields
+}
+
+func (f *FileUploader) acceptField(field string) bool {
+	allowed := f.fields()
+	if len(allowed) == 0 {
+		return true
+	}
+	for _, a := range allowed {
+		if a == field {
+			return true
+		}
+	}
+	return false
+}
+
+// collect 按照字段的顺序收集需要保存的文件
+func (f *FileUploader) collect(form map[string][]*uploadPart) []*uploadPart {
+	allowed := f.fields()
+	if len(allowed) == 0 {
+		for field := range form {
+			allowed = append(allowed, field)
+		}
+		sort.Strings(allowed)
+	}
+
+	var (
+		parts []*uploadPart
+		seen  = make(map[string]bool, len(allowed))
+	)
+	for _, field := range allowed {
+		if seen[field] {
+			continue
+		}
+		seen[field] = true
+		parts = append(parts, form[field]...)
+	}
+	return parts
+}
+
+func (f *FileUploader) save(ctx context.Context, part *uploadPart) (UploadedFile, storedFile, error) {
+	header := part.header
+	res := UploadedFile{Field: part.field, Filename: header.Filename}
+	if !f.allowedExt(filepath.Ext(header.Filename)) {
+		return res, storedFile{}, fmt.Errorf("%w: %s", ErrUploadTypeNotAllowed, header.Filename)
+	}
+
+	src, err := part.open()
+	if err != nil {
+		return res, storedFile{}, err
+	}
+	sniff := make([]byte, 512)
+	n, err := io.ReadFull(src, sniff)
+	_ = src.Close()
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return res, storedFile{}, err
+	}
+	res.ContentType = http.DetectContentType(sniff[:n])
+	if !f.allowedType(res.ContentType) {
+		return res, storedFile{}, fmt.Errorf("%w: %s是%s", ErrUploadTypeNotAllowed, header.Filename, res.ContentType)
+	}
+
+	store, key := f.target(header)
+	ext := path.Ext(key)
+	base := strings.TrimSuffix(key, ext)
+	for i := 1; ; i++ {
+		info, err := f.put(ctx, store, key, part, res.ContentType)
+		if err == nil {
+			res.Name, res.Size = key, info.Size
+			if l, ok := store.(*local.Storage); ok {
+				res.Path, _ = l.Path(key)
+			}
+			return res, storedFile{store: store, key: key}, nil
+		}
+		if !errors.Is(err, storage.ErrExists) {
+			return res, storedFile{}, err
+		}
+		if f.OnConflict == ConflictReject {
+			return res, storedFile{}, fmt.Errorf("%w: %s", ErrUploadConflict, path.Base(key))
+		}
+		key = base + "-" + strconv.Itoa(i) + ext
+	}
+}
+
+// target 文件保存的存储和key
+func (f *FileUploader) target(header *multipart.FileHeader) (storage.Storage, string) {
+	if f.DestPathFunc != nil {
+		dest := f.DestPathFunc(header)
+		return local.NewStorage(filepath.Dir(dest)), filepath.Base(dest)
+	}
+	name := randomFileName(header)
+	if f.FileNameFunc != nil {
+		name = f.FileNameFunc(header)
+	}
+	key := storageKey(name)
+	if f.Storage != nil {
+		return f.Storage, key
+	}
+	return local.NewStorage(f.Dir), key
+}
+
+// put 每次都重新打开文件，存储在冲突时可能已经读取了部分内容
+func (f *FileUploader) put(ctx context.Context, store storage.Storage, key string, part *uploadPart, contentType string) (storage.ObjectInfo, error) {
+	src, err := part.open()
+	if err != nil {
+		return storage.ObjectInfo{}, err
+	}
+	defer src.Close()
+	return store.Put(ctx, key, src, storage.PutOptions{
+		ContentType: contentType,
+		Size:        part.header.Size,
+		IfNotExists: f.OnConflict != ConflictOverwrite,
+	})
+}
+
+func (f *FileUploader) allowedType(contentType string) bool {
+	if len(f.AllowedTypes) == 0 {
+		return true
+	}
+	contentType = baseMediaType(contentType)
+	for _, allowed := range f.AllowedTypes {
+		allowed = strings.ToLower(allowed)
+		if allowed == contentType || strings.HasSuffix(allowed, "/*") &&
+			strings.HasPrefix(contentType, strings.TrimSuffix(allowed, "*")) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *FileUploader) allowedExt(ext string) bool {
+	if len(f.AllowedExts) == 0 {
+		return true
+	}
+	for _, allowed := range f.AllowedExts {
+		if strings.EqualFold(allowed, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *FileUploader) httpError(ctx *Context, err error) *HTTPError {
+	var (
+		status int
+		key    string
+		msg    string
+	)
+	switch {
+	case errors.Is(err, ErrUploadTooLarge):
+		status, key, msg = http.StatusRequestEntityTooLarge, "lr.upload_too_large", "上传的文件超过了大小限制"
+	case errors.Is(err, ErrUploadTypeNotAllowed):
+		status, key, msg = http.StatusUnsupportedMediaType, "lr.upload_type", "不允许上传这种类型的文件"
+	case errors.Is(err, ErrUploadConflict):
+		status, key, msg = http.StatusConflict, "lr.upload_conflict", "同名文件已经存在"
+	case errors.Is(err, ErrUploadTooManyFiles):
+		status, key, msg = http.StatusBadRequest, "lr.upload_too_many", "上传的文件数量超过了限制"
+	case errors.Is(err, ErrUploadNoFile):
+		status, key, msg = http.StatusBadRequest, "lr.upload_no_file", "没有上传文件"
+	case errors.Is(err, http.ErrNotMultipart), errors.Is(err, http.ErrMissingBoundary):
+		status, key, msg = http.StatusBadRequest, "lr.upload_no_file", "没有上传文件"
+	default:
+		status, key, msg = http.StatusInternalServerError, "lr.upload_failed", "上传失败"
+	}
+	httpErr := NewHTTPError(status, status, ctx.localize(key, msg))
+	httpErr.Err = err
+	return httpErr
+}
+
+// storageKey 把文件名转换为存储的key，去掉../这样跳出根目录的部分，统一使用/分隔
+func storageKey(name string) string {
+	return strings.TrimPrefix(path.Clean("/"+strings.ReplaceAll(name, `\`, "/")), "/")
+}
+
+// randomFileName 随机的文件名加上客户端文件名中安全的扩展名
+func randomFileName(header *multipart.FileHeader) string {
+	buf := make([]byte, 16)
+	_, _ = rand.Read(buf)
+	return hex.EncodeToString(buf) + safeExt(header.Filename)
+}
+
+// SafeFileName 把客户端的文件名转换为可以安全保存的名字：
+// 去掉路径，只保留字母、数字、中文等文字以及.-_，其他字符替换为_，不以.开头，最长200个字节
+//
+//	SafeFileName("../../etc/passwd")  // passwd
+//	SafeFileName("我的 简历(1).pdf")   // 我的_简历_1_.pdf
+func SafeFileName(name string) string {
+	// 客户端可能使用\作为分隔符
+	name = name[strings.LastIndexAny(name, `/\`)+1:]
+	var sb strings.Builder
+	for _, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '-', r == '_':
+			sb.WriteRune(r)
+		case r > 127 && r != utf8.RuneError && (unicode.IsLetter(r) || unicode.IsDigit(r)):
+			sb.WriteRune(r)
+		default:
+			sb.WriteByte('_')
+		}
+	}
+	res := strings.TrimLeft(sb.String(), ".")
+	if len(res) > 200 {
+		ext := safeExt(res)
+		res = truncateUTF8(res[:len(res)-len(ext)], 200-len(ext)) + ext
+	}
+	if res == "" {
+		res = "file"
+	}
+	return res
+}
+
+// safeExt 小写的扩展名，只包含字母和数字并且不超过10个字符，否则返回空字符串
+func safeExt(name string) string {
+	ext := strings.ToLower(filepath.Ext(name[strings.LastIndexAny(name, `/\`)+1:]))
+	if len(ext) < 2 || len(ext) > 10 {
+		return ""
+	}
+	for _, c := range ext[1:] {
+		if !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9') {
+			return ""
+		}
+	}
+	return ext
+}
+
+func truncateUTF8(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+// FileDownloader 文件下载功能实现，查询参数file是要下载的文件
+//
+// 支持条件请求，存储返回的内容实现了io.Seeker时(例如本地文件)还支持Range
 type FileDownloader struct {
+	// 文件所在的目录，没有设置Storage时使用
 	Dir string
+	// 读取文件的存储，设置之后Dir不再生效
+	Storage storage.Storage
 }
 
 func (f FileDownloader) Handle() HandleFunc {
+	store := f.Storage
+	if store == nil {
+		store = local.NewStorage(f.Dir)
+	}
 	return func(ctx *Context) {
 		res := ctx.QueryValue("file")
 		if res.err != nil {
 			ctx.Status = 400
-			ctx.RespData = []byte("找不到目标文件")
+			ctx.RespData = []byte(ctx.localize("lr.file_not_found", "找不到目标文件"))
 			return
 		}
-		// 全路径
-		req := filepath.Clean(res.val)
-		dest := filepath.Join(f.Dir, req)
 		// 这里做路径校验，防止攻击者发送相对路径导致下载其他的内部文件
-		dest, err := filepath.Abs(dest)
+		key := storageKey(res.val)
+		rc, info, err := store.Get(ctx.Req.Context(), key)
 		if err != nil {
-			ctx.Status = 400
-			ctx.RespData = []byte("找不到目标文件")
-			return
-		}
-		if !strings.Contains(dest, f.Dir) {
-			ctx.Status = 400
-			ctx.RespData = []byte("找不到目标文件")
+			switch {
+			case errors.Is(err, storage.ErrInvalidKey):
+				ctx.Status = 400
+			case errors.Is(err, storage.ErrNotFound):
+				ctx.Status = 404
+			default:
+				ctx.handleError(err)
+				return
+			}
+			ctx.RespData = []byte(ctx.localize("lr.file_not_found", "找不到目标文件"))
 			return
 		}
+		defer rc.Close()
 		// 文件名
-		fileName := filepath.Base(dest)
+		fileName := path.Base(key)
 
 		header := ctx.Resp.Header()
-		header.Set("Content-Disposition", "attachment;filename="+fileName)
+		header.Set("Content-Disposition", contentDisposition("attachment", fileName))
 		header.Set("Content-Description", "File Transfer")
 		header.Set("Content-Type", "application/octet-stream")
 		header.Set("Content-Transfer-Encoding", "binary")
 		header.Set("Expires", "0")
 		header.Set("Cache-Control", "must-revalidate")
 		header.Set("Pragma", "public")
-		http.ServeFile(ctx.Resp, ctx.Req, dest)
+		if info.ETag != "" {
+			header.Set("ETag", info.ETag)
+		}
+		// 直接写入了响应，框架不再处理RespData
+		ctx.committed = true
+		if rs, ok := rc.(io.ReadSeeker); ok {
+			http.ServeContent(ctx.Resp, ctx.Req, fileName, info.ModTime, rs)
+			return
+		}
+
+		// 不支持Range，条件请求使用ETag和Last-Modified校验
+		ctx.SetLastModified(info.ModTime)
+		if status := ctx.evaluatePreconditions(); status != 0 {
+			header.Del("Content-Type")
+			ctx.Status = status
+			ctx.Resp.WriteHeader(status)
+			return
+		}
+		if info.Size >= 0 {
+			header.Set("Content-Length", strconv.FormatInt(info.Size, 10))
+		}
+		ctx.Status = http.StatusOK
+		ctx.Resp.WriteHeader(http.StatusOK)
+		if ctx.Req.Method != http.MethodHead {
+			_, _ = io.Copy(ctx.Resp, rc)
+		}
 	}
 }
@@ -0,0 +1,443 @@
+package lr
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/liquanhui-99/lr/storage"
+	"github.com/liquanhui-99/lr/storage/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testPNG = append([]byte("\x89PNG\r\n\x1a\n"), make([]byte, 64)...)
+
+type testUploadFile struct {
+	field    string
+	filename string
+	content  []byte
+}
+
+func newUploadRequest(t *testing.T, files ...testUploadFile) *http.Request {
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	for _, file := range files {
+		part, err := writer.CreateFormFile(file.field, file.filename)
+		require.NoError(t, err)
+		_, err = part.Write(file.content)
+		require.NoError(t, err)
+	}
+	require.NoError(t, writer.WriteField("title", "不是文件"))
+	require.NoError(t, writer.Close())
+
+	req := httptest.NewRequest(http.MethodPost, "/upload", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	return req
+}
+
+func TestFileUploader_Handler(t *testing.T) {
+	testCases := []struct {
+		name     string
+		uploader FileUploader
+		existing map[string]string
+		files    []testUploadFile
+
+		wantCode  int
+		wantNames []string
+		wantFiles map[string]string
+		wantMsg   string
+	}{
+		{
+			name:     "多个字段多个文件",
+			uploader: FileUploader{FileFields: []string{"avatar", "photos"}, FileNameFunc: func(h *multipart.FileHeader) string { return SafeFileName(h.Filename) }},
+			files: []testUploadFile{
+				{field: "photos", filename: "a.png", content: testPNG},
+				{field: "avatar", filename: "../../me.png", content: testPNG},
+				{field: "photos", filename: "b.txt", content: []byte("hello")},
+				{field: "ignored", filename: "c.txt", content: []byte("ignored")},
+			},
+			wantCode:  http.StatusOK,
+			wantNames: []string{"me.png", "a.png", "b.txt"},
+			wantFiles: map[string]string{"me.png": string(testPNG), "a.png": string(testPNG), "b.txt": "hello"},
+		},
+		{
+			name:      "默认随机文件名",
+			uploader:  FileUploader{FileField: "file"},
+			files:     []testUploadFile{{field: "file", filename: "报告.PDF", content: []byte("%PDF-1.4")}},
+			wantCode:  http.StatusOK,
+			wantNames: []string{".pdf"},
+		},
+		{
+			name:     "单个文件超过大小",
+			uploader: FileUploader{FileField: "file", MaxFileSize: 10},
+			files:    []testUploadFile{{field: "file", filename: "a.png", content: testPNG}},
+			wantCode: http.StatusRequestEntityTooLarge,
+			wantMsg:  "上传的文件超过了大小限制",
+		},
+		{
+			name:     "请求体超过大小",
+			uploader: FileUploader{FileField: "file", MaxTotalSize: 100},
+			files: []testUploadFile{
+				{field: "file", filename: "a.png", content: testPNG},
+				{field: "file", filename: "b.png", content: testPNG},
+			},
+			wantCode: http.StatusRequestEntityTooLarge,
+		},
+		{
+			name:     "文件数量超过限制",
+			uploader: FileUploader{FileField: "file", MaxFiles: 1},
+			files: []testUploadFile{
+				{field: "file", filename: "a.png", content: testPNG},
+				{field: "file", filename: "b.png", content: testPNG},
+			},
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "按照内容探测类型",
+			uploader: FileUploader{FileField: "file", AllowedTypes: []string{"image/*"}},
+			files:    []testUploadFile{{field: "file", filename: "fake.png", content: []byte("<html><script>alert(1)</script>")}},
+			wantCode: http.StatusUnsupportedMediaType,
+			wantMsg:  "不允许上传这种类型的文件",
+		},
+		{
+			name:     "扩展名不允许",
+			uploader: FileUploader{FileField: "file", AllowedExts: []string{".png"}},
+			files:    []testUploadFile{{field: "file", filename: "a.exe", content: testPNG}},
+			wantCode: http.StatusUnsupportedMediaType,
+		},
+		{
+			name:     "没有文件",
+			uploader: FileUploader{FileField: "file"},
+			wantCode: http.StatusBadRequest,
+			wantMsg:  "没有上传文件",
+		},
+		{
+			name:      "同名文件重命名",
+			uploader:  FileUploader{FileField: "file", FileNameFunc: func(h *multipart.FileHeader) string { return h.Filename }},
+			existing:  map[string]string{"a.txt": "old", "a-1.txt": "old"},
+			files:     []testUploadFile{{field: "file", filename: "a.txt", content: []byte("new")}},
+			wantCode:  http.StatusOK,
+			wantNames: []string{"a-2.txt"},
+			wantFiles: map[string]string{"a.txt": "old", "a-1.txt": "old", "a-2.txt": "new"},
+		},
+		{
+			name:      "同名文件覆盖",
+			uploader:  FileUploader{FileField: "file", OnConflict: ConflictOverwrite, FileNameFunc: func(h *multipart.FileHeader) string { return h.Filename }},
+			existing:  map[string]string{"a.txt": "old"},
+			files:     []testUploadFile{{field: "file", filename: "a.txt", content: []byte("new")}},
+			wantCode:  http.StatusOK,
+			wantNames: []string{"a.txt"},
+			wantFiles: map[string]string{"a.txt": "new"},
+		},
+		{
+			name:     "同名文件拒绝，已经保存的文件被删除",
+			uploader: FileUploader{FileField: "file", OnConflict: ConflictReject, FileNameFunc: func(h *multipart.FileHeader) string { return h.Filename }},
+			existing: map[string]string{"b.txt": "old"},
+			files: []testUploadFile{
+				{field: "file", filename: "a.txt", content: []byte("new")},
+				{field: "file", filename: "b.txt", content: []byte("new")},
+			},
+			wantCode:  http.StatusConflict,
+			wantFiles: map[string]string{"b.txt": "old"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, content := range tc.existing {
+				require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644))
+			}
+			uploader := tc.uploader
+			uploader.Dir = dir
+			h := NewHTTPServer("tcp", ":8081")
+			h.POST("/upload", uploader.Handler())
+
+			recorder := httptest.NewRecorder()
+			h.ServeHTTP(recorder, newUploadRequest(t, tc.files...))
+			assert.Equal(t, tc.wantCode, recorder.Code)
+
+			if tc.wantCode != http.StatusOK {
+				var httpErr HTTPError
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &httpErr))
+				if tc.wantMsg != "" {
+					assert.Equal(t, tc.wantMsg, httpErr.Message)
+				}
+			} else {
+				var res UploadResult
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &res))
+				require.Len(t, res.Files, len(tc.wantNames))
+				for i, file := range res.Files {
+					assert.True(t, strings.HasSuffix(file.Name, tc.wantNames[i]), file.Name)
+					assert.Empty(t, file.Path)
+					info, err := os.Stat(filepath.Join(dir, file.Name))
+					require.NoError(t, err)
+					assert.Equal(t, info.Size(), file.Size)
+				}
+			}
+
+			entries, err := os.ReadDir(dir)
+			require.NoError(t, err)
+			if tc.wantFiles != nil {
+				assert.Len(t, entries, len(tc.wantFiles))
+			}
+			for name, content := range tc.wantFiles {
+				data, err := os.ReadFile(filepath.Join(dir, name))
+				require.NoError(t, err)
+				assert.Equal(t, content, string(data))
+			}
+		})
+	}
+}
+
+func TestFileUploader_Upload(t *testing.T) {
+	dir := t.TempDir()
+	uploader := &FileUploader{
+		FileField: "file",
+		DestPathFunc: func(header *multipart.FileHeader) string {
+			return filepath.Join(dir, "legacy", header.Filename)
+		},
+	}
+	ctx := &Context{
+		Req:  newUploadRequest(t, testUploadFile{field: "file", filename: "a.png", content: testPNG}),
+		Resp: httptest.NewRecorder(),
+	}
+	files, err := uploader.Upload(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []UploadedFile{{
+		Field:       "file",
+		Filename:    "a.png",
+		Name:        "a.png",
+		Path:        filepath.Join(dir, "legacy", "a.png"),
+		Size:        int64(len(testPNG)),
+		ContentType: "image/png",
+	}}, files)
+
+	assert.Panics(t, func() {
+		(&FileUploader{FileField: "file"}).Handler()
+	})
+}
+
+func TestFileUploader_Streaming(t *testing.T) {
+	tmp := t.TempDir()
+	t.Setenv("TMPDIR", tmp)
+
+	// 超过MaxFileSize之后不再继续读取请求体
+	body := &countingReader{r: io.MultiReader(
+		strings.NewReader("--b\r\nContent-Disposition: form-data; name=\"file\"; filename=\"a.bin\"\r\n\r\n"),
+		io.LimitReader(zeroReader{}, 100<<20),
+	)}
+	req := httptest.NewRequest(http.MethodPost, "/upload", body)
+	req.Header.Set("Content-Type", "multipart/form-data; boundary=b")
+	uploader := &FileUploader{FileField: "file", Dir: t.TempDir(), MaxFileSize: 1 << 10}
+	_, err := uploader.Upload(&Context{Req: req, Resp: httptest.NewRecorder()})
+	assert.ErrorIs(t, err, ErrUploadTooLarge)
+	assert.Less(t, body.n, int64(1<<20))
+
+	// 普通字段依然可以读取，临时文件被删除
+	ctx := &Context{
+		Req:  newUploadRequest(t, testUploadFile{field: "file", filename: "a.png", content: testPNG}),
+		Resp: httptest.NewRecorder(),
+	}
+	files, err := uploader.Upload(ctx)
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	assert.Equal(t, "不是文件", ctx.FormValue("title").val)
+	entries, err := os.ReadDir(tmp)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+
+	// 已经解析过的表单
+	req = newUploadRequest(t, testUploadFile{field: "file", filename: "a.png", content: testPNG})
+	require.NoError(t, req.ParseMultipartForm(1<<20))
+	files, err = uploader.Upload(&Context{Req: req, Resp: httptest.NewRecorder()})
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(testPNG)), files[0].Size)
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = 0
+	}
+	return len(p), nil
+}
+
+func TestFileUploader_Storage(t *testing.T) {
+	store := memory.NewStorage()
+	_, err := store.Put(context.Background(), "docs/a.txt", strings.NewReader("old"), storage.PutOptions{Size: 3})
+	require.NoError(t, err)
+	uploader := &FileUploader{
+		FileField:    "file",
+		Storage:      store,
+		FileNameFunc: func(h *multipart.FileHeader) string { return "docs/" + SafeFileName(h.Filename) },
+	}
+	h := NewHTTPServer("tcp", ":8081")
+	h.POST("/upload", uploader.Handler())
+
+	recorder := httptest.NewRecorder()
+	h.ServeHTTP(recorder, newUploadRequest(t,
+		testUploadFile{field: "file", filename: "a.txt", content: []byte("new")},
+		testUploadFile{field: "file", filename: "b.png", content: testPNG},
+	))
+	require.Equal(t, http.StatusOK, recorder.Code)
+	var res UploadResult
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &res))
+	require.Len(t, res.Files, 2)
+	assert.Equal(t, "docs/a-1.txt", res.Files[0].Name)
+	assert.Equal(t, "docs/b.png", res.Files[1].Name)
+
+	info, err := store.Stat(context.Background(), "docs/b.png")
+	require.NoError(t, err)
+	assert.Equal(t, "image/png", info.ContentType)
+	assert.Equal(t, int64(len(testPNG)), info.Size)
+
+	// 失败时删除存储中已经保存的文件
+	uploader.OnConflict = ConflictReject
+	recorder = httptest.NewRecorder()
+	h.ServeHTTP(recorder, newUploadRequest(t,
+		testUploadFile{field: "file", filename: "c.txt", content: []byte("c")},
+		testUploadFile{field: "file", filename: "a.txt", content: []byte("new")},
+	))
+	assert.Equal(t, http.StatusConflict, recorder.Code)
+	infos, err := store.List(context.Background(), "")
+	require.NoError(t, err)
+	assert.Len(t, infos, 3)
+}
+
+// noSeekStorage Get返回的内容不支持Seek，和S3一样
+type noSeekStorage struct {
+	storage.Storage
+}
+
+func (s noSeekStorage) Get(ctx context.Context, key string) (io.ReadCloser, storage.ObjectInfo, error) {
+	rc, info, err := s.Storage.Get(ctx, key)
+	if err != nil {
+		return nil, info, err
+	}
+	return struct{ io.ReadCloser }{rc}, info, nil
+}
+
+func TestFileDownloader_Handle(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "报告.txt"), []byte("0123456789"), 0o644))
+	secret := filepath.Join(filepath.Dir(dir), filepath.Base(dir)+"-secret.txt")
+	require.NoError(t, os.WriteFile(secret, []byte("secret"), 0o644))
+	t.Cleanup(func() { _ = os.Remove(secret) })
+
+	store := memory.NewStorage()
+	info, err := store.Put(context.Background(), "报告.txt", strings.NewReader("0123456789"), storage.PutOptions{Size: 10})
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name       string
+		downloader FileDownloader
+		file       string
+		rangeHdr   string
+		headers    map[string]string
+
+		wantCode int
+		wantBody string
+	}{
+		{name: "本地目录", downloader: FileDownloader{Dir: dir}, file: "报告.txt", wantCode: http.StatusOK, wantBody: "0123456789"},
+		{name: "Range", downloader: FileDownloader{Dir: dir}, file: "报告.txt", rangeHdr: "bytes=2-4", wantCode: http.StatusPartialContent, wantBody: "234"},
+		{name: "不能跳出目录", downloader: FileDownloader{Dir: dir}, file: "../" + filepath.Base(secret), wantCode: http.StatusNotFound, wantBody: "找不到目标文件"},
+		{name: "没有file参数", downloader: FileDownloader{Dir: dir}, wantCode: http.StatusBadRequest, wantBody: "找不到目标文件"},
+		{name: "目录", downloader: FileDownloader{Dir: dir}, file: "/", wantCode: http.StatusBadRequest, wantBody: "找不到目标文件"},
+		{name: "Storage", downloader: FileDownloader{Storage: store}, file: "报告.txt", rangeHdr: "bytes=2-4", wantCode: http.StatusPartialContent, wantBody: "234"},
+		{name: "不支持Seek", downloader: FileDownloader{Storage: noSeekStorage{store}}, file: "报告.txt", rangeHdr: "bytes=2-4", wantCode: http.StatusOK, wantBody: "0123456789"},
+		{name: "Storage不存在", downloader: FileDownloader{Storage: store}, file: "a.txt", wantCode: http.StatusNotFound},
+		{
+			name:       "不支持Seek，If-None-Match",
+			downloader: FileDownloader{Storage: noSeekStorage{store}},
+			file:       "报告.txt",
+			headers:    map[string]string{"If-None-Match": `"other", ` + info.ETag},
+			wantCode:   http.StatusNotModified,
+		},
+		{
+			name:       "不支持Seek，If-Modified-Since",
+			downloader: FileDownloader{Storage: noSeekStorage{store}},
+			file:       "报告.txt",
+			headers:    map[string]string{"If-Modified-Since": info.ModTime.Add(time.Second).UTC().Format(http.TimeFormat)},
+			wantCode:   http.StatusNotModified,
+		},
+		{
+			name:       "不支持Seek，已经修改",
+			downloader: FileDownloader{Storage: noSeekStorage{store}},
+			file:       "报告.txt",
+			headers:    map[string]string{"If-None-Match": `"other"`, "If-Modified-Since": info.ModTime.Add(time.Second).UTC().Format(http.TimeFormat)},
+			wantCode:   http.StatusOK,
+			wantBody:   "0123456789",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			h := NewHTTPServer("tcp", ":8081")
+			h.GET("/download", tc.downloader.Handle())
+			target := "/download"
+			if tc.file != "" {
+				target += "?file=" + url.QueryEscape(tc.file)
+			}
+			req := httptest.NewRequest(http.MethodGet, target, nil)
+			if tc.rangeHdr != "" {
+				req.Header.Set("Range", tc.rangeHdr)
+			}
+			for key, val := range tc.headers {
+				req.Header.Set(key, val)
+			}
+			recorder := httptest.NewRecorder()
+			h.ServeHTTP(recorder, req)
+			assert.Equal(t, tc.wantCode, recorder.Code)
+			if tc.wantBody != "" {
+				assert.Equal(t, tc.wantBody, recorder.Body.String())
+			}
+			if tc.wantCode == http.StatusNotModified {
+				assert.Empty(t, recorder.Body.String())
+				assert.Equal(t, info.ETag, recorder.Header().Get("ETag"))
+			}
+			if tc.wantCode < 300 {
+				assert.Equal(t, `attachment; filename="__.txt"; filename*=UTF-8''%E6%8A%A5%E5%91%8A.txt`, recorder.Header().Get("Content-Disposition"))
+				assert.NotEmpty(t, recorder.Header().Get("ETag"))
+			}
+		})
+	}
+}
+
+func TestSafeFileName(t *testing.T) {
+	testCases := map[string]string{
+		"../../etc/passwd":                "passwd",
+		`C:\Users\tom\a.txt`:              "a.txt",
+		"我的 简历(1).pdf":                    "我的_简历_1_.pdf",
+		".htaccess":                       "htaccess",
+		"":                                "file",
+		"a\x00b.png":                      "a_b.png",
+		strings.Repeat("长", 100) + ".png": strings.Repeat("长", 65) + ".png",
+	}
+	for name, want := range testCases {
+		assert.Equal(t, want, SafeFileName(name), name)
+	}
+}
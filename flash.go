@@ -0,0 +1,93 @@
+package lr
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"strings"
+)
+
+// flashCookieName 保存flash消息的cookie
+const flashCookieName = "lr_flash"
+
+// defaultFlashCodec 没有通过FlashCodec配置时使用，key在进程启动时随机生成，
+// 进程重启或者多实例部署时其它实例写入的flash会被丢弃，这种情况需要配置固定的key
+var defaultFlashCodec CookieCodec = newRandomCookieSigner()
+
+func newRandomCookieSigner() *CookieSigner {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		panic("lr: 生成flash签名key失败: " + err.Error())
+	}
+	return NewCookieSigner(key)
+}
+
+// FlashCodec 设置flash消息cookie的签名或者加密方式，防止客户端伪造消息
+//
+//	lr.NewHTTPServer("tcp", ":8081", lr.FlashCodec(lr.NewCookieSigner(key)))
+func FlashCodec(codec CookieCodec) HTTPServerOptions {
+	return func(s *HTTPServer) {
+		s.flashCodec = codec
+	}
+}
+
+// flashCodec 没有通过FlashCodec配置时使用defaultFlashCodec
+func (c *Context) flashCodec() CookieCodec {
+	if c.flashCookieCodec != nil {
+		return c.flashCookieCodec
+	}
+	return defaultFlashCodec
+}
+
+// AddFlash 保存一条只在下一个请求中显示一次的消息，通常在重定向之前调用
+// 消息通过Set-Cookie保存，需要在响应写出之前调用
+//
+//	ctx.AddFlash("保存成功")
+//	_ = ctx.Redirect(http.StatusSeeOther, "/articles")
+func (c *Context) AddFlash(msg string) error {
+	c.outFlashes = append(c.outFlashes, msg)
+	data, err := json.Marshal(c.outFlashes)
+	if err != nil {
+		return err
+	}
+
+	// 多次调用时只保留最后一个Set-Cookie
+	header := c.Resp.Header()
+	cookies := header.Values("Set-Cookie")
+	header.Del("Set-Cookie")
+	for _, cookie := range cookies {
+		if !strings.HasPrefix(cookie, flashCookieName+"=") {
+			header.Add("Set-Cookie", cookie)
+		}
+	}
+	return c.SetSecureCookie(c.flashCodec(), flashCookieName, string(data))
+}
+
+// Flashes 读取上一个请求保存的消息，读取之后删除，同一个请求中多次调用返回相同的结果
+// 签名校验失败的cookie同样会被删除，不返回任何消息。
+//
+// 删除cookie需要写Set-Cookie响应头，响应已经写出之后(例如SSE、StreamTemplates已经刷新了缓冲区)
+// 只能读取消息，cookie不会被删除，下一个请求会再次看到这些消息；
+// 流式渲染的模版需要在页面开头使用flashes，或者在handler中提前调用Flashes
+func (c *Context) Flashes() []string {
+	if c.inFlashes != nil {
+		return *c.inFlashes
+	}
+
+	var msgs []string
+	c.inFlashes = &msgs
+	if _, err := c.Req.Cookie(flashCookieName); err != nil {
+		return msgs
+	}
+	if len(c.outFlashes) == 0 && c.Resp != nil && !c.committed {
+		_ = c.DeleteCookie(flashCookieName)
+	}
+
+	data, err := c.SecureCookie(c.flashCodec(), flashCookieName)
+	if err != nil {
+		return msgs
+	}
+	if err = json.Unmarshal([]byte(data), &msgs); err != nil {
+		msgs = nil
+	}
+	return msgs
+}
@@ -0,0 +1,438 @@
+package lr
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// PluralRule 根据数量返回复数形式的类别：zero、one、two、few、many或者other
+type PluralRule func(n int) string
+
+var (
+	pluralMu sync.RWMutex
+	// pluralRules 语言对应的复数规则，key是不带地区的语言，没有注册的语言只有other
+	pluralRules = map[string]PluralRule{
+		"en": pluralOne, "de": pluralOne, "nl": pluralOne, "sv": pluralOne, "da": pluralOne,
+		"nb": pluralOne, "no": pluralOne, "fi": pluralOne, "it": pluralOne, "es": pluralOne,
+		"pt": pluralOne, "el": pluralOne, "hu": pluralOne, "tr": pluralOne, "bg": pluralOne,
+		"fr": func(n int) string {
+			if n == 0 || n == 1 {
+				return "one"
+			}
+			return "other"
+		},
+		"ru": pluralSlavic, "uk": pluralSlavic, "be": pluralSlavic,
+		"pl": func(n int) string {
+			switch {
+			case n == 1:
+				return "one"
+			case n%10 >= 2 && n%10 <= 4 && (n%100 < 12 || n%100 > 14):
+				return "few"
+			default:
+				return "many"
+			}
+		},
+		"cs": pluralCzech, "sk": pluralCzech,
+		"ar": func(n int) string {
+			switch {
+			case n == 0:
+				return "zero"
+			case n == 1:
+				return "one"
+			case n == 2:
+				return "two"
+			case n%100 >= 3 && n%100 <= 10:
+				return "few"
+			case n%100 >= 11:
+				return "many"
+			default:
+				return "other"
+			}
+		},
+	}
+	pluralCategories = map[string]bool{"zero": true, "one": true, "two": true, "few": true, "many": true, "other": true}
+)
+
+func pluralOne(n int) string {
+	if n == 1 {
+		return "one"
+	}
+	return "other"
+}
+
+func pluralSlavic(n int) string {
+	switch {
+	case n%10 == 1 && n%100 != 11:
+		return "one"
+	case n%10 >= 2 && n%10 <= 4 && (n%100 < 12 || n%100 > 14):
+		return "few"
+	default:
+		return "many"
+	}
+}
+
+func pluralCzech(n int) string {
+	switch {
+	case n == 1:
+		return "one"
+	case n >= 2 && n <= 4:
+		return "few"
+	default:
+		return "other"
+	}
+}
+
+// RegisterPluralRule 注册或者覆盖语言的复数规则，lang是不带地区的语言，例如en
+func RegisterPluralRule(lang string, rule PluralRule) {
+	pluralMu.Lock()
+	defer pluralMu.Unlock()
+	pluralRules[strings.ToLower(lang)] = rule
+}
+
+func pluralCategory(locale string, n int) string {
+	if n < 0 {
+		n = -n
+	}
+	lang, _, _ := strings.Cut(locale, "-")
+	pluralMu.RLock()
+	rule, ok := pluralRules[strings.ToLower(lang)]
+	pluralMu.RUnlock()
+	if !ok {
+		return "other"
+	}
+	return rule(n)
+}
+
+// i18nMessage 一条消息的所有复数形式，没有复数的消息只有other
+type i18nMessage map[string]string
+
+// I18nOption I18nBundle的配置
+type I18nOption func(b *I18nBundle)
+
+// I18nQueryParam 从query参数中读取语言，默认为lang，为空时不读取
+func I18nQueryParam(name string) I18nOption {
+	return func(b *I18nBundle) {
+		b.queryParam = name
+	}
+}
+
+// I18nCookie 从cookie中读取语言，默认为lang，为空时不读取
+func I18nCookie(name string) I18nOption {
+	return func(b *I18nBundle) {
+		b.cookieName = name
+	}
+}
+
+// I18nBundle 所有语言的消息目录
+//
+// 消息文件是json或者yaml，文件名是语言，例如locales/zh-CN.json、locales/en.yaml；
+// 嵌套的key用.连接，值是只包含zero、one、two、few、many、other的对象时表示复数形式：
+//
+//	{
+//		"home": {"title": "Welcome, {name}"},
+//		"cart.items": {"one": "{count} item", "other": "{count} items"}
+//	}
+type I18nBundle struct {
+	defaultLocale string
+	queryParam    string
+	cookieName    string
+
+	mu       sync.RWMutex
+	catalogs map[string]map[string]i18nMessage
+}
+
+// NewI18nBundle defaultLocale是没有匹配到任何语言时使用的语言
+// 框架自己的消息(例如404、参数校验失败)内置了zh和en两种语言，消息目录中可以用同样的key覆盖
+func NewI18nBundle(defaultLocale string, opts ...I18nOption) *I18nBundle {
+	b := &I18nBundle{
+		defaultLocale: normalizeLocale(defaultLocale),
+		queryParam:    "lang",
+		cookieName:    "lang",
+		catalogs:      make(map[string]map[string]i18nMessage, 4),
+	}
+	for _, opt := range opts {
+		opt(b)
+	}
+	return b
+}
+
+// AddMessages 添加消息，msgs的格式和消息文件一致，已经存在的key会被覆盖
+func (b *I18nBundle) AddMessages(locale string, msgs map[string]any) error {
+	flat := make(map[string]i18nMessage, len(msgs))
+	if err := flattenMessages("", msgs, flat); err != nil {
+		return fmt.Errorf("lr: 语言 %s 的消息格式错误: %w", locale, err)
+	}
+
+	locale = normalizeLocale(locale)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	catalog, ok := b.catalogs[locale]
+	if !ok {
+		catalog = make(map[string]i18nMessage, len(flat))
+		b.catalogs[locale] = catalog
+	}
+	for key, msg := range flat {
+		catalog[key] = msg
+	}
+	return nil
+}
+
+// LoadFS 加载dir目录下所有的.json、.yaml和.yml消息文件
+//
+//	//go:embed locales
+//	var locales embed.FS
+//
+//	err := bundle.LoadFS(locales, "locales")
+func (b *I18nBundle) LoadFS(fsys fs.FS, dir string) error {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return fmt.Errorf("lr: 加载消息目录失败: %w", err)
+	}
+	for _, entry := range entries {
+		ext := path.Ext(entry.Name())
+		if entry.IsDir() || ext != ".json" && ext != ".yaml" && ext != ".yml" {
+			continue
+		}
+		file := path.Join(dir, entry.Name())
+		content, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return err
+		}
+		msgs := make(map[string]any)
+		if ext == ".json" {
+			err = json.Unmarshal(content, &msgs)
+		} else {
+			err = yaml.Unmarshal(content, &msgs)
+		}
+		if err != nil {
+			return fmt.Errorf("lr: 解析消息文件 %s 失败: %w", file, err)
+		}
+		if err = b.AddMessages(strings.TrimSuffix(entry.Name(), ext), msgs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LoadDir 加载本地目录中的消息文件
+func (b *I18nBundle) LoadDir(dir string) error {
+	return b.LoadFS(os.DirFS(dir), ".")
+}
+
+// Locales 所有有消息的语言，按照字母排序
+func (b *I18nBundle) Locales() []string {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	res := make([]string, 0, len(b.catalogs))
+	for locale := range b.catalogs {
+		res = append(res, locale)
+	}
+	sort.Strings(res)
+	return res
+}
+
+// Translate 翻译key，args是key、value交替的参数，消息中的{name}会被替换为参数的值；
+// 参数中有count时根据复数规则选择消息的形式。
+// 依次查找locale、去掉地区之后的语言和默认语言，都没有时返回key
+func (b *I18nBundle) Translate(locale, key string, args ...any) string {
+	msg, ok := b.Lookup(locale, key, args...)
+	if !ok {
+		return key
+	}
+	return msg
+}
+
+// Lookup 和Translate相同，找不到消息时返回false
+func (b *I18nBundle) Lookup(locale, key string, args ...any) (string, bool) {
+	for _, candidate := range b.fallbacks(normalizeLocale(locale)) {
+		b.mu.RLock()
+		msg, ok := b.catalogs[candidate][key]
+		b.mu.RUnlock()
+		if !ok {
+			msg, ok = frameworkCatalogs[candidate][key]
+		}
+		if ok {
+			return formatMessage(candidate, msg, args), true
+		}
+	}
+	return "", false
+}
+
+// fallbacks zh-Hant-TW依次查找zh-Hant-TW、zh-Hant、zh和默认语言
+func (b *I18nBundle) fallbacks(locale string) []string {
+	res := localeChain(locale)
+	if locale != b.defaultLocale {
+		res = append(res, localeChain(b.defaultLocale)...)
+	}
+	return res
+}
+
+// match 在支持的语言中找到最接近的语言，找不到时返回false
+// 先按照locale、去掉地区之后的语言匹配，例如en-US可以匹配en；再匹配同一种语言的其他地区，例如zh可以匹配zh-CN。
+// 没有加载任何消息时只支持框架内置的语言
+func (b *I18nBundle) match(locale string) (string, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	supported := make(map[string]bool, len(b.catalogs))
+	for candidate := range b.catalogs {
+		supported[candidate] = true
+	}
+	if len(supported) == 0 {
+		for candidate := range frameworkCatalogs {
+			supported[candidate] = true
+		}
+	}
+
+	chain := localeChain(normalizeLocale(locale))
+	for _, candidate := range chain {
+		if supported[candidate] {
+			return candidate, true
+		}
+	}
+	if len(chain) == 0 {
+		return "", false
+	}
+	lang := chain[len(chain)-1]
+	locales := make([]string, 0, len(supported))
+	for candidate := range supported {
+		locales = append(locales, candidate)
+	}
+	sort.Strings(locales)
+	for _, candidate := range locales {
+		if strings.HasPrefix(candidate, lang+"-") {
+			return candidate, true
+		}
+	}
+	return "", false
+}
+
+// localeChain 从具体到宽泛的语言标签，例如zh-Hant-TW、zh-Hant、zh
+func localeChain(locale string) []string {
+	var res []string
+	for locale != "" {
+		res = append(res, locale)
+		idx := strings.LastIndexByte(locale, '-')
+		if idx < 0 {
+			break
+		}
+		locale = locale[:idx]
+	}
+	return res
+}
+
+func formatMessage(locale string, msg i18nMessage, args []any) string {
+	if len(args) == 0 {
+		return msg["other"]
+	}
+
+	pairs := make([]string, 0, len(args))
+	text, hasCount := "", false
+	for i := 0; i+1 < len(args); i += 2 {
+		key := fmt.Sprint(args[i])
+		pairs = append(pairs, "{"+key+"}", fmt.Sprint(args[i+1]))
+		if key != "count" {
+			continue
+		}
+		if n, ok := pluralCount(args[i+1]); ok {
+			hasCount = true
+			text = msg[pluralCategory(locale, n)]
+			// 数量为0时优先使用zero，即使语言的规则没有zero
+			if n == 0 && msg["zero"] != "" {
+				text = msg["zero"]
+			}
+		}
+	}
+	if !hasCount || text == "" {
+		text = msg["other"]
+	}
+	return strings.NewReplacer(pairs...).Replace(text)
+}
+
+func pluralCount(val any) (int, bool) {
+	v := reflect.ValueOf(val)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return int(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return int(v.Float()), true
+	default:
+		return 0, false
+	}
+}
+
+// flattenMessages 把嵌套的消息展开为用.连接的key
+func flattenMessages(prefix string, msgs map[string]any, res map[string]i18nMessage) error {
+	for key, val := range msgs {
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+		// 代码中添加消息时经常使用map[string]string
+		if m, ok := val.(map[string]string); ok {
+			nested := make(map[string]any, len(m))
+			for k, s := range m {
+				nested[k] = s
+			}
+			val = nested
+		}
+		switch v := val.(type) {
+		case string:
+			res[key] = i18nMessage{"other": v}
+		case map[string]any:
+			if msg, ok := pluralMessage(v); ok {
+				res[key] = msg
+				continue
+			}
+			if err := flattenMessages(key, v, res); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("%s 的值必须是字符串或者对象", key)
+		}
+	}
+	return nil
+}
+
+// pluralMessage 只包含复数类别并且有other的对象是一条复数消息
+func pluralMessage(val map[string]any) (i18nMessage, bool) {
+	if _, ok := val["other"]; !ok {
+		return nil, false
+	}
+	msg := make(i18nMessage, len(val))
+	for category, form := range val {
+		s, ok := form.(string)
+		if !ok || !pluralCategories[category] {
+			return nil, false
+		}
+		msg[category] = s
+	}
+	return msg, true
+}
+
+// normalizeLocale 统一语言标签的格式，例如zh_cn转换为zh-CN，zh-hant-tw转换为zh-Hant-TW
+func normalizeLocale(locale string) string {
+	parts := strings.Split(strings.ReplaceAll(strings.TrimSpace(locale), "_", "-"), "-")
+	for i, part := range parts {
+		switch {
+		case i == 0:
+			parts[i] = strings.ToLower(part)
+		case len(part) == 4:
+			parts[i] = strings.ToUpper(part[:1]) + strings.ToLower(part[1:])
+		case len(part) == 2 || len(part) == 3 && part[0] >= '0' && part[0] <= '9':
+			parts[i] = strings.ToUpper(part)
+		default:
+			parts[i] = strings.ToLower(part)
+		}
+	}
+	return strings.Join(parts, "-")
+}
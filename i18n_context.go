@@ -0,0 +1,196 @@
+package lr
+
+import (
+	"errors"
+)
+
+// frameworkCatalogs 框架自己的消息，key以lr.开头，没有设置I18n时使用代码中原来的默认消息
+var frameworkCatalogs = map[string]map[string]i18nMessage{
+	"zh": {
+		"lr.not_found":           {"other": "找不到页面"},
+		"lr.method_not_allowed":  {"other": "不支持的请求方法"},
+		"lr.bad_request":         {"other": "请求参数错误"},
+		"lr.not_acceptable":      {"other": "没有可以满足Accept的响应格式，支持的类型: {types}"},
+		"lr.upload_failed":       {"other": "上传失败"},
+		"lr.upload_no_file":      {"other": "没有上传文件"},
+		"lr.upload_too_large":    {"other": "上传的文件超过了大小限制"},
+		"lr.upload_too_many":     {"other": "上传的文件数量超过了限制"},
+		"lr.upload_type":         {"other": "不允许上传这种类型的文件"},
+		"lr.upload_conflict":     {"other": "同名文件已经存在"},
+		"lr.file_not_found":      {"other": "找不到目标文件"},
+		"lr.key_not_found":       {"other": "key不存在"},
+		"lr.value_required":      {"other": "值不能为空"},
+		"lr.request_too_large":   {"other": "请求体超过了大小限制"},
+		"lr.json_trailing_data":  {"other": "请求体中包含多个json值"},
+		"lr.validation.required": {"other": "不能为空"},
+		"lr.validation.min":      {"other": "不能小于{param}"},
+		"lr.validation.max":      {"other": "不能大于{param}"},
+		"lr.validation.len":      {"other": "长度必须为{param}"},
+		"lr.validation.gt":       {"other": "必须大于{param}"},
+		"lr.validation.gte":      {"other": "不能小于{param}"},
+		"lr.validation.lt":       {"other": "必须小于{param}"},
+		"lr.validation.lte":      {"other": "不能大于{param}"},
+		"lr.validation.eq":       {"other": "必须等于{param}"},
+		"lr.validation.ne":       {"other": "不能等于{param}"},
+		"lr.validation.oneof":    {"other": "必须是[{param}]中的一个"},
+		"lr.validation.email":    {"other": "不是合法的邮箱地址"},
+		"lr.validation.url":      {"other": "不是合法的URL"},
+		"lr.validation.alpha":    {"other": "只能包含字母"},
+		"lr.validation.alphanum": {"other": "只能包含字母和数字"},
+		"lr.validation.numeric":  {"other": "必须是数字"},
+		"lr.validation.uuid":     {"other": "不是合法的UUID"},
+	},
+	"en": {
+		"lr.not_found":           {"other": "Not Found"},
+		"lr.method_not_allowed":  {"other": "Method Not Allowed"},
+		"lr.bad_request":         {"other": "Invalid request parameters"},
+		"lr.not_acceptable":      {"other": "Not Acceptable, supported types: {types}"},
+		"lr.upload_failed":       {"other": "Upload failed"},
+		"lr.upload_no_file":      {"other": "No file was uploaded"},
+		"lr.upload_too_large":    {"other": "The uploaded file is too large"},
+		"lr.upload_too_many":     {"other": "Too many files were uploaded"},
+		"lr.upload_type":         {"other": "This type of file is not allowed"},
+		"lr.upload_conflict":     {"other": "A file with the same name already exists"},
+		"lr.file_not_found":      {"other": "File not found"},
+		"lr.key_not_found":       {"other": "Key not found"},
+		"lr.value_required":      {"other": "Value is required"},
+		"lr.request_too_large":   {"other": "Request body is too large"},
+		"lr.json_trailing_data":  {"other": "Request body contains more than one JSON value"},
+		"lr.validation.required": {"other": "is required"},
+		"lr.validation.min":      {"other": "must be at least {param}"},
+		"lr.validation.max":      {"other": "must be at most {param}"},
+		"lr.validation.len":      {"other": "must have length {param}"},
+		"lr.validation.gt":       {"other": "must be greater than {param}"},
+		"lr.validation.gte":      {"other": "must be at least {param}"},
+		"lr.validation.lt":       {"other": "must be less than {param}"},
+		"lr.validation.lte":      {"other": "must be at most {param}"},
+		"lr.validation.eq":       {"other": "must be equal to {param}"},
+		"lr.validation.ne":       {"other": "must not be equal to {param}"},
+		"lr.validation.oneof":    {"other": "must be one of [{param}]"},
+		"lr.validation.email":    {"other": "is not a valid email address"},
+		"lr.validation.url":      {"other": "is not a valid URL"},
+		"lr.validation.alpha":    {"other": "may only contain letters"},
+		"lr.validation.alphanum": {"other": "may only contain letters and digits"},
+		"lr.validation.numeric":  {"other": "must be numeric"},
+		"lr.validation.uuid":     {"other": "is not a valid UUID"},
+	},
+}
+
+// frameworkErrors 可以本地化的框架错误，按照顺序使用errors.Is匹配
+var frameworkErrors = []struct {
+	err error
+	key string
+}{
+	{err: ErrKeyNotFound, key: "lr.key_not_found"},
+	{err: ErrValueRequired, key: "lr.value_required"},
+	{err: ErrRequestTooLarge, key: "lr.request_too_large"},
+	{err: ErrJSONTrailingData, key: "lr.json_trailing_data"},
+	{err: ErrUploadNoFile, key: "lr.upload_no_file"},
+	{err: ErrUploadTooLarge, key: "lr.upload_too_large"},
+	{err: ErrUploadTooManyFiles, key: "lr.upload_too_many"},
+	{err: ErrUploadTypeNotAllowed, key: "lr.upload_type"},
+	{err: ErrUploadConflict, key: "lr.upload_conflict"},
+}
+
+// I18n 开启国际化，Context.T和模版中的T函数使用bundle翻译，框架自己的错误信息也会按照请求的语言返回
+//
+//	bundle := lr.NewI18nBundle("zh-CN")
+//	if err := bundle.LoadDir("locales"); err != nil {
+//		panic(err)
+//	}
+//	server := lr.NewHTTPServer("tcp", ":8081", lr.I18n(bundle))
+func I18n(bundle *I18nBundle) HTTPServerOptions {
+	return func(s *HTTPServer) {
+		s.i18n = bundle
+	}
+}
+
+// Locale 当前请求的语言，依次从query参数、cookie和Accept-Language中检测，都没有匹配时使用默认语言，
+// 同时按照检查过的来源设置Vary: Cookie、Accept-Language。没有开启I18n时返回空字符串
+func (c *Context) Locale() string {
+	if c.locale != "" || c.i18n == nil {
+		return c.locale
+	}
+	c.locale = c.detectLocale()
+	return c.locale
+}
+
+// SetLocale 指定当前请求的语言，例如使用用户资料中保存的语言
+func (c *Context) SetLocale(locale string) {
+	c.locale = normalizeLocale(locale)
+}
+
+// T 按照当前请求的语言翻译，args是key、value交替的参数，见I18nBundle.Translate
+//
+//	ctx.T("cart.items", "count", 3)
+func (c *Context) T(key string, args ...any) string {
+	if c.i18n == nil {
+		return key
+	}
+	return c.i18n.Translate(c.Locale(), key, args...)
+}
+
+// LocalizeError 框架定义的错误(例如ErrKeyNotFound)按照当前请求的语言返回错误信息，其他错误返回err.Error()
+func (c *Context) LocalizeError(err error) string {
+	if c.i18n != nil {
+		for _, fe := range frameworkErrors {
+			if !errors.Is(err, fe.err) {
+				continue
+			}
+			if msg, ok := c.i18n.Lookup(c.Locale(), fe.key); ok {
+				return msg
+			}
+		}
+	}
+	return err.Error()
+}
+
+// localize 框架自己的消息，没有开启I18n或者没有对应的翻译时使用def
+func (c *Context) localize(key, def string, args ...any) string {
+	if c.i18n == nil {
+		return def
+	}
+	if msg, ok := c.i18n.Lookup(c.Locale(), key, args...); ok {
+		return msg
+	}
+	return def
+}
+
+func (c *Context) detectLocale() string {
+	b := c.i18n
+	if c.Req != nil {
+		if b.queryParam != "" {
+			if val, err := c.QueryValue(b.queryParam).String(); err == nil {
+				if locale, ok := b.match(val); ok {
+					return locale
+				}
+			}
+		}
+		// 检查过的请求头都会影响响应的内容，缓存需要区分；query参数是URL的一部分，不需要Vary。
+		// Vary: Cookie会让共享缓存几乎无法命中，需要缓存的页面可以只使用query参数或者路径区分语言
+		if b.cookieName != "" {
+			c.addVary("Cookie")
+			if cookie, err := c.Req.Cookie(b.cookieName); err == nil {
+				if locale, ok := b.match(cookie.Value); ok {
+					return locale
+				}
+			}
+		}
+		c.addVary("Accept-Language")
+		for _, lang := range parseQualityValues(c.Req.Header.Get("Accept-Language")) {
+			if lang.q == 0 || lang.value == "*" {
+				continue
+			}
+			if locale, ok := b.match(lang.value); ok {
+				return locale
+			}
+		}
+	}
+	return b.defaultLocale
+}
+
+func (c *Context) addVary(header string) {
+	if c.Resp != nil {
+		c.Resp.Header().Add("Vary", header)
+	}
+}
@@ -0,0 +1,243 @@
+package lr
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testI18nBundle(t *testing.T) *I18nBundle {
+	bundle := NewI18nBundle("zh-CN")
+	require.NoError(t, bundle.LoadFS(fstest.MapFS{
+		"locales/zh-CN.json": {Data: []byte(`{
+			"home": {"title": "欢迎，{name}"},
+			"cart.items": "购物车里有{count}件商品",
+			"only.zh": "只有中文"
+		}`)},
+		"locales/en.yaml": {Data: []byte(`
+home:
+  title: "Welcome, {name}"
+cart.items:
+  zero: "Your cart is empty"
+  one: "{count} item"
+  other: "{count} items"
+lr.not_found: "Nothing here"
+`)},
+		"locales/ru.json": {Data: []byte(`{
+			"cart.items": {"one": "{count} товар", "few": "{count} товара", "many": "{count} товаров", "other": "{count} товара"}
+		}`)},
+		"locales/README.md": {Data: []byte(`不是消息文件`)},
+	}, "locales"))
+	return bundle
+}
+
+func TestI18nBundle_Translate(t *testing.T) {
+	bundle := testI18nBundle(t)
+	assert.Equal(t, []string{"en", "ru", "zh-CN"}, bundle.Locales())
+
+	testCases := []struct {
+		name   string
+		locale string
+		key    string
+		args   []any
+		want   string
+	}{
+		{name: "嵌套的key", locale: "en", key: "home.title", args: []any{"name", "Tom"}, want: "Welcome, Tom"},
+		{name: "复数one", locale: "en", key: "cart.items", args: []any{"count", 1}, want: "1 item"},
+		{name: "复数other", locale: "en", key: "cart.items", args: []any{"count", int64(5)}, want: "5 items"},
+		{name: "复数zero", locale: "en", key: "cart.items", args: []any{"count", 0}, want: "Your cart is empty"},
+		{name: "中文没有复数", locale: "zh-CN", key: "cart.items", args: []any{"count", 1}, want: "购物车里有1件商品"},
+		{name: "俄语one", locale: "ru", key: "cart.items", args: []any{"count", 21}, want: "21 товар"},
+		{name: "俄语few", locale: "ru", key: "cart.items", args: []any{"count", 3}, want: "3 товара"},
+		{name: "俄语many", locale: "ru", key: "cart.items", args: []any{"count", 11}, want: "11 товаров"},
+		{name: "去掉地区", locale: "en_us", key: "home.title", args: []any{"name", "Tom"}, want: "Welcome, Tom"},
+		{name: "使用默认语言", locale: "en", key: "only.zh", want: "只有中文"},
+		{name: "不存在", locale: "en", key: "missing", want: "missing"},
+		{name: "框架内置的消息", locale: "en", key: "lr.bad_request", want: "Invalid request parameters"},
+		{name: "覆盖框架的消息", locale: "en", key: "lr.not_found", want: "Nothing here"},
+		{name: "默认语言使用框架内置的中文", locale: "fr", key: "lr.not_found", want: "找不到页面"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, bundle.Translate(tc.locale, tc.key, tc.args...))
+		})
+	}
+
+	require.NoError(t, bundle.AddMessages("en", map[string]any{
+		"mail":  map[string]string{"subject": "Hi {name}"},
+		"inbox": map[string]string{"one": "{count} mail", "other": "{count} mails"},
+	}))
+	assert.Equal(t, "Hi Tom", bundle.Translate("en", "mail.subject", "name", "Tom"))
+	assert.Equal(t, "2 mails", bundle.Translate("en", "inbox", "count", 2))
+
+	err := bundle.AddMessages("en", map[string]any{"bad": []int{1}})
+	assert.EqualError(t, err, "lr: 语言 en 的消息格式错误: bad 的值必须是字符串或者对象")
+	err = bundle.LoadFS(fstest.MapFS{"en.json": {Data: []byte(`{`)}}, ".")
+	assert.ErrorContains(t, err, "lr: 解析消息文件 en.json 失败")
+}
+
+func TestPluralRules(t *testing.T) {
+	testCases := []struct {
+		locale string
+		counts map[int]string
+	}{
+		{locale: "en-US", counts: map[int]string{0: "other", 1: "one", 2: "other"}},
+		{locale: "fr", counts: map[int]string{0: "one", 1: "one", 2: "other"}},
+		{locale: "pl", counts: map[int]string{1: "one", 3: "few", 13: "many", 22: "few", 25: "many"}},
+		{locale: "cs", counts: map[int]string{1: "one", 4: "few", 5: "other"}},
+		{locale: "ar", counts: map[int]string{0: "zero", 2: "two", 105: "few", 111: "many", 100: "other"}},
+		{locale: "zh-CN", counts: map[int]string{1: "other"}},
+	}
+	for _, tc := range testCases {
+		for n, want := range tc.counts {
+			assert.Equal(t, want, pluralCategory(tc.locale, n), "%s %d", tc.locale, n)
+		}
+	}
+
+	RegisterPluralRule("xx", func(n int) string { return "few" })
+	assert.Equal(t, "few", pluralCategory("xx-YY", 1))
+}
+
+func TestContext_Locale(t *testing.T) {
+	bundle := testI18nBundle(t)
+
+	testCases := []struct {
+		name     string
+		req      func() *http.Request
+		want     string
+		wantVary []string
+	}{
+		{
+			name: "query优先",
+			req: func() *http.Request {
+				req := httptest.NewRequest(http.MethodGet, "/?lang=ru", nil)
+				req.AddCookie(&http.Cookie{Name: "lang", Value: "en"})
+				return req
+			},
+			want: "ru",
+		},
+		{
+			name: "cookie",
+			req: func() *http.Request {
+				req := httptest.NewRequest(http.MethodGet, "/?lang=xx", nil)
+				req.AddCookie(&http.Cookie{Name: "lang", Value: "en"})
+				return req
+			},
+			want:     "en",
+			wantVary: []string{"Cookie"},
+		},
+		{
+			name: "Accept-Language按照q值",
+			req: func() *http.Request {
+				req := httptest.NewRequest(http.MethodGet, "/", nil)
+				req.Header.Set("Accept-Language", "de;q=0.9, ru;q=0.5, en-GB;q=0.8")
+				return req
+			},
+			want:     "en",
+			wantVary: []string{"Cookie", "Accept-Language"},
+		},
+		{
+			name: "匹配同一种语言的其他地区",
+			req: func() *http.Request {
+				req := httptest.NewRequest(http.MethodGet, "/", nil)
+				req.Header.Set("Accept-Language", "zh-TW, en;q=0.1")
+				return req
+			},
+			want:     "zh-CN",
+			wantVary: []string{"Cookie", "Accept-Language"},
+		},
+		{
+			name: "默认语言",
+			req: func() *http.Request {
+				req := httptest.NewRequest(http.MethodGet, "/", nil)
+				req.Header.Set("Accept-Language", "de, en;q=0")
+				return req
+			},
+			want:     "zh-CN",
+			wantVary: []string{"Cookie", "Accept-Language"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			ctx := &Context{Req: tc.req(), Resp: recorder, i18n: bundle}
+			assert.Equal(t, tc.want, ctx.Locale())
+			assert.Equal(t, tc.wantVary, recorder.Header().Values("Vary"))
+		})
+	}
+
+	ctx := &Context{Req: httptest.NewRequest(http.MethodGet, "/", nil), i18n: bundle}
+	ctx.SetLocale("en_us")
+	assert.Equal(t, "en-US", ctx.Locale())
+	assert.Equal(t, "Welcome, Tom", ctx.T("home.title", "name", "Tom"))
+	assert.Equal(t, "home.title", (&Context{}).T("home.title"))
+	assert.Equal(t, "", (&Context{}).Locale())
+}
+
+func TestI18n_FrameworkMessages(t *testing.T) {
+	type signUp struct {
+		Name string `json:"name" validate:"required"`
+	}
+	newServer := func(opts ...HTTPServerOptions) *HTTPServer {
+		h := NewHTTPServer("tcp", ":8081", opts...)
+		h.GET("/sign-up", func(ctx *Context) {
+			_ = ctx.RespBadRequest(Validate(&signUp{}))
+		})
+		h.GET("/key", func(ctx *Context) {
+			_, err := ctx.QueryValue("id").Required().String()
+			_ = ctx.RespBadRequest(err)
+		})
+		return h
+	}
+	serve := func(h *HTTPServer, path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Accept-Language", "en-US")
+		recorder := httptest.NewRecorder()
+		h.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	// 开启I18n之后按照请求的语言返回
+	h := newServer(I18n(NewI18nBundle("zh-CN")))
+	assert.Equal(t, "Not Found", serve(h, "/missing").Body.String())
+
+	var resp badRequestResp
+	require.NoError(t, json.Unmarshal(serve(h, "/sign-up").Body.Bytes(), &resp))
+	assert.Equal(t, "Invalid request parameters", resp.Message)
+	require.Len(t, resp.Errors, 1)
+	assert.Equal(t, "is required", resp.Errors[0].Message)
+
+	require.NoError(t, json.Unmarshal(serve(h, "/key").Body.Bytes(), &resp))
+	assert.Equal(t, "Key not found", resp.Message)
+
+	// 没有开启时保持原来的信息
+	h = newServer()
+	assert.Equal(t, "NOT FOUND", serve(h, "/missing").Body.String())
+	resp = badRequestResp{}
+	require.NoError(t, json.Unmarshal(serve(h, "/sign-up").Body.Bytes(), &resp))
+	assert.Equal(t, "请求参数错误", resp.Message)
+	assert.Equal(t, "不能为空", resp.Errors[0].Message)
+}
+
+func TestI18n_Template(t *testing.T) {
+	engine, err := NewFSTemplateEngine(fstest.MapFS{
+		"cart.gohtml": {Data: []byte(`{{locale}}: {{T "cart.items" "count" .count}}`)},
+	})
+	require.NoError(t, err)
+
+	h := NewHTTPServer("tcp", ":8081", Template(engine), I18n(testI18nBundle(t)))
+	h.GET("/cart", func(ctx *Context) {
+		_ = ctx.Render("cart", map[string]any{"count": 2})
+	})
+
+	recorder := httptest.NewRecorder()
+	h.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/cart?lang=en", nil))
+	assert.Equal(t, "en: 2 items", recorder.Body.String())
+}
@@ -0,0 +1,131 @@
+package lr
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+var (
+	ErrRequestTooLarge  = errors.New("lr: 请求体超过了大小限制")
+	ErrResponseTooLarge = errors.New("lr: 响应体超过了大小限制")
+	ErrJSONTrailingData = errors.New("lr: 请求体中包含多个json值")
+)
+
+// JSONCodec json的编解码实现，可以替换为性能更好的第三方库
+type JSONCodec interface {
+	Marshal(val any) ([]byte, error)
+	NewDecoder(r io.Reader) JSONDecoder
+}
+
+// JSONDecoder 和encoding/json.Decoder的方法保持一致，*json.Decoder可以直接作为实现
+type JSONDecoder interface {
+	Decode(val any) error
+	DisallowUnknownFields()
+	UseNumber()
+}
+
+// StdJSONCodec 基于encoding/json的默认实现
+type StdJSONCodec struct{}
+
+func (StdJSONCodec) Marshal(val any) ([]byte, error) {
+	return json.Marshal(val)
+}
+
+func (StdJSONCodec) NewDecoder(r io.Reader) JSONDecoder {
+	return json.NewDecoder(r)
+}
+
+// JSONConfig json编解码的配置，绑定请求和返回响应都会使用
+type JSONConfig struct {
+	// 编解码实现，为nil时使用StdJSONCodec
+	Codec JSONCodec
+	// 请求中出现结构体没有的字段时返回错误
+	DisallowUnknownFields bool
+	// 数字解码为json.Number而不是float64
+	UseNumber bool
+	// 请求体中第一个json值之后还有其他数据时返回ErrJSONTrailingData
+	DisallowTrailingData bool
+	// 请求体的最大字节数，0表示不限制
+	MaxRequestBytes int64
+	// json响应的最大字节数，0表示不限制
+	MaxResponseBytes int64
+}
+
+// JSON 配置json编解码，json格式的内容协商也会使用同一个Codec
+func JSON(cfg JSONConfig) HTTPServerOptions {
+	return func(s *HTTPServer) {
+		if cfg.Codec == nil {
+			cfg.Codec = StdJSONCodec{}
+		}
+		s.jsonCfg = cfg
+		s.RegisterRenderer(JSONRenderer{Codec: cfg.Codec})
+	}
+}
+
+// jsonConfig 没有通过HTTPServer创建的Context使用默认配置
+func (c *Context) jsonConfig() JSONConfig {
+	if c.jsonCfg == nil || c.jsonCfg.Codec == nil {
+		return JSONConfig{Codec: StdJSONCodec{}}
+	}
+	return *c.jsonCfg
+}
+
+// limitBody 按照配置限制请求体的大小
+func (c *Context) limitBody(limit int64) io.Reader {
+	if limit <= 0 {
+		return c.Req.Body
+	}
+	return http.MaxBytesReader(c.Resp, c.Req.Body, limit)
+}
+
+// decodeJSON 按照JSONConfig的严格模式解码请求体
+func (c *Context) decodeJSON(val any) error {
+	cfg := c.jsonConfig()
+	decoder := cfg.Codec.NewDecoder(c.limitBody(cfg.MaxRequestBytes))
+	if cfg.DisallowUnknownFields {
+		decoder.DisallowUnknownFields()
+	}
+	if cfg.UseNumber {
+		decoder.UseNumber()
+	}
+
+	if err := decoder.Decode(val); err != nil {
+		return wrapBodyError(err)
+	}
+	if cfg.DisallowTrailingData {
+		var extra json.RawMessage
+		if err := decoder.Decode(&extra); err != io.EOF {
+			if err != nil {
+				if wrapped := wrapBodyError(err); errors.Is(wrapped, ErrRequestTooLarge) {
+					return wrapped
+				}
+			}
+			return ErrJSONTrailingData
+		}
+	}
+	return nil
+}
+
+// marshalJSON 按照JSONConfig编码响应
+func (c *Context) marshalJSON(val any) ([]byte, error) {
+	cfg := c.jsonConfig()
+	data, err := cfg.Codec.Marshal(val)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.MaxResponseBytes > 0 && int64(len(data)) > cfg.MaxResponseBytes {
+		return nil, ErrResponseTooLarge
+	}
+	return data, nil
+}
+
+func wrapBodyError(err error) error {
+	var maxErr *http.MaxBytesError
+	if errors.As(err, &maxErr) {
+		return fmt.Errorf("%w: 限制为%d字节", ErrRequestTooLarge, maxErr.Limit)
+	}
+	return err
+}
@@ -0,0 +1,132 @@
+package lr
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingCodec 记录调用次数，验证Codec确实被使用
+type countingCodec struct {
+	StdJSONCodec
+	marshal int
+}
+
+func (c *countingCodec) Marshal(val any) ([]byte, error) {
+	c.marshal++
+	return c.StdJSONCodec.Marshal(val)
+}
+
+func TestContext_BindJsonStrict(t *testing.T) {
+	type user struct {
+		Name string `json:"name"`
+		Age  any    `json:"age"`
+	}
+
+	testCases := []struct {
+		name    string
+		cfg     JSONConfig
+		body    string
+		want    user
+		wantErr error
+	}{
+		{
+			name: "默认",
+			body: `{"name":"Tom","age":18,"extra":1} {}`,
+			want: user{Name: "Tom", Age: float64(18)},
+		},
+		{
+			name:    "未知字段",
+			cfg:     JSONConfig{DisallowUnknownFields: true},
+			body:    `{"name":"Tom","extra":1}`,
+			wantErr: assert.AnError,
+		},
+		{
+			name: "UseNumber",
+			cfg:  JSONConfig{UseNumber: true},
+			body: `{"age":18}`,
+			want: user{Age: json.Number("18")},
+		},
+		{
+			name:    "多余数据",
+			cfg:     JSONConfig{DisallowTrailingData: true},
+			body:    `{"name":"Tom"} {"name":"Jerry"}`,
+			wantErr: ErrJSONTrailingData,
+		},
+		{
+			name: "结尾空白",
+			cfg:  JSONConfig{DisallowTrailingData: true},
+			body: "{\"name\":\"Tom\"}\n\t ",
+			want: user{Name: "Tom"},
+		},
+		{
+			name:    "请求体过大",
+			cfg:     JSONConfig{MaxRequestBytes: 8},
+			body:    `{"name":"Tom"}`,
+			wantErr: ErrRequestTooLarge,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.cfg.Codec == nil {
+				tc.cfg.Codec = StdJSONCodec{}
+			}
+			ctx := &Context{
+				Req:     httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tc.body)),
+				Resp:    httptest.NewRecorder(),
+				jsonCfg: &tc.cfg,
+			}
+			var val user
+			err := ctx.BindJson(&val)
+			switch {
+			case tc.wantErr == assert.AnError:
+				assert.Error(t, err)
+			case tc.wantErr != nil:
+				assert.ErrorIs(t, err, tc.wantErr)
+			default:
+				require.NoError(t, err)
+				assert.Equal(t, tc.want, val)
+			}
+		})
+	}
+}
+
+func TestJSONCodec(t *testing.T) {
+	codec := &countingCodec{}
+	h := NewHTTPServer("tcp", ":8081", JSON(JSONConfig{Codec: codec, MaxResponseBytes: 16}))
+	h.GET("/small", func(ctx *Context) {
+		require.NoError(t, ctx.RespJsonOK(map[string]int{"id": 1}))
+	})
+	h.GET("/negotiate", func(ctx *Context) {
+		require.NoError(t, ctx.Negotiate(http.StatusOK, []int{1}))
+	})
+	h.GET("/large", func(ctx *Context) {
+		err := ctx.RespJSON(http.StatusOK, strings.Repeat("a", 32))
+		assert.Equal(t, ErrResponseTooLarge, err)
+	})
+
+	for _, path := range []string{"/small", "/negotiate", "/large"} {
+		recorder := httptest.NewRecorder()
+		h.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+	}
+	assert.Equal(t, 3, codec.marshal)
+}
+
+func TestContext_BindXMLLimit(t *testing.T) {
+	ctx := &Context{
+		Req:     httptest.NewRequest(http.MethodPost, "/", io.NopCloser(strings.NewReader("<a><b>1</b></a>"))),
+		Resp:    httptest.NewRecorder(),
+		jsonCfg: &JSONConfig{Codec: StdJSONCodec{}, MaxRequestBytes: 4},
+	}
+	var val struct {
+		B int `xml:"b"`
+	}
+	assert.ErrorIs(t, ctx.BindXML(&val), ErrRequestTooLarge)
+}
@@ -0,0 +1,379 @@
+package lr
+
+import (
+	"bytes"
+	"context"
+	"html"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// MarkdownEngine 先用text/template执行模版，再把结果从Markdown转换成HTML
+//
+// 支持标题、段落、强调、行内代码、代码块、引用、列表、分隔线、链接和图片，
+// 模版和数据中的HTML都会被转义，链接只允许http、https、mailto和相对地址
+type MarkdownEngine struct {
+	T *template.Template
+}
+
+func (m MarkdownEngine) Render(ctx context.Context, tplName string, data any) ([]byte, error) {
+	bs := &bytes.Buffer{}
+	if err := m.T.ExecuteTemplate(bs, tplName, data); err != nil {
+		return nil, err
+	}
+	return MarkdownToHTML(bs.Bytes()), nil
+}
+
+// MarkdownToHTML 把Markdown转换成HTML
+func MarkdownToHTML(src []byte) []byte {
+	text := strings.ReplaceAll(string(src), "\r\n", "\n")
+	sb := &strings.Builder{}
+	renderMarkdownBlocks(sb, strings.Split(text, "\n"))
+	return []byte(sb.String())
+}
+
+func renderMarkdownBlocks(sb *strings.Builder, lines []string) {
+	for i := 0; i < len(lines); {
+		trimmed := strings.TrimSpace(lines[i])
+		switch {
+		case trimmed == "":
+			i++
+		case strings.HasPrefix(trimmed, "```"):
+			i = renderMarkdownFence(sb, lines, i)
+		case isMarkdownRule(trimmed):
+			sb.WriteString("<hr>\n")
+			i++
+		case markdownHeading(trimmed) > 0:
+			level := markdownHeading(trimmed)
+			tag := "h" + strconv.Itoa(level)
+			sb.WriteString("<" + tag + ">")
+			renderMarkdownInline(sb, strings.TrimSpace(trimmed[level:]))
+			sb.WriteString("</" + tag + ">\n")
+			i++
+		case strings.HasPrefix(trimmed, ">"):
+			var quote []string
+			for ; i < len(lines); i++ {
+				line := strings.TrimSpace(lines[i])
+				if !strings.HasPrefix(line, ">") {
+					break
+				}
+				line = strings.TrimPrefix(line, ">")
+				quote = append(quote, strings.TrimPrefix(line, " "))
+			}
+			sb.WriteString("<blockquote>\n")
+			renderMarkdownBlocks(sb, quote)
+			sb.WriteString("</blockquote>\n")
+		default:
+			if _, _, _, ok := markdownListItem(lines[i]); ok {
+				i = renderMarkdownList(sb, lines, i)
+				continue
+			}
+			i = renderMarkdownParagraph(sb, lines, i)
+		}
+	}
+}
+
+// renderMarkdownFence 围起来的代码块，返回下一行的位置
+func renderMarkdownFence(sb *strings.Builder, lines []string, start int) int {
+	lang := strings.TrimSpace(strings.TrimSpace(lines[start])[3:])
+	end := start + 1
+	for end < len(lines) && !strings.HasPrefix(strings.TrimSpace(lines[end]), "```") {
+		end++
+	}
+
+	sb.WriteString("<pre><code")
+	if lang != "" {
+		sb.WriteString(` class="language-` + html.EscapeString(lang) + `"`)
+	}
+	sb.WriteString(">")
+	for _, line := range lines[start+1 : end] {
+		sb.WriteString(html.EscapeString(line))
+		sb.WriteByte('\n')
+	}
+	sb.WriteString("</code></pre>\n")
+	return end + 1
+}
+
+// renderMarkdownList 连续的同类列表项，缩进的行是上一项的延续
+func renderMarkdownList(sb *strings.Builder, lines []string, start int) int {
+	ordered, num, _, _ := markdownListItem(lines[start])
+	tag := "ul"
+	if ordered {
+		tag = "ol"
+	}
+	sb.WriteString("<" + tag)
+	if ordered && num != 1 {
+		sb.WriteString(` start="` + strconv.Itoa(num) + `"`)
+	}
+	sb.WriteString(">\n")
+
+	var items []string
+	i := start
+	for ; i < len(lines); i++ {
+		line := lines[i]
+		if o, _, content, ok := markdownListItem(line); ok {
+			if o != ordered {
+				break
+			}
+			items = append(items, content)
+			continue
+		}
+		if strings.TrimSpace(line) == "" || !strings.HasPrefix(line, " ") && !strings.HasPrefix(line, "\t") {
+			break
+		}
+		items[len(items)-1] += "\n" + strings.TrimSpace(line)
+	}
+	for _, item := range items {
+		sb.WriteString("<li>")
+		renderMarkdownInline(sb, item)
+		sb.WriteString("</li>\n")
+	}
+	sb.WriteString("</" + tag + ">\n")
+	return i
+}
+
+// renderMarkdownParagraph 段落在空行或者其他块开始的地方结束，行尾两个空格表示换行
+func renderMarkdownParagraph(sb *strings.Builder, lines []string, start int) int {
+	var para []string
+	i := start
+	for ; i < len(lines); i++ {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+		if i > start && (trimmed == "" || startsMarkdownBlock(line)) {
+			break
+		}
+		if strings.HasSuffix(line, "  ") {
+			// 转换成反斜杠加换行，由renderMarkdownInline统一处理
+			trimmed += "\\"
+		}
+		para = append(para, trimmed)
+	}
+	text := strings.TrimSuffix(strings.Join(para, "\n"), "\\")
+	sb.WriteString("<p>")
+	renderMarkdownInline(sb, text)
+	sb.WriteString("</p>\n")
+	return i
+}
+
+func startsMarkdownBlock(line string) bool {
+	trimmed := strings.TrimSpace(line)
+	if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, ">") ||
+		isMarkdownRule(trimmed) || markdownHeading(trimmed) > 0 {
+		return true
+	}
+	_, _, _, ok := markdownListItem(line)
+	return ok
+}
+
+// markdownHeading 返回标题的级别，不是标题时返回0
+func markdownHeading(line string) int {
+	level := 0
+	for level < len(line) && line[level] == '#' {
+		level++
+	}
+	if level == 0 || level > 6 || level < len(line) && line[level] != ' ' {
+		return 0
+	}
+	return level
+}
+
+// isMarkdownRule 三个及以上的-、*或者_，中间可以有空格
+func isMarkdownRule(line string) bool {
+	if line == "" || !strings.ContainsRune("-*_", rune(line[0])) {
+		return false
+	}
+	cnt := 0
+	for i := 0; i < len(line); i++ {
+		switch line[i] {
+		case line[0]:
+			cnt++
+		case ' ':
+		default:
+			return false
+		}
+	}
+	return cnt >= 3
+}
+
+// markdownListItem 解析列表项，有序列表返回起始的数字
+func markdownListItem(line string) (ordered bool, num int, content string, ok bool) {
+	trimmed := strings.TrimLeft(line, " \t")
+	if len(trimmed) >= 2 && strings.ContainsRune("-*+", rune(trimmed[0])) && trimmed[1] == ' ' {
+		return false, 0, strings.TrimSpace(trimmed[2:]), true
+	}
+
+	digits := 0
+	for digits < len(trimmed) && digits < 9 && trimmed[digits] >= '0' && trimmed[digits] <= '9' {
+		digits++
+	}
+	if digits == 0 || digits+1 >= len(trimmed) ||
+		trimmed[digits] != '.' && trimmed[digits] != ')' || trimmed[digits+1] != ' ' {
+		return false, 0, "", false
+	}
+	num, _ = strconv.Atoi(trimmed[:digits])
+	return true, num, strings.TrimSpace(trimmed[digits+2:]), true
+}
+
+func renderMarkdownInline(sb *strings.Builder, s string) {
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == '\\' && i+1 < len(s) && s[i+1] == '\n':
+			sb.WriteString("<br>\n")
+			i += 2
+		case c == '\\' && i+1 < len(s) && isMarkdownPunct(s[i+1]):
+			sb.WriteString(html.EscapeString(s[i+1 : i+2]))
+			i += 2
+		case c == '`':
+			i = renderMarkdownCode(sb, s, i)
+		case c == '!' && strings.HasPrefix(s[i+1:], "["):
+			text, dest, n, ok := parseMarkdownLink(s[i+1:])
+			if !ok {
+				sb.WriteByte('!')
+				i++
+				continue
+			}
+			sb.WriteString(`<img src="` + html.EscapeString(safeMarkdownURL(dest)) +
+				`" alt="` + html.EscapeString(text) + `">`)
+			i += n + 1
+		case c == '[':
+			text, dest, n, ok := parseMarkdownLink(s[i:])
+			if !ok {
+				sb.WriteByte('[')
+				i++
+				continue
+			}
+			sb.WriteString(`<a href="` + html.EscapeString(safeMarkdownURL(dest)) + `">`)
+			renderMarkdownInline(sb, text)
+			sb.WriteString("</a>")
+			i += n
+		case c == '*' || c == '_':
+			i = renderMarkdownEmphasis(sb, s, i)
+		default:
+			// 一次写入到下一个特殊字符之前的内容
+			j := i + 1
+			for j < len(s) && !strings.ContainsRune("\\`![*_", rune(s[j])) {
+				j++
+			}
+			sb.WriteString(html.EscapeString(s[i:j]))
+			i = j
+		}
+	}
+}
+
+// renderMarkdownCode 行内代码，开始和结束的反引号数量必须一致
+func renderMarkdownCode(sb *strings.Builder, s string, start int) int {
+	n := 0
+	for start+n < len(s) && s[start+n] == '`' {
+		n++
+	}
+	delim := s[start : start+n]
+	end := strings.Index(s[start+n:], delim)
+	if end < 0 {
+		sb.WriteString(delim)
+		return start + n
+	}
+	code := s[start+n : start+n+end]
+	if len(code) > 2 && code[0] == ' ' && code[len(code)-1] == ' ' {
+		code = code[1 : len(code)-1]
+	}
+	sb.WriteString("<code>" + html.EscapeString(code) + "</code>")
+	return start + n + end + n
+}
+
+// renderMarkdownEmphasis **加粗**和*斜体*，单词中间的_不处理，例如snake_case
+func renderMarkdownEmphasis(sb *strings.Builder, s string, start int) int {
+	c := s[start]
+	n := 1
+	if start+1 < len(s) && s[start+1] == c {
+		n = 2
+	}
+	delim := s[start : start+n]
+	if c == '_' && start > 0 && isMarkdownWordChar(s[start-1]) {
+		sb.WriteString(delim)
+		return start + n
+	}
+
+	rest := s[start+n:]
+	end := strings.Index(rest, delim)
+	if n == 1 {
+		// 跳过加粗的结束标记
+		for end >= 0 && end+1 < len(rest) && rest[end+1] == c {
+			next := strings.Index(rest[end+2:], delim)
+			if next < 0 {
+				end = -1
+				break
+			}
+			end += 2 + next
+		}
+	}
+	if end <= 0 || rest[0] == ' ' || rest[end-1] == ' ' ||
+		c == '_' && start+n+end+n < len(s) && isMarkdownWordChar(s[start+n+end+n]) {
+		sb.WriteString(delim)
+		return start + n
+	}
+
+	tag := "em"
+	if n == 2 {
+		tag = "strong"
+	}
+	sb.WriteString("<" + tag + ">")
+	renderMarkdownInline(sb, rest[:end])
+	sb.WriteString("</" + tag + ">")
+	return start + n + end + n
+}
+
+// parseMarkdownLink 解析[text](dest "title")，n是消耗的字节数，title会被忽略
+func parseMarkdownLink(s string) (text, dest string, n int, ok bool) {
+	depth := 0
+	closeIdx := -1
+	for i := 0; i < len(s) && closeIdx < 0; i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '[':
+			depth++
+		case ']':
+			depth--
+			if depth == 0 {
+				closeIdx = i
+			}
+		}
+	}
+	if closeIdx < 0 || !strings.HasPrefix(s[closeIdx+1:], "(") {
+		return "", "", 0, false
+	}
+	end := strings.IndexByte(s[closeIdx+2:], ')')
+	if end < 0 {
+		return "", "", 0, false
+	}
+	target := strings.TrimSpace(s[closeIdx+2 : closeIdx+2+end])
+	if idx := strings.IndexAny(target, " \t"); idx >= 0 {
+		target = target[:idx]
+	}
+	target = strings.TrimSuffix(strings.TrimPrefix(target, "<"), ">")
+	return s[1:closeIdx], target, closeIdx + 3 + end, true
+}
+
+// safeMarkdownURL 只允许http、https、mailto和相对地址，其他协议(例如javascript:)替换为#
+func safeMarkdownURL(dest string) string {
+	idx := strings.IndexAny(dest, ":/?#")
+	if idx < 0 || dest[idx] != ':' {
+		return dest
+	}
+	switch strings.ToLower(dest[:idx]) {
+	case "http", "https", "mailto":
+		return dest
+	default:
+		return "#"
+	}
+}
+
+func isMarkdownPunct(c byte) bool {
+	return strings.IndexByte("\\`*_{}[]()#+-.!>|", c) >= 0
+}
+
+func isMarkdownWordChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
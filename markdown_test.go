@@ -0,0 +1,64 @@
+package lr
+
+import (
+	"context"
+	"testing"
+	"text/template"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMarkdownToHTML(t *testing.T) {
+	testCases := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{
+			name: "标题和段落",
+			src:  "# 标题\n\n第一行\n第二行  \n第三行",
+			want: "<h1>标题</h1>\n<p>第一行\n第二行<br>\n第三行</p>\n",
+		},
+		{
+			name: "强调和行内代码",
+			src:  "**粗体**、*斜体*、_斜体_、`a < b`、snake_case_name、2 * 3",
+			want: "<p><strong>粗体</strong>、<em>斜体</em>、<em>斜体</em>、<code>a &lt; b</code>、snake_case_name、2 * 3</p>\n",
+		},
+		{
+			name: "链接和图片",
+			src:  `[文档](https://example.com/?a=1&b=2 "标题") ![logo](/logo.png) [坏链接](javascript:alert(1)`,
+			want: `<p><a href="https://example.com/?a=1&amp;b=2">文档</a> <img src="/logo.png" alt="logo"> <a href="#">坏链接</a></p>` + "\n",
+		},
+		{
+			name: "列表",
+			src:  "- 苹果\n- **香蕉**\n  很好吃\n\n3. 第三\n4. 第四",
+			want: "<ul>\n<li>苹果</li>\n<li><strong>香蕉</strong>\n很好吃</li>\n</ul>\n<ol start=\"3\">\n<li>第三</li>\n<li>第四</li>\n</ol>\n",
+		},
+		{
+			name: "代码块、引用和分隔线",
+			src:  "```go\nfmt.Println(\"<hi>\")\n```\n> 引用\n> **内容**\n\n---",
+			want: "<pre><code class=\"language-go\">fmt.Println(&#34;&lt;hi&gt;&#34;)\n</code></pre>\n" +
+				"<blockquote>\n<p>引用\n<strong>内容</strong></p>\n</blockquote>\n<hr>\n",
+		},
+		{
+			name: "转义HTML",
+			src:  `<script>alert(1)</script> \*不是斜体\*`,
+			want: "<p>&lt;script&gt;alert(1)&lt;/script&gt; *不是斜体*</p>\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, string(MarkdownToHTML([]byte(tc.src))))
+		})
+	}
+}
+
+func TestMarkdownEngine_Render(t *testing.T) {
+	tpl := template.Must(template.New("welcome.md").Parse("# 你好，{{.Name}}\n\n欢迎访问[首页]({{.URL}})"))
+	data, err := MarkdownEngine{T: tpl}.Render(context.Background(), "welcome.md",
+		map[string]string{"Name": "<Tom>", "URL": "/home"})
+	require.NoError(t, err)
+	assert.Equal(t, "<h1>你好，&lt;Tom&gt;</h1>\n<p>欢迎访问<a href=\"/home\">首页</a></p>\n", string(data))
+}
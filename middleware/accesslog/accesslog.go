@@ -8,6 +8,8 @@ import (
 type MiddleBuilder struct {
 	// logFunc可以解决调用者使用不同log包的问题
 	logFunc func(msg string)
+	// 需要记录的Context.Set保存的数据
+	keys []string
 }
 
 func NewMiddleBuilder() *MiddleBuilder {
@@ -19,17 +21,32 @@ func (b *MiddleBuilder) LogFunc(fn func(string)) *MiddleBuilder {
 	return b
 }
 
+// Keys 记录handler或者其他中间件通过Context.Set保存的数据，例如用户id
+func (b *MiddleBuilder) Keys(keys ...string) *MiddleBuilder {
+	b.keys = keys
+	return b
+}
+
 func (b *MiddleBuilder) Build() lr.Middleware {
 	return func(next lr.HandleFunc) lr.HandleFunc {
 		return func(ctx *lr.Context) {
 			defer func() {
 				// 记录请求信息，在defer中执行可以方式panic问题导致未知性
 				lg := AccessLog{
-					Host:       ctx.Req.Host,
+					Host:       ctx.Host(),
+					ClientIP:   ctx.ClientIP(),
 					Root:       ctx.MatchedPath(),
 					HTTPMethod: ctx.Req.Method,
 					Path:       ctx.Req.URL.Path,
 				}
+				for _, key := range b.keys {
+					if val, ok := ctx.Get(key); ok {
+						if lg.Values == nil {
+							lg.Values = make(map[string]any, len(b.keys))
+						}
+						lg.Values[key] = val
+					}
+				}
 
 				bytes, _ := json.Marshal(&lg)
 				b.logFunc(string(bytes))
@@ -40,8 +57,10 @@ func (b *MiddleBuilder) Build() lr.Middleware {
 }
 
 type AccessLog struct {
-	Host       string `json:"host,omitempty"`
-	Root       string `json:"root,omitempty"`
-	Path       string `json:"path,omitempty"`
-	HTTPMethod string `json:"http_method,omitempty"`
+	Host       string         `json:"host,omitempty"`
+	ClientIP   string         `json:"client_ip,omitempty"`
+	Root       string         `json:"root,omitempty"`
+	Path       string         `json:"path,omitempty"`
+	HTTPMethod string         `json:"http_method,omitempty"`
+	Values     map[string]any `json:"values,omitempty"`
 }
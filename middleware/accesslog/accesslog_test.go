@@ -2,8 +2,10 @@ package accesslog
 
 import (
 	"github.com/liquanhui-99/lr"
+	"github.com/stretchr/testify/assert"
 	"log"
 	"net/http"
+	"net/http/httptest"
 	"testing"
 )
 
@@ -23,3 +25,17 @@ func TestAccessLog(t *testing.T) {
 		panic(err)
 	}
 }
+
+func TestAccessLog_Keys(t *testing.T) {
+	var msg string
+	accessLog := NewMiddleBuilder().LogFunc(func(s string) {
+		msg = s
+	}).Keys("uid", "missing")
+	s := lr.NewHTTPServer("tcp", ":8081", lr.Use(accessLog.Build()))
+	s.GET("/user", func(ctx *lr.Context) {
+		ctx.Set("uid", 42)
+	})
+
+	s.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/user", nil))
+	assert.Equal(t, `{"host":"example.com","client_ip":"192.0.2.1","root":"user","path":"/user","http_method":"GET","values":{"uid":42}}`, msg)
+}
@@ -64,6 +64,17 @@ func (b *MiddlewareBuilder) Build() lr.Middleware {
 	return func(next lr.HandleFunc) lr.HandleFunc {
 		return func(ctx *lr.Context) {
 			next(ctx)
+			// handler已经返回了problem文档，不需要再处理
+			if ctx.IsProblem() {
+				return
+			}
+			// 客户端接受json时返回problem文档，而不是html页面
+			if _, ok := b.resp[ctx.Status]; ok && ctx.PrefersJSON() {
+				if err := ctx.RespProblem(lr.NewProblem(ctx.Status, "")); err != nil {
+					b.logFunc(err.Error())
+				}
+				return
+			}
 			engine, ok := b.resp[ctx.Status]
 			if ok {
 				// 串改结果
@@ -2,7 +2,9 @@ package errorHandler
 
 import (
 	"github.com/liquanhui-99/lr"
+	"github.com/stretchr/testify/assert"
 	"net/http"
+	"net/http/httptest"
 	"testing"
 )
 
@@ -23,3 +25,46 @@ func TestMiddlewareBuilder(t *testing.T) {
 		panic(err)
 	}
 }
+
+func TestMiddlewareBuilder_Problem(t *testing.T) {
+	builder := NewMiddlewareBuilder().
+		AddCode(http.StatusNotFound).
+		AddCode(http.StatusInternalServerError)
+	h := lr.NewHTTPServer("tcp", ":8084", lr.Use(builder.Build()))
+	h.GET("/user", func(ctx *lr.Context) {
+		ctx.Status = http.StatusInternalServerError
+	})
+	h.GET("/order", lr.Handle(func(ctx *lr.Context) error {
+		return lr.NewProblem(http.StatusInternalServerError, "库存服务不可用")
+	}))
+
+	testCases := []struct {
+		name     string
+		path     string
+		wantBody string
+	}{
+		{
+			name:     "状态码转换为problem",
+			path:     "/user",
+			wantBody: `{"type":"about:blank","title":"Internal Server Error","status":500,"instance":"/user"}`,
+		},
+		{
+			name:     "不覆盖handler的problem",
+			path:     "/order",
+			wantBody: `{"type":"about:blank","title":"Internal Server Error","status":500,"detail":"库存服务不可用","instance":"/order"}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+			req.Header.Set("Accept", "application/json")
+			recorder := httptest.NewRecorder()
+			h.ServeHTTP(recorder, req)
+
+			assert.Equal(t, http.StatusInternalServerError, recorder.Code)
+			assert.Equal(t, "application/problem+json", recorder.Header().Get("Content-Type"))
+			assert.Equal(t, tc.wantBody, recorder.Body.String())
+		})
+	}
+}
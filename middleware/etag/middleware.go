@@ -0,0 +1,60 @@
+package etag
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+
+	"github.com/liquanhui-99/lr"
+)
+
+type MiddlewareBuilder struct {
+	// 是否生成弱ETag，响应内容在语义上相同但字节可能不同时(例如压缩)使用
+	weak bool
+}
+
+func NewMiddlewareBuilder() *MiddlewareBuilder {
+	return &MiddlewareBuilder{}
+}
+
+// Weak 生成W/前缀的弱ETag
+func (b *MiddlewareBuilder) Weak() *MiddlewareBuilder {
+	b.weak = true
+	return b
+}
+
+// Build 对GET和HEAD的200响应计算ETag，然后处理If-None-Match、If-Modified-Since、If-Match和If-Unmodified-Since
+// handler已经设置了ETag时直接使用，不再计算
+//
+// 中间件在handler执行之后才能得到ETag，不处理PUT、PATCH、DELETE这些修改资源的请求：
+// 它们的If-Match和If-Unmodified-Since需要handler在修改之前设置ETag或者Last-Modified，
+// 然后调用ctx.CheckPreconditions，见lr.Context.CheckPreconditions
+func (b *MiddlewareBuilder) Build() lr.Middleware {
+	return func(next lr.HandleFunc) lr.HandleFunc {
+		return func(ctx *lr.Context) {
+			next(ctx)
+
+			if ctx.Req.Method != http.MethodGet && ctx.Req.Method != http.MethodHead {
+				return
+			}
+			if ctx.Status != http.StatusOK || len(ctx.RespData) == 0 {
+				return
+			}
+
+			if ctx.Resp.Header().Get("ETag") == "" {
+				ctx.SetETag(b.etag(ctx.RespData))
+			}
+			ctx.CheckPreconditions()
+		}
+	}
+}
+
+// etag sha256的前16字节，足够区分同一个资源的不同版本
+func (b *MiddlewareBuilder) etag(data []byte) string {
+	sum := sha256.Sum256(data)
+	etag := `"` + base64.RawURLEncoding.EncodeToString(sum[:16]) + `"`
+	if b.weak {
+		etag = "W/" + etag
+	}
+	return etag
+}
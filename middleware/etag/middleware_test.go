@@ -0,0 +1,152 @@
+package etag
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/liquanhui-99/lr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMiddlewareBuilder(t *testing.T) {
+	modified := time.Date(2023, 5, 1, 10, 0, 0, 0, time.UTC)
+	h := lr.NewHTTPServer("tcp", ":8081", lr.Use(NewMiddlewareBuilder().Build()))
+	h.GET("/user", func(ctx *lr.Context) {
+		_ = ctx.RespJSON(http.StatusOK, map[string]string{"name": "Tom"})
+	})
+	h.GET("/article", func(ctx *lr.Context) {
+		ctx.SetETag("v3")
+		ctx.SetLastModified(modified)
+		_ = ctx.RespString(http.StatusOK, "article")
+	})
+	h.GET("/error", func(ctx *lr.Context) {
+		_ = ctx.RespString(http.StatusInternalServerError, "boom")
+	})
+	h.POST("/user", func(ctx *lr.Context) {
+		_ = ctx.RespString(http.StatusOK, "created")
+	})
+
+	recorder := httptest.NewRecorder()
+	h.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/user", nil))
+	require.Equal(t, http.StatusOK, recorder.Code)
+	etag := recorder.Header().Get("ETag")
+	assert.True(t, strings.HasPrefix(etag, `"`))
+
+	testCases := []struct {
+		name       string
+		method     string
+		path       string
+		headers    map[string]string
+		wantStatus int
+		wantETag   string
+	}{
+		{
+			name:       "If-None-Match命中",
+			method:     http.MethodGet,
+			path:       "/user",
+			headers:    map[string]string{"If-None-Match": etag},
+			wantStatus: http.StatusNotModified,
+			wantETag:   etag,
+		},
+		{
+			name:       "If-None-Match未命中",
+			method:     http.MethodGet,
+			path:       "/user",
+			headers:    map[string]string{"If-None-Match": `"stale"`},
+			wantStatus: http.StatusOK,
+			wantETag:   etag,
+		},
+		{
+			name:       "If-Match未命中",
+			method:     http.MethodGet,
+			path:       "/user",
+			headers:    map[string]string{"If-Match": `"stale"`},
+			wantStatus: http.StatusPreconditionFailed,
+			wantETag:   etag,
+		},
+		{
+			name:       "handler设置的ETag",
+			method:     http.MethodGet,
+			path:       "/article",
+			headers:    map[string]string{"If-None-Match": `"v3"`},
+			wantStatus: http.StatusNotModified,
+			wantETag:   `"v3"`,
+		},
+		{
+			name:       "If-Modified-Since",
+			method:     http.MethodGet,
+			path:       "/article",
+			headers:    map[string]string{"If-Modified-Since": modified.Format(http.TimeFormat)},
+			wantStatus: http.StatusNotModified,
+			wantETag:   `"v3"`,
+		},
+		{
+			name:       "If-Unmodified-Since",
+			method:     http.MethodGet,
+			path:       "/article",
+			headers:    map[string]string{"If-Unmodified-Since": modified.Add(-time.Hour).Format(http.TimeFormat)},
+			wantStatus: http.StatusPreconditionFailed,
+			wantETag:   `"v3"`,
+		},
+		{
+			name:       "非200响应",
+			method:     http.MethodGet,
+			path:       "/error",
+			headers:    map[string]string{"If-None-Match": "*"},
+			wantStatus: http.StatusInternalServerError,
+		},
+		{
+			name:       "POST请求",
+			method:     http.MethodPost,
+			path:       "/user",
+			headers:    map[string]string{"If-None-Match": "*"},
+			wantStatus: http.StatusOK,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, tc.path, nil)
+			for key, val := range tc.headers {
+				req.Header.Set(key, val)
+			}
+			recorder := httptest.NewRecorder()
+			h.ServeHTTP(recorder, req)
+
+			assert.Equal(t, tc.wantStatus, recorder.Code)
+			assert.Equal(t, tc.wantETag, recorder.Header().Get("ETag"))
+			if tc.wantStatus == http.StatusNotModified || tc.wantStatus == http.StatusPreconditionFailed {
+				assert.Empty(t, recorder.Body.String())
+			}
+		})
+	}
+}
+
+func TestMiddlewareBuilder_Weak(t *testing.T) {
+	h := lr.NewHTTPServer("tcp", ":8081", lr.Use(NewMiddlewareBuilder().Weak().Build()))
+	h.GET("/user", func(ctx *lr.Context) {
+		_ = ctx.RespString(http.StatusOK, "Tom")
+	})
+
+	recorder := httptest.NewRecorder()
+	h.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/user", nil))
+	etag := recorder.Header().Get("ETag")
+	assert.True(t, strings.HasPrefix(etag, `W/"`))
+
+	// 弱ETag可以用于If-None-Match，不能用于If-Match
+	req := httptest.NewRequest(http.MethodGet, "/user", nil)
+	req.Header.Set("If-None-Match", strings.TrimPrefix(etag, "W/"))
+	recorder = httptest.NewRecorder()
+	h.ServeHTTP(recorder, req)
+	assert.Equal(t, http.StatusNotModified, recorder.Code)
+
+	req = httptest.NewRequest(http.MethodGet, "/user", nil)
+	req.Header.Set("If-Match", etag)
+	recorder = httptest.NewRecorder()
+	h.ServeHTTP(recorder, req)
+	assert.Equal(t, http.StatusPreconditionFailed, recorder.Code)
+}
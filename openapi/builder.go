@@ -0,0 +1,400 @@
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/liquanhui-99/lr"
+)
+
+// OperationMeta 无法从代码中推断的路由信息
+type OperationMeta struct {
+	Summary     string
+	Description string
+	Tags        []string
+	// 需要的认证方式，为空时使用全局设置
+	Security []string
+	// 不需要认证，覆盖全局设置
+	Public     bool
+	Deprecated bool
+}
+
+// Builder 根据注册的路由生成OpenAPI 3.1文档
+// 通过lr.HandleTyped注册或者用lr.WithTypes记录了类型的路由会根据Req和Resp的类型生成参数、请求体和响应，普通的handler只有路径参数
+//
+//	openapi.NewBuilder("用户服务", "1.0.0").
+//		SecurityScheme("bearer", openapi.SecurityScheme{Type: "http", Scheme: "bearer"}).
+//		Security("bearer").
+//		Operation(http.MethodPost, "/login", openapi.OperationMeta{Public: true}).
+//		Register(server)
+type Builder struct {
+	info            Info
+	servers         []Server
+	securitySchemes map[string]*SecurityScheme
+	security        []SecurityRequirement
+	operations      map[string]OperationMeta
+	docPath         string
+	uiPath          string
+}
+
+// NewBuilder 文档默认在/openapi.json，UI默认在/docs
+func NewBuilder(title, version string) *Builder {
+	return &Builder{
+		info:            Info{Title: title, Version: version},
+		securitySchemes: make(map[string]*SecurityScheme),
+		operations:      make(map[string]OperationMeta),
+		docPath:         "/openapi.json",
+		uiPath:          "/docs",
+	}
+}
+
+// Description 文档的描述
+func (b *Builder) Description(desc string) *Builder {
+	b.info.Description = desc
+	return b
+}
+
+// Server 添加服务地址
+func (b *Builder) Server(url, desc string) *Builder {
+	b.servers = append(b.servers, Server{URL: url, Description: desc})
+	return b
+}
+
+// SecurityScheme 添加认证方式
+func (b *Builder) SecurityScheme(name string, scheme SecurityScheme) *Builder {
+	b.securitySchemes[name] = &scheme
+	return b
+}
+
+// Security 所有路由默认需要的认证方式，多次调用表示满足其中一种即可
+func (b *Builder) Security(names ...string) *Builder {
+	b.security = append(b.security, newSecurityRequirement(names))
+	return b
+}
+
+// Operation 补充单个路由的信息，path和注册路由时使用的一致，例如/user/:id
+func (b *Builder) Operation(method, path string, meta OperationMeta) *Builder {
+	b.operations[method+" "+path] = meta
+	return b
+}
+
+// DocPath 文档的路径，为空时不注册
+func (b *Builder) DocPath(path string) *Builder {
+	b.docPath = path
+	return b
+}
+
+// UIPath 文档UI的路径，为空时不注册
+func (b *Builder) UIPath(path string) *Builder {
+	b.uiPath = path
+	return b
+}
+
+// Register 在server上注册文档和UI
+// 文档在每次请求时根据当前的路由生成，所以Register之后注册的路由也会出现在文档中
+func (b *Builder) Register(server *lr.HTTPServer) {
+	if b.docPath != "" {
+		server.GET(b.docPath, func(ctx *lr.Context) {
+			data, err := json.Marshal(b.Build(server.Routes()))
+			if err != nil {
+				_ = ctx.RespJSON(http.StatusInternalServerError, lr.NewHTTPError(http.StatusInternalServerError, http.StatusInternalServerError, ""))
+				return
+			}
+			_ = ctx.RespBytes(http.StatusOK, "application/json", data)
+		})
+	}
+	if b.uiPath != "" {
+		page := uiPage(b.docPath)
+		server.GET(b.uiPath, func(ctx *lr.Context) {
+			_ = ctx.RespBytes(http.StatusOK, "text/html; charset=utf-8", page)
+		})
+	}
+}
+
+// Build 生成文档，文档和UI自身的路由会被忽略
+func (b *Builder) Build(routes []lr.RouteInfo) *Document {
+	doc := &Document{
+		OpenAPI:  Version,
+		Info:     b.info,
+		Servers:  b.servers,
+		Paths:    make(map[string]PathItem),
+		Security: b.security,
+	}
+	registry := newSchemaRegistry()
+	operationIDs := make(map[string]struct{}, len(routes))
+
+	for _, route := range routes {
+		method := strings.ToLower(route.Method)
+		if !isOpenAPIMethod(method) || route.Path == b.docPath || route.Path == b.uiPath {
+			continue
+		}
+
+		path, pathParams := convertPath(route.Path)
+		op := b.operation(registry, route)
+		op.OperationID = uniqueOperationID(operationIDs, method, route.Path)
+		for _, name := range pathParams {
+			if !hasParameter(op.Parameters, name, "path") {
+				op.Parameters = append(op.Parameters, &Parameter{Name: name, In: "path", Required: true, Schema: &Schema{Type: "string"}})
+			}
+		}
+
+		if doc.Paths[path] == nil {
+			doc.Paths[path] = make(PathItem)
+		}
+		doc.Paths[path][method] = op
+	}
+
+	if len(registry.schemas) > 0 || len(b.securitySchemes) > 0 {
+		doc.Components = &Components{SecuritySchemes: b.securitySchemes}
+		if len(registry.schemas) > 0 {
+			doc.Components.Schemas = registry.schemas
+		}
+	}
+	return doc
+}
+
+func (b *Builder) operation(registry *schemaRegistry, route lr.RouteInfo) *Operation {
+	op := &Operation{Responses: make(map[string]*Response)}
+
+	if info := route.Types; info != nil {
+		reqType := info.Req
+		if reqType.Kind() == reflect.Pointer {
+			reqType = reqType.Elem()
+		}
+		if reqType.Kind() == reflect.Struct {
+			addRequest(registry, op, route.Method, reqType)
+			op.Responses["400"] = &Response{
+				Description: "请求参数错误",
+				Content:     jsonContent(&Schema{Ref: "#/components/schemas/" + registerBadRequest(registry)}),
+			}
+		}
+		addResponses(registry, op, info.Resp)
+	} else {
+		op.Responses["200"] = &Response{Description: "OK"}
+	}
+
+	meta, ok := b.operations[route.Method+" "+route.Path]
+	if !ok {
+		return op
+	}
+	op.Summary = meta.Summary
+	op.Description = meta.Description
+	op.Tags = meta.Tags
+	op.Deprecated = meta.Deprecated
+	switch {
+	case meta.Public:
+		op.Security = &[]SecurityRequirement{}
+	case len(meta.Security) > 0:
+		op.Security = &[]SecurityRequirement{newSecurityRequirement(meta.Security)}
+	}
+	return op
+}
+
+// addRequest path、query、header标签的字段生成参数，form标签的字段生成表单，其他字段生成json请求体
+func addRequest(registry *schemaRegistry, op *Operation, method string, typ reflect.Type) {
+	form := &Schema{Type: "object"}
+	hasFile := false
+
+	walkFields(typ, func(sf reflect.StructField) {
+		for _, in := range []string{"path", "query", "header"} {
+			name := sf.Tag.Get(in)
+			if name == "" || name == "-" {
+				continue
+			}
+			param := &Parameter{
+				Name:     name,
+				In:       in,
+				Required: in == "path" || isRequired(sf),
+				Schema:   registry.fieldSchema(sf),
+			}
+			param.Description, param.Schema.Description = param.Schema.Description, ""
+			op.Parameters = append(op.Parameters, param)
+		}
+
+		if name := sf.Tag.Get("form"); name != "" && name != "-" {
+			if form.Properties == nil {
+				form.Properties = make(map[string]*Schema)
+			}
+			form.Properties[name] = registry.fieldSchema(sf)
+			if isRequired(sf) {
+				form.Required = append(form.Required, name)
+			}
+			if isFileField(sf.Type) {
+				hasFile = true
+			}
+		}
+	})
+
+	body := &RequestBody{Content: make(map[string]*MediaType)}
+	if method != http.MethodGet && method != http.MethodHead {
+		var schema *Schema
+		if len(op.Parameters) == 0 && len(form.Properties) == 0 {
+			schema = registry.schemaOf(typ)
+		} else {
+			schema = registry.objectSchema(typ, isBindField)
+		}
+		resolved := schema
+		if schema.Ref != "" {
+			resolved = registry.schemas[registry.names[typ]]
+		}
+		if len(resolved.Properties) > 0 {
+			body.Content["application/json"] = &MediaType{Schema: schema}
+			body.Required = len(resolved.Required) > 0
+		}
+	}
+	if len(form.Properties) > 0 {
+		contentType := "application/x-www-form-urlencoded"
+		if hasFile {
+			contentType = "multipart/form-data"
+		}
+		body.Content[contentType] = &MediaType{Schema: form}
+		body.Required = body.Required || len(form.Required) > 0
+	}
+	if len(body.Content) > 0 {
+		op.RequestBody = body
+	}
+}
+
+// addResponses 成功时按照Resp的类型返回200，Resp可以为nil时还会返回204
+func addResponses(registry *schemaRegistry, op *Operation, typ reflect.Type) {
+	op.Responses["200"] = &Response{Description: "OK", Content: jsonContent(registry.schemaOf(typ))}
+	switch typ.Kind() {
+	case reflect.Pointer, reflect.Interface, reflect.Map, reflect.Slice:
+		op.Responses["204"] = &Response{Description: "No Content"}
+	}
+
+	op.Responses["default"] = &Response{
+		Description: "错误",
+		Content: map[string]*MediaType{
+			"application/json":         {Schema: registry.schemaOf(reflect.TypeOf(lr.HTTPError{}))},
+			"application/problem+json": {Schema: &Schema{Ref: "#/components/schemas/" + registerProblem(registry)}},
+		},
+	}
+}
+
+// registerBadRequest RespBadRequest返回的响应体
+func registerBadRequest(registry *schemaRegistry) string {
+	const name = "BadRequest"
+	if _, ok := registry.schemas[name]; !ok {
+		registry.schemas[name] = registry.objectSchema(reflect.TypeOf(struct {
+			Message string                     `json:"message" validate:"required"`
+			Errors  []*lr.ValidationFieldError `json:"errors,omitempty"`
+		}{}), nil)
+	}
+	return name
+}
+
+// registerProblem Problem实现了json.Marshaler，需要单独生成
+func registerProblem(registry *schemaRegistry) string {
+	typ := reflect.TypeOf(lr.Problem{})
+	if name, ok := registry.names[typ]; ok {
+		return name
+	}
+	name := registry.uniqueName(typ)
+	registry.names[typ] = name
+	registry.schemas[name] = registry.objectSchema(typ, nil)
+	return name
+}
+
+// walkFields 遍历导出的字段，没有标签的嵌入结构体展开处理，和Bind的行为一致
+func walkFields(typ reflect.Type, fn func(sf reflect.StructField)) {
+	for i := 0; i < typ.NumField(); i++ {
+		sf := typ.Field(i)
+		if sf.Anonymous && !isBindField(sf) {
+			ft := sf.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct && ft != timeType {
+				walkFields(ft, fn)
+				continue
+			}
+		}
+		if sf.IsExported() {
+			fn(sf)
+		}
+	}
+}
+
+// isBindField 是否从path、query、form、header中绑定，这些字段不属于json请求体
+func isBindField(sf reflect.StructField) bool {
+	for _, tag := range []string{"path", "query", "form", "header"} {
+		if name := sf.Tag.Get(tag); name != "" && name != "-" {
+			return true
+		}
+	}
+	return false
+}
+
+func isFileField(typ reflect.Type) bool {
+	for typ.Kind() == reflect.Pointer || typ.Kind() == reflect.Slice {
+		typ = typ.Elem()
+	}
+	return typ == fileHeaderType
+}
+
+// convertPath 把/user/:id转换为/user/{id}，同时返回路径参数
+func convertPath(path string) (string, []string) {
+	segs := strings.Split(path, "/")
+	var params []string
+	for i, seg := range segs {
+		if strings.HasPrefix(seg, ":") {
+			params = append(params, seg[1:])
+			segs[i] = "{" + seg[1:] + "}"
+		}
+	}
+	return strings.Join(segs, "/"), params
+}
+
+// uniqueOperationID 方法加上路径生成operationId，例如GET /user/:id生成getUserId
+func uniqueOperationID(used map[string]struct{}, method, path string) string {
+	var sb strings.Builder
+	sb.WriteString(method)
+	for _, seg := range strings.FieldsFunc(path, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9')
+	}) {
+		sb.WriteString(strings.ToUpper(seg[:1]) + seg[1:])
+	}
+
+	id := sb.String()
+	for i := 2; ; i++ {
+		if _, ok := used[id]; !ok {
+			break
+		}
+		id = sb.String() + strconv.Itoa(i)
+	}
+	used[id] = struct{}{}
+	return id
+}
+
+func hasParameter(params []*Parameter, name, in string) bool {
+	for _, p := range params {
+		if p.Name == name && p.In == in {
+			return true
+		}
+	}
+	return false
+}
+
+func isOpenAPIMethod(method string) bool {
+	switch method {
+	case "get", "put", "post", "delete", "options", "head", "patch", "trace":
+		return true
+	}
+	return false
+}
+
+func newSecurityRequirement(names []string) SecurityRequirement {
+	req := make(SecurityRequirement, len(names))
+	for _, name := range names {
+		req[name] = []string{}
+	}
+	return req
+}
+
+func jsonContent(schema *Schema) map[string]*MediaType {
+	return map[string]*MediaType{"application/json": {Schema: schema}}
+}
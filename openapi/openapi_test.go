@@ -0,0 +1,221 @@
+package openapi
+
+import (
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/liquanhui-99/lr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type Address struct {
+	City string `json:"city" validate:"required"`
+}
+
+type User struct {
+	ID        int64     `json:"id"`
+	Name      string    `json:"name" description:"用户名"`
+	Email     string    `json:"email,omitempty"`
+	Tags      []string  `json:"tags,omitempty"`
+	Address   *Address  `json:"address,omitempty"`
+	Friends   []*User   `json:"friends,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	password  string
+}
+
+type Pagination struct {
+	Page int `query:"page" default:"1" validate:"gte=1"`
+	Size int `query:"size" default:"20" validate:"max=100"`
+}
+
+type ListUserReq struct {
+	Pagination
+	Keyword string `query:"q"`
+}
+
+type UpdateUserReq struct {
+	ID    int64    `path:"id"`
+	Token string   `header:"X-Token" validate:"required"`
+	Name  string   `json:"name" validate:"required,min=2,max=20"`
+	Email string   `json:"email" validate:"omitempty,email"`
+	Level string   `json:"level" validate:"oneof=low high"`
+	Tags  []string `json:"tags" validate:"max=5,dive,alpha"`
+}
+
+type UploadReq struct {
+	Title string                `form:"title" validate:"required"`
+	File  *multipart.FileHeader `form:"file"`
+}
+
+func TestBuilder_Build(t *testing.T) {
+	h := lr.NewHTTPServer("tcp", ":8081")
+	h.GET("/", func(ctx *lr.Context) {})
+	listUser := func(ctx *lr.Context, req ListUserReq) ([]User, error) {
+		return nil, nil
+	}
+	lr.HandleTyped(h, http.MethodGet, "/user", listUser)
+	updateUser := func(ctx *lr.Context, req *UpdateUserReq) (*User, error) {
+		return nil, nil
+	}
+	lr.HandleTyped(h, http.MethodPut, "/user/:id", updateUser)
+	signIn := func(ctx *lr.Context, req struct {
+		Email    string `json:"email" validate:"required,email"`
+		Password string `json:"password" validate:"required"`
+	}) (string, error) {
+		return "", nil
+	}
+	lr.HandleTyped(h, http.MethodPost, "/user/signIn", signIn)
+	upload := func(ctx *lr.Context, req UploadReq) (map[string]string, error) {
+		return nil, nil
+	}
+	lr.HandleTyped(h, http.MethodPost, "/file/upload", upload)
+	h.DELETE("/order/:orderId", func(ctx *lr.Context) {})
+
+	doc := NewBuilder("用户服务", "1.0.0").
+		Server("https://api.example.com", "").
+		SecurityScheme("bearer", SecurityScheme{Type: "http", Scheme: "bearer", BearerFormat: "JWT"}).
+		Security("bearer").
+		Operation(http.MethodPost, "/user/signIn", OperationMeta{Summary: "登录", Tags: []string{"user"}, Public: true}).
+		Build(h.Routes())
+
+	assert.Equal(t, "3.1.0", doc.OpenAPI)
+	assert.Equal(t, []SecurityRequirement{{"bearer": {}}}, doc.Security)
+	assert.Equal(t, &SecurityScheme{Type: "http", Scheme: "bearer", BearerFormat: "JWT"}, doc.Components.SecuritySchemes["bearer"])
+	assert.ElementsMatch(t, []string{"/", "/user", "/user/{id}", "/user/signIn", "/file/upload", "/order/{orderId}"}, keys(doc.Paths))
+
+	t.Run("普通handler", func(t *testing.T) {
+		op := doc.Paths["/order/{orderId}"]["delete"]
+		assert.Equal(t, "deleteOrderOrderId", op.OperationID)
+		assert.Equal(t, []*Parameter{{Name: "orderId", In: "path", Required: true, Schema: &Schema{Type: "string"}}}, op.Parameters)
+		assert.Equal(t, map[string]*Response{"200": {Description: "OK"}}, op.Responses)
+		assert.Nil(t, op.RequestBody)
+	})
+
+	t.Run("query参数", func(t *testing.T) {
+		op := doc.Paths["/user"]["get"]
+		require.Len(t, op.Parameters, 3)
+		assert.Equal(t, &Parameter{Name: "page", In: "query", Schema: &Schema{Type: "integer", Format: "int64", Minimum: float64Ptr(1), Default: int64(1)}}, op.Parameters[0])
+		assert.Equal(t, &Schema{Type: "integer", Format: "int64", Maximum: float64Ptr(100), Default: int64(20)}, op.Parameters[1].Schema)
+		assert.Equal(t, "q", op.Parameters[2].Name)
+		assert.Nil(t, op.RequestBody)
+
+		assert.Equal(t, &Schema{Type: "array", Items: &Schema{Ref: "#/components/schemas/User"}}, op.Responses["200"].Content["application/json"].Schema)
+		assert.Contains(t, op.Responses, "204")
+		assert.Contains(t, op.Responses, "400")
+		assert.Contains(t, op.Responses["default"].Content, "application/problem+json")
+	})
+
+	t.Run("path、header和body", func(t *testing.T) {
+		op := doc.Paths["/user/{id}"]["put"]
+		require.Len(t, op.Parameters, 2)
+		assert.Equal(t, &Parameter{Name: "id", In: "path", Required: true, Schema: &Schema{Type: "integer", Format: "int64"}}, op.Parameters[0])
+		assert.Equal(t, &Parameter{Name: "X-Token", In: "header", Required: true, Schema: &Schema{Type: "string"}}, op.Parameters[1])
+
+		require.NotNil(t, op.RequestBody)
+		assert.True(t, op.RequestBody.Required)
+		body := op.RequestBody.Content["application/json"].Schema
+		assert.Equal(t, []string{"name"}, body.Required)
+		assert.ElementsMatch(t, []string{"name", "email", "level", "tags"}, keys(body.Properties))
+		assert.Equal(t, &Schema{Type: "string", MinLength: intPtr(2), MaxLength: intPtr(20)}, body.Properties["name"])
+		assert.Equal(t, &Schema{Type: "string", Format: "email"}, body.Properties["email"])
+		assert.Equal(t, &Schema{Type: "string", Enum: []any{"low", "high"}}, body.Properties["level"])
+		assert.Equal(t, &Schema{Type: "array", MaxItems: intPtr(5), Items: &Schema{Type: "string", Pattern: `^[a-zA-Z]+$`}}, body.Properties["tags"])
+
+		assert.Equal(t, &Schema{Ref: "#/components/schemas/User"}, op.Responses["200"].Content["application/json"].Schema)
+	})
+
+	t.Run("OperationMeta", func(t *testing.T) {
+		op := doc.Paths["/user/signIn"]["post"]
+		assert.Equal(t, "登录", op.Summary)
+		assert.Equal(t, []string{"user"}, op.Tags)
+		assert.Equal(t, &[]SecurityRequirement{}, op.Security)
+		body := op.RequestBody.Content["application/json"].Schema
+		assert.Equal(t, "object", body.Type)
+		assert.Equal(t, []string{"email", "password"}, body.Required)
+		assert.Equal(t, &Schema{Type: "string"}, op.Responses["200"].Content["application/json"].Schema)
+		assert.NotContains(t, op.Responses, "204")
+	})
+
+	t.Run("表单", func(t *testing.T) {
+		op := doc.Paths["/file/upload"]["post"]
+		form := op.RequestBody.Content["multipart/form-data"].Schema
+		require.NotNil(t, form)
+		assert.Equal(t, []string{"title"}, form.Required)
+		assert.Equal(t, &Schema{Type: "string", Format: "binary"}, form.Properties["file"])
+		assert.NotContains(t, op.RequestBody.Content, "application/json")
+	})
+
+	t.Run("components", func(t *testing.T) {
+		user := doc.Components.Schemas["User"]
+		require.NotNil(t, user)
+		assert.ElementsMatch(t, []string{"id", "name", "email", "tags", "address", "friends", "created_at"}, keys(user.Properties))
+		assert.Equal(t, &Schema{Type: "string", Description: "用户名"}, user.Properties["name"])
+		assert.Equal(t, &Schema{Type: "string", Format: "date-time"}, user.Properties["created_at"])
+		assert.Equal(t, &Schema{Type: "array", Items: &Schema{Ref: "#/components/schemas/User"}}, user.Properties["friends"])
+		assert.Equal(t, []string{"city"}, doc.Components.Schemas["Address"].Required)
+		assert.Contains(t, doc.Components.Schemas, "BadRequest")
+		assert.Contains(t, doc.Components.Schemas["Problem"].Properties, "detail")
+	})
+}
+
+func TestBuilder_Register(t *testing.T) {
+	h := lr.NewHTTPServer("tcp", ":8081")
+	NewBuilder("用户服务", "1.0.0").DocPath("/api/openapi.json").Register(h)
+	// Register之后注册的路由也会出现在文档中
+	getUser := func(ctx *lr.Context, req struct {
+		ID int `path:"id"`
+	}) (*User, error) {
+		return nil, nil
+	}
+	lr.HandleTyped(h, http.MethodGet, "/user/:id", getUser)
+
+	recorder := httptest.NewRecorder()
+	h.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/openapi.json", nil))
+	require.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "application/json", recorder.Header().Get("Content-Type"))
+
+	var doc map[string]any
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &doc))
+	assert.Equal(t, "3.1.0", doc["openapi"])
+	paths := doc["paths"].(map[string]any)
+	assert.Contains(t, paths, "/user/{id}")
+	assert.NotContains(t, paths, "/api/openapi.json")
+	assert.NotContains(t, paths, "/docs")
+
+	// 第一次请求之后注册的路由同样会出现在文档中
+	h.DELETE("/user/:id", func(ctx *lr.Context) {})
+	recorder = httptest.NewRecorder()
+	h.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/openapi.json", nil))
+	require.Equal(t, http.StatusOK, recorder.Code)
+	doc = nil
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &doc))
+	item := doc["paths"].(map[string]any)["/user/{id}"].(map[string]any)
+	assert.Contains(t, item, "get")
+	assert.Contains(t, item, "delete")
+
+	recorder = httptest.NewRecorder()
+	h.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/docs", nil))
+	require.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "text/html; charset=utf-8", recorder.Header().Get("Content-Type"))
+	page := recorder.Body.String()
+	assert.Contains(t, page, `var docURL = "/api/openapi.json";`)
+	assert.NotContains(t, page, "http://")
+	assert.NotContains(t, page, "https://")
+}
+
+func TestSanitizeName(t *testing.T) {
+	assert.Equal(t, "Page_github.com_liquanhui-99_lr.User", sanitizeName("Page[github.com/liquanhui-99/lr.User]"))
+}
+
+func keys[V any](m map[string]V) []string {
+	res := make([]string, 0, len(m))
+	for key := range m {
+		res = append(res, key)
+	}
+	return res
+}
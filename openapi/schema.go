@@ -0,0 +1,364 @@
+package openapi
+
+import (
+	"encoding"
+	"encoding/json"
+	"mime/multipart"
+	"path"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	timeType          = reflect.TypeOf(time.Time{})
+	durationType      = reflect.TypeOf(time.Duration(0))
+	rawMessageType    = reflect.TypeOf(json.RawMessage(nil))
+	fileHeaderType    = reflect.TypeOf(multipart.FileHeader{})
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+)
+
+// schemaRegistry 具名结构体注册为components中的schema，其他地方通过$ref引用
+type schemaRegistry struct {
+	schemas map[string]*Schema
+	names   map[reflect.Type]string
+}
+
+func newSchemaRegistry() *schemaRegistry {
+	return &schemaRegistry{
+		schemas: make(map[string]*Schema),
+		names:   make(map[reflect.Type]string),
+	}
+}
+
+// schemaOf 返回类型对应的schema，具名结构体返回$ref
+func (r *schemaRegistry) schemaOf(typ reflect.Type) *Schema {
+	for typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+
+	switch {
+	case typ == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case typ == durationType:
+		return &Schema{Type: "integer", Format: "int64", Description: "纳秒"}
+	case typ == fileHeaderType:
+		return &Schema{Type: "string", Format: "binary"}
+	case typ == rawMessageType:
+		return &Schema{}
+	case implements(typ, jsonMarshalerType):
+		// 自定义序列化的类型无法推断结构
+		return &Schema{}
+	case implements(typ, textMarshalerType):
+		return &Schema{Type: "string"}
+	}
+
+	switch typ.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Int8, reflect.Int16, reflect.Int32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return &Schema{Type: "integer", Minimum: float64Ptr(0)}
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if typ.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", ContentEncoding: "base64"}
+		}
+		return &Schema{Type: "array", Items: r.schemaOf(typ.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: r.schemaOf(typ.Elem())}
+	case reflect.Struct:
+		if typ.Name() == "" {
+			return r.objectSchema(typ, nil)
+		}
+		return &Schema{Ref: "#/components/schemas/" + r.register(typ)}
+	}
+	// interface、func、chan之类的类型
+	return &Schema{}
+}
+
+// register 注册具名结构体，返回components中的名字
+func (r *schemaRegistry) register(typ reflect.Type) string {
+	if name, ok := r.names[typ]; ok {
+		return name
+	}
+
+	name := r.uniqueName(typ)
+	r.names[typ] = name
+	// 先占位再生成，避免递归类型无限展开
+	r.schemas[name] = &Schema{}
+	*r.schemas[name] = *r.objectSchema(typ, nil)
+	return name
+}
+
+// uniqueName 默认使用类型名，和其他包的同名类型冲突时加上包名
+func (r *schemaRegistry) uniqueName(typ reflect.Type) string {
+	name := sanitizeName(typ.Name())
+	if _, ok := r.schemas[name]; !ok {
+		return name
+	}
+	qualified := sanitizeName(path.Base(typ.PkgPath()) + "." + typ.Name())
+	if _, ok := r.schemas[qualified]; !ok {
+		return qualified
+	}
+	for i := 2; ; i++ {
+		candidate := qualified + strconv.Itoa(i)
+		if _, ok := r.schemas[candidate]; !ok {
+			return candidate
+		}
+	}
+}
+
+// objectSchema 按照json标签生成对象的schema，skip返回true的字段不会出现在结果中
+// 嵌入的结构体字段提升到当前层级，和encoding/json的行为一致
+func (r *schemaRegistry) objectSchema(typ reflect.Type, skip func(sf reflect.StructField) bool) *Schema {
+	s := &Schema{Type: "object"}
+	r.addProperties(s, typ, skip)
+	return s
+}
+
+func (r *schemaRegistry) addProperties(s *Schema, typ reflect.Type, skip func(sf reflect.StructField) bool) {
+	for i := 0; i < typ.NumField(); i++ {
+		sf := typ.Field(i)
+		tag := sf.Tag.Get("json")
+		if tag == "-" || (skip != nil && skip(sf)) {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+
+		if sf.Anonymous && name == "" {
+			ft := sf.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				r.addProperties(s, ft, skip)
+				continue
+			}
+		}
+		if !sf.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+
+		prop := r.fieldSchema(sf)
+		if hasOption(opts, "string") {
+			// ,string选项把数字和布尔值编码为字符串
+			prop = &Schema{Type: "string", Description: prop.Description}
+		}
+		if s.Properties == nil {
+			s.Properties = make(map[string]*Schema)
+		}
+		s.Properties[name] = prop
+		if isRequired(sf) {
+			s.Required = append(s.Required, name)
+		}
+	}
+}
+
+// fieldSchema 字段类型的schema，再叠加validate、default和description标签
+func (r *schemaRegistry) fieldSchema(sf reflect.StructField) *Schema {
+	s := r.schemaOf(sf.Type)
+	applyValidateTag(s, sf.Type, sf.Tag.Get("validate"))
+	if desc := sf.Tag.Get("description"); desc != "" {
+		s.Description = desc
+	}
+	if def, ok := sf.Tag.Lookup("default"); ok {
+		s.Default = convertLiteral(sf.Type, def)
+	}
+	return s
+}
+
+// applyValidateTag 把能够用JSON Schema表达的校验规则写入schema，dive之后的规则作用于元素
+func applyValidateTag(s *Schema, typ reflect.Type, tag string) {
+	if tag == "" || tag == "-" {
+		return
+	}
+	for typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+
+	rules := strings.Split(tag, ",")
+	for i, rule := range rules {
+		name, param, _ := strings.Cut(strings.TrimSpace(rule), "=")
+		if name == "dive" {
+			elem := s.Items
+			if typ.Kind() == reflect.Map {
+				elem = s.AdditionalProperties
+			}
+			if elem != nil && (typ.Kind() == reflect.Slice || typ.Kind() == reflect.Array || typ.Kind() == reflect.Map) {
+				applyValidateTag(elem, typ.Elem(), strings.Join(rules[i+1:], ","))
+			}
+			return
+		}
+		applyRule(s, typ, name, param)
+	}
+}
+
+func applyRule(s *Schema, typ reflect.Type, name, param string) {
+	kind := typ.Kind()
+	switch name {
+	case "email":
+		s.Format = "email"
+	case "url":
+		s.Format = "uri"
+	case "uuid":
+		s.Format = "uuid"
+	case "alpha":
+		s.Pattern = `^[a-zA-Z]+$`
+	case "alphanum":
+		s.Pattern = `^[a-zA-Z0-9]+$`
+	case "numeric":
+		s.Pattern = `^[-+]?[0-9]+(\.[0-9]+)?$`
+	case "oneof":
+		for _, v := range strings.Fields(param) {
+			s.Enum = append(s.Enum, convertLiteral(typ, v))
+		}
+	case "eq":
+		s.Enum = []any{convertLiteral(typ, param)}
+	case "len", "min", "max", "gt", "gte", "lt", "lte":
+		n, err := strconv.ParseFloat(param, 64)
+		if err != nil {
+			return
+		}
+		switch {
+		case isNumberKind(kind):
+			applyNumberBound(s, name, n)
+		case kind == reflect.String || kind == reflect.Slice || kind == reflect.Array:
+			applyLengthBound(s, kind == reflect.String, name, int(n))
+		}
+	}
+}
+
+func applyNumberBound(s *Schema, name string, n float64) {
+	switch name {
+	case "len":
+		s.Minimum, s.Maximum = float64Ptr(n), float64Ptr(n)
+	case "min", "gte":
+		s.Minimum = float64Ptr(n)
+	case "max", "lte":
+		s.Maximum = float64Ptr(n)
+	case "gt":
+		s.ExclusiveMinimum = float64Ptr(n)
+	case "lt":
+		s.ExclusiveMaximum = float64Ptr(n)
+	}
+}
+
+func applyLengthBound(s *Schema, isString bool, name string, n int) {
+	minP, maxP := &s.MinItems, &s.MaxItems
+	if isString {
+		minP, maxP = &s.MinLength, &s.MaxLength
+	}
+	switch name {
+	case "len":
+		*minP, *maxP = intPtr(n), intPtr(n)
+	case "min", "gte":
+		*minP = intPtr(n)
+	case "max", "lte":
+		*maxP = intPtr(n)
+	case "gt":
+		*minP = intPtr(n + 1)
+	case "lt":
+		*maxP = intPtr(n - 1)
+	}
+}
+
+// isRequired validate标签在dive之前包含required
+func isRequired(sf reflect.StructField) bool {
+	for _, rule := range strings.Split(sf.Tag.Get("validate"), ",") {
+		switch strings.TrimSpace(rule) {
+		case "required":
+			return true
+		case "dive":
+			return false
+		}
+	}
+	return false
+}
+
+// convertLiteral 把标签中的字面量转换为和字段类型一致的json值，转换失败时保留字符串
+func convertLiteral(typ reflect.Type, val string) any {
+	for typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	switch typ.Kind() {
+	case reflect.Bool:
+		if b, err := strconv.ParseBool(val); err == nil {
+			return b
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if typ == durationType {
+			return val
+		}
+		if n, err := strconv.ParseInt(val, 10, 64); err == nil {
+			return n
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if n, err := strconv.ParseUint(val, 10, 64); err == nil {
+			return n
+		}
+	case reflect.Float32, reflect.Float64:
+		if n, err := strconv.ParseFloat(val, 64); err == nil {
+			return n
+		}
+	}
+	return val
+}
+
+func hasOption(opts, option string) bool {
+	for _, opt := range strings.Split(opts, ",") {
+		if opt == option {
+			return true
+		}
+	}
+	return false
+}
+
+func isNumberKind(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+func implements(typ, iface reflect.Type) bool {
+	return typ.Implements(iface) || reflect.PointerTo(typ).Implements(iface)
+}
+
+// sanitizeName components的名字只能包含字母、数字、.、-和_，泛型类型名中的其他字符替换为_
+func sanitizeName(name string) string {
+	var sb strings.Builder
+	for _, ch := range name {
+		switch {
+		case ch >= 'a' && ch <= 'z', ch >= 'A' && ch <= 'Z', ch >= '0' && ch <= '9', ch == '.', ch == '-', ch == '_':
+			sb.WriteRune(ch)
+		default:
+			sb.WriteByte('_')
+		}
+	}
+	return strings.Trim(sb.String(), "_")
+}
+
+func float64Ptr(n float64) *float64 {
+	return &n
+}
+
+func intPtr(n int) *int {
+	return &n
+}
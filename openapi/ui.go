@@ -0,0 +1,18 @@
+package openapi
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+)
+
+// uiTemplate 不依赖任何外部资源的文档页面，离线环境也可以使用
+//
+//go:embed ui/index.html
+var uiTemplate []byte
+
+// uiPage 把文档的地址写入页面，json编码会转义<、>和&，可以安全地放在script中
+func uiPage(docPath string) []byte {
+	url, _ := json.Marshal(docPath)
+	return bytes.Replace(uiTemplate, []byte("__OPENAPI_URL__"), url, 1)
+}
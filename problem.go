@@ -0,0 +1,114 @@
+package lr
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// problemContentType RFC 7807规定的媒体类型
+const problemContentType = "application/problem+json"
+
+// Problem RFC 7807 Problem Details，handler可以直接返回或者通过RespProblem设置
+type Problem struct {
+	// 问题类型的URI，为空时按照about:blank处理
+	Type string `json:"type,omitempty"`
+	// 问题类型的简短描述
+	Title string `json:"title,omitempty"`
+	// http状态码
+	Status int `json:"status,omitempty"`
+	// 本次请求的具体描述
+	Detail string `json:"detail,omitempty"`
+	// 出现问题的资源，为空时使用请求路径
+	Instance string `json:"instance,omitempty"`
+	// 扩展字段，和标准字段平级输出
+	Extensions map[string]any `json:"-"`
+}
+
+// NewProblem 创建Problem，Title使用状态码的标准描述
+func NewProblem(status int, detail string) *Problem {
+	return &Problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+	}
+}
+
+// With 添加扩展字段
+func (p *Problem) With(key string, val any) *Problem {
+	if p.Extensions == nil {
+		p.Extensions = make(map[string]any, 4)
+	}
+	p.Extensions[key] = val
+	return p
+}
+
+func (p *Problem) Error() string {
+	return fmt.Sprintf("lr: %d %s: %s", p.Status, p.Title, p.Detail)
+}
+
+// MarshalJSON 扩展字段不能覆盖标准字段
+func (p *Problem) MarshalJSON() ([]byte, error) {
+	type problem Problem
+	data, err := json.Marshal((*problem)(p))
+	if err != nil || len(p.Extensions) == 0 {
+		return data, err
+	}
+
+	members := make(map[string]any, len(p.Extensions)+5)
+	for key, val := range p.Extensions {
+		members[key] = val
+	}
+	var std map[string]any
+	if err = json.Unmarshal(data, &std); err != nil {
+		return nil, err
+	}
+	for key, val := range std {
+		members[key] = val
+	}
+	return json.Marshal(members)
+}
+
+// RespProblem 返回application/problem+json响应
+// 补充的Status和Instance写在副本上，p可以是多个请求共用的变量
+func (c *Context) RespProblem(p *Problem) error {
+	cp := *p
+	p = &cp
+	if p.Status == 0 {
+		p.Status = http.StatusInternalServerError
+	}
+	if p.Instance == "" && c.Req.URL != nil {
+		p.Instance = c.Req.URL.Path
+	}
+	data, err := c.marshalJSON(p)
+	if err != nil {
+		return err
+	}
+	c.setResp(p.Status, problemContentType, data)
+	return nil
+}
+
+// PrefersJSON 客户端是否更希望得到json而不是html
+// 没有Accept或者只有*/*时返回false，保持返回html页面的行为
+func (c *Context) PrefersJSON() bool {
+	accept := c.Req.Header.Get("Accept")
+	if accept == "" {
+		return false
+	}
+	ranges := parseQualityValues(accept)
+	jsonQ, jsonS, _ := matchMediaRange(ranges, "application/json")
+	if q, s, ok := matchMediaRange(ranges, problemContentType); ok && (q > jsonQ || (q == jsonQ && s > jsonS)) {
+		jsonQ, jsonS = q, s
+	}
+	htmlQ, htmlS, _ := matchMediaRange(ranges, "text/html")
+	if jsonQ <= 0 {
+		return false
+	}
+	return jsonQ > htmlQ || (jsonQ == htmlQ && jsonS > htmlS)
+}
+
+// IsProblem 响应是否已经是problem文档，中间件可以据此避免覆盖handler设置的错误
+func (c *Context) IsProblem() bool {
+	return baseMediaType(c.Resp.Header().Get("Content-Type")) == problemContentType
+}
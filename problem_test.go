@@ -0,0 +1,120 @@
+package lr
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProblem_MarshalJSON(t *testing.T) {
+	p := NewProblem(http.StatusForbidden, "余额不足").With("balance", 30).With("status", 200)
+	p.Type = "https://example.com/probs/out-of-credit"
+	data, err := json.Marshal(p)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"type": "https://example.com/probs/out-of-credit",
+		"title": "Forbidden",
+		"status": 403,
+		"detail": "余额不足",
+		"balance": 30
+	}`, string(data))
+}
+
+func TestServe_Problem(t *testing.T) {
+	testCases := []struct {
+		name       string
+		method     string
+		path       string
+		accept     string
+		wantStatus int
+		wantType   string
+		wantBody   string
+		wantAllow  string
+	}{
+		{
+			name:       "404 html",
+			method:     http.MethodGet,
+			path:       "/missing",
+			accept:     "text/html,application/xhtml+xml,*/*;q=0.8",
+			wantStatus: http.StatusNotFound,
+			wantBody:   "NOT FOUND",
+		},
+		{
+			name:       "404 json",
+			method:     http.MethodGet,
+			path:       "/missing",
+			accept:     "application/json",
+			wantStatus: http.StatusNotFound,
+			wantType:   "application/problem+json",
+			wantBody:   `{"type":"about:blank","title":"Not Found","status":404,"instance":"/missing"}`,
+		},
+		{
+			name:       "405 problem",
+			method:     http.MethodDelete,
+			path:       "/user",
+			accept:     "application/problem+json",
+			wantStatus: http.StatusMethodNotAllowed,
+			wantType:   "application/problem+json",
+			wantBody:   `{"type":"about:blank","title":"Method Not Allowed","status":405,"instance":"/user"}`,
+			wantAllow:  "GET, POST",
+		},
+		{
+			name:       "405 text",
+			method:     http.MethodPut,
+			path:       "/user",
+			wantStatus: http.StatusMethodNotAllowed,
+			wantBody:   "METHOD NOT ALLOWED",
+			wantAllow:  "GET, POST",
+		},
+		{
+			name:       "handler返回Problem",
+			method:     http.MethodPost,
+			path:       "/user",
+			wantStatus: http.StatusConflict,
+			wantType:   "application/problem+json",
+			wantBody:   `{"type":"about:blank","title":"Conflict","status":409,"detail":"用户名已存在","instance":"/user"}`,
+		},
+	}
+
+	h := NewHTTPServer("tcp", ":8081")
+	h.GET("/user", func(ctx *Context) {})
+	h.POST("/user", Handle(func(ctx *Context) error {
+		return NewProblem(http.StatusConflict, "用户名已存在")
+	}))
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, tc.path, nil)
+			if tc.accept != "" {
+				req.Header.Set("Accept", tc.accept)
+			}
+			recorder := httptest.NewRecorder()
+			h.ServeHTTP(recorder, req)
+
+			assert.Equal(t, tc.wantStatus, recorder.Code)
+			assert.Equal(t, tc.wantBody, recorder.Body.String())
+			assert.Equal(t, tc.wantAllow, recorder.Header().Get("Allow"))
+			if tc.wantType != "" {
+				assert.Equal(t, tc.wantType, recorder.Header().Get("Content-Type"))
+			}
+		})
+	}
+}
+
+func TestContext_RespProblem_Shared(t *testing.T) {
+	// 多个请求共用同一个Problem时，不能带上第一个请求的路径
+	errNoStatus := &Problem{Type: "https://example.com/probs/unknown", Title: "未知错误"}
+	for _, path := range []string{"/a", "/b"} {
+		recorder := httptest.NewRecorder()
+		ctx := &Context{Req: httptest.NewRequest(http.MethodGet, path, nil), Resp: recorder}
+		require.NoError(t, ctx.RespProblem(errNoStatus))
+		assert.Equal(t, http.StatusInternalServerError, ctx.Status)
+		assert.JSONEq(t, `{"type":"https://example.com/probs/unknown","title":"未知错误","status":500,"instance":"`+path+`"}`, string(ctx.RespData))
+	}
+	assert.Equal(t, 0, errNoStatus.Status)
+	assert.Empty(t, errNoStatus.Instance)
+}
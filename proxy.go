@@ -0,0 +1,272 @@
+package lr
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// TrustedProxies 设置可信的代理，支持CIDR和单个IP
+// 只有直接连接的对端在列表中时，ClientIP、Scheme和Host才会使用Forwarded、X-Forwarded-*和X-Real-IP请求头
+//
+//	lr.TrustedProxies("10.0.0.0/8", "192.168.1.10", "::1")
+func TrustedProxies(cidrs ...string) HTTPServerOptions {
+	nets := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		nets = append(nets, parseTrustedProxy(cidr))
+	}
+	return func(s *HTTPServer) {
+		s.trustedProxies = nets
+	}
+}
+
+func parseTrustedProxy(cidr string) *net.IPNet {
+	if !strings.Contains(cidr, "/") {
+		ip := net.ParseIP(cidr)
+		if ip == nil {
+			panic(fmt.Sprintf("lr: 非法的代理地址 [%s]", cidr))
+		}
+		bits := 8 * net.IPv6len
+		if ip4 := ip.To4(); ip4 != nil {
+			ip, bits = ip4, 8*net.IPv4len
+		}
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}
+	}
+
+	_, ipNet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		panic(fmt.Sprintf("lr: 非法的代理地址 [%s]: %v", cidr, err))
+	}
+	return ipNet
+}
+
+// ClientIP 客户端的真实地址
+// 对端是可信代理时，依次使用Forwarded的for、X-Forwarded-For、X-Real-IP，
+// 代理链从右往左跳过可信代理，第一个不可信的地址就是客户端，防止客户端伪造请求头。
+// 使用了前面的请求头时不再查看后面的，代理链中遇到unknown或者混淆过的标识时返回最后一个可信代理，
+// 没有可信代理时返回对端地址
+func (c *Context) ClientIP() string {
+	peer := c.peerIP()
+	if !c.isTrustedProxy(peer) {
+		return peer
+	}
+
+	if elements := forwardedElements(c.Req.Header.Values("Forwarded")); len(elements) > 0 {
+		chain := make([]string, len(elements))
+		for i, element := range elements {
+			chain[i] = element["for"]
+		}
+		return c.clientFromChain(chain, peer)
+	}
+	if chain := splitHeaderList(c.Req.Header.Values("X-Forwarded-For")); len(chain) > 0 {
+		return c.clientFromChain(chain, peer)
+	}
+	if ip := parseForwardedIP(c.Req.Header.Get("X-Real-IP")); ip != "" {
+		return ip
+	}
+	return peer
+}
+
+// Scheme 客户端请求使用的协议，http或者https
+// 对端是可信代理时，使用最靠近客户端的可信代理写入的Forwarded的proto或者X-Forwarded-Proto，见forwardedParam，
+// 其他的值会被忽略
+func (c *Context) Scheme() string {
+	if c.isTrustedProxy(c.peerIP()) {
+		switch proto := strings.ToLower(c.forwardedParam("proto", "X-Forwarded-Proto")); proto {
+		case "http", "https":
+			return proto
+		}
+	}
+	if c.Req.TLS != nil {
+		return "https"
+	}
+	return "http"
+}
+
+// Host 客户端请求的Host
+// 对端是可信代理时，使用最靠近客户端的可信代理写入的Forwarded的host或者X-Forwarded-Host，见forwardedParam
+func (c *Context) Host() string {
+	if c.isTrustedProxy(c.peerIP()) {
+		if host := c.forwardedParam("host", "X-Forwarded-Host"); host != "" {
+			return host
+		}
+	}
+	return c.Req.Host
+}
+
+// forwardedParam 和ClientIP一样从右往左跳过可信代理，取最靠近客户端的可信代理写入的值，客户端自己带上的值在它的左边
+//
+// 有Forwarded时只使用Forwarded：每个元素的for和其他参数是同一个代理写入的，直接取对应元素的key。
+// 否则使用xHeader：值的数量和X-Forwarded-For相同时认为每个代理都追加了一个值，按照X-Forwarded-For的下标取；
+// 数量不同时说明有代理覆盖了这个请求头，只有直接连接的代理写入的最后一个值可信
+func (c *Context) forwardedParam(key, xHeader string) string {
+	if elements := forwardedElements(c.Req.Header.Values("Forwarded")); len(elements) > 0 {
+		chain := make([]string, len(elements))
+		for i, element := range elements {
+			chain[i] = element["for"]
+		}
+		return elements[c.hopIndex(chain)][key]
+	}
+
+	values := splitHeaderList(c.Req.Header.Values(xHeader))
+	if len(values) == 0 {
+		return ""
+	}
+	if chain := splitHeaderList(c.Req.Header.Values("X-Forwarded-For")); len(chain) == len(values) {
+		return values[c.hopIndex(chain)]
+	}
+	return values[len(values)-1]
+}
+
+// hopIndex 从右往左跳过可信代理，返回第一个不可信或者无法解析的地址的下标
+// 这个位置的值是最靠近客户端的可信代理写入的，全部可信时返回0
+func (c *Context) hopIndex(chain []string) int {
+	for i := len(chain) - 1; i > 0; i-- {
+		ip := parseForwardedIP(chain[i])
+		if ip == "" || !c.isTrustedProxy(ip) {
+			return i
+		}
+	}
+	return 0
+}
+
+// peerIP 直接连接的对端地址
+func (c *Context) peerIP() string {
+	host, _, err := net.SplitHostPort(strings.TrimSpace(c.Req.RemoteAddr))
+	if err != nil {
+		return strings.TrimSpace(c.Req.RemoteAddr)
+	}
+	return host
+}
+
+func (c *Context) isTrustedProxy(addr string) bool {
+	if len(c.trustedProxies) == 0 {
+		return false
+	}
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+	for _, ipNet := range c.trustedProxies {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientFromChain 从右往左跳过可信代理，全部可信时返回最左边的地址
+// 遇到unknown或者混淆过的标识时无法继续判断，返回最后一个可信代理，没有时返回peer
+func (c *Context) clientFromChain(chain []string, peer string) string {
+	res := peer
+	for i := len(chain) - 1; i >= 0; i-- {
+		ip := parseForwardedIP(chain[i])
+		if ip == "" {
+			return res
+		}
+		res = ip
+		if !c.isTrustedProxy(ip) {
+			return ip
+		}
+	}
+	return res
+}
+
+// parseForwardedIP 支持1.2.3.4、1.2.3.4:80、[::1]:80和带引号的值，非法的值返回空字符串
+func parseForwardedIP(val string) string {
+	val = strings.Trim(strings.TrimSpace(val), `"`)
+	if strings.HasPrefix(val, "[") {
+		end := strings.IndexByte(val, ']')
+		if end < 0 {
+			return ""
+		}
+		val = val[1:end]
+	} else if strings.Count(val, ":") == 1 {
+		val, _, _ = strings.Cut(val, ":")
+	}
+
+	ip := net.ParseIP(val)
+	if ip == nil {
+		return ""
+	}
+	return ip.String()
+}
+
+// forwardedElements 按照RFC 7239解析Forwarded请求头中的每个元素，参数名转为小写
+//
+//	Forwarded: for=192.0.2.60;proto=http;by=203.0.113.43, for="[2001:db8:cafe::17]:4711"
+func forwardedElements(headers []string) []map[string]string {
+	var res []map[string]string
+	for _, header := range headers {
+		for _, element := range splitQuoted(header, ',') {
+			if strings.TrimSpace(element) == "" {
+				continue
+			}
+			params := make(map[string]string, 4)
+			for _, pair := range splitQuoted(element, ';') {
+				k, v, ok := strings.Cut(strings.TrimSpace(pair), "=")
+				if !ok {
+					continue
+				}
+				params[strings.ToLower(strings.TrimSpace(k))] = unquoteForwarded(strings.TrimSpace(v))
+			}
+			res = append(res, params)
+		}
+	}
+	return res
+}
+
+// splitQuoted 按照sep切分，忽略引号中的sep
+func splitQuoted(s string, sep byte) []string {
+	var (
+		res     []string
+		quoted  bool
+		escaped bool
+		start   int
+	)
+	for i := 0; i < len(s); i++ {
+		switch {
+		case escaped:
+			escaped = false
+		case quoted && s[i] == '\\':
+			escaped = true
+		case s[i] == '"':
+			quoted = !quoted
+		case !quoted && s[i] == sep:
+			res = append(res, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(res, s[start:])
+}
+
+func unquoteForwarded(v string) string {
+	if len(v) < 2 || v[0] != '"' || v[len(v)-1] != '"' {
+		return v
+	}
+	v = v[1 : len(v)-1]
+	if !strings.Contains(v, `\`) {
+		return v
+	}
+	var sb strings.Builder
+	for i := 0; i < len(v); i++ {
+		if v[i] == '\\' && i+1 < len(v) {
+			i++
+		}
+		sb.WriteByte(v[i])
+	}
+	return sb.String()
+}
+
+// splitHeaderList 多个请求头和逗号分隔的值合并为一个列表
+func splitHeaderList(headers []string) []string {
+	var res []string
+	for _, header := range headers {
+		for _, v := range strings.Split(header, ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				res = append(res, v)
+			}
+		}
+	}
+	return res
+}
@@ -0,0 +1,252 @@
+package lr
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContext_ClientIP(t *testing.T) {
+	testCases := []struct {
+		name       string
+		trusted    []string
+		remoteAddr string
+		headers    map[string][]string
+		want       string
+	}{
+		{
+			name:       "没有可信代理",
+			remoteAddr: "203.0.113.7:5000",
+			headers:    map[string][]string{"X-Forwarded-For": {"1.1.1.1"}},
+			want:       "203.0.113.7",
+		},
+		{
+			name:       "对端不可信",
+			trusted:    []string{"10.0.0.0/8"},
+			remoteAddr: "203.0.113.7:5000",
+			headers:    map[string][]string{"X-Forwarded-For": {"1.1.1.1"}, "X-Real-IP": {"2.2.2.2"}},
+			want:       "203.0.113.7",
+		},
+		{
+			name:       "X-Forwarded-For",
+			trusted:    []string{"10.0.0.0/8"},
+			remoteAddr: "10.0.0.2:5000",
+			headers:    map[string][]string{"X-Forwarded-For": {"1.1.1.1"}},
+			want:       "1.1.1.1",
+		},
+		{
+			name:       "X-Forwarded-For跳过可信代理",
+			trusted:    []string{"10.0.0.0/8"},
+			remoteAddr: "10.0.0.2:5000",
+			headers:    map[string][]string{"X-Forwarded-For": {"6.6.6.6, 1.1.1.1", "10.0.0.3"}},
+			want:       "1.1.1.1",
+		},
+		{
+			name:       "全部可信返回最左边",
+			trusted:    []string{"10.0.0.0/8"},
+			remoteAddr: "10.0.0.2:5000",
+			headers:    map[string][]string{"X-Forwarded-For": {"10.0.0.5, 10.0.0.3"}},
+			want:       "10.0.0.5",
+		},
+		{
+			name:       "Forwarded优先",
+			trusted:    []string{"10.0.0.2"},
+			remoteAddr: "10.0.0.2:5000",
+			headers: map[string][]string{
+				"Forwarded":       {`for=192.0.2.60;proto=https, for="[2001:db8:cafe::17]:4711"`},
+				"X-Forwarded-For": {"1.1.1.1"},
+			},
+			want: "2001:db8:cafe::17",
+		},
+		{
+			name:       "Forwarded带端口",
+			trusted:    []string{"::1"},
+			remoteAddr: "[::1]:5000",
+			headers:    map[string][]string{"Forwarded": {`For="192.0.2.43:47011"`}},
+			want:       "192.0.2.43",
+		},
+		{
+			name:       "X-Real-IP",
+			trusted:    []string{"10.0.0.0/8"},
+			remoteAddr: "10.0.0.2:5000",
+			headers:    map[string][]string{"X-Real-IP": {"2.2.2.2"}},
+			want:       "2.2.2.2",
+		},
+		{
+			name:       "Forwarded中是unknown时不使用X-Forwarded-For",
+			trusted:    []string{"10.0.0.0/8"},
+			remoteAddr: "10.0.0.2:5000",
+			headers: map[string][]string{
+				"Forwarded":       {"for=unknown, for=10.0.0.3"},
+				"X-Forwarded-For": {"6.6.6.6"},
+				"X-Real-IP":       {"7.7.7.7"},
+			},
+			want: "10.0.0.3",
+		},
+		{
+			name:       "Forwarded中是混淆的标识",
+			trusted:    []string{"10.0.0.0/8"},
+			remoteAddr: "10.0.0.2:5000",
+			headers: map[string][]string{
+				"Forwarded":       {"for=_hidden"},
+				"X-Forwarded-For": {"6.6.6.6"},
+			},
+			want: "10.0.0.2",
+		},
+		{
+			name:       "X-Forwarded-For中是unknown时不使用X-Real-IP",
+			trusted:    []string{"10.0.0.0/8"},
+			remoteAddr: "10.0.0.2:5000",
+			headers:    map[string][]string{"X-Forwarded-For": {"unknown"}, "X-Real-IP": {"7.7.7.7"}},
+			want:       "10.0.0.2",
+		},
+		{
+			name:       "非法的请求头",
+			trusted:    []string{"10.0.0.0/8"},
+			remoteAddr: "10.0.0.2:5000",
+			headers:    map[string][]string{"X-Forwarded-For": {"unknown"}, "X-Real-IP": {"abc"}},
+			want:       "10.0.0.2",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			h := NewHTTPServer("tcp", ":8081", TrustedProxies(tc.trusted...))
+			var got string
+			h.GET("/", func(ctx *Context) {
+				got = ctx.ClientIP()
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tc.remoteAddr
+			for key, vals := range tc.headers {
+				for _, val := range vals {
+					req.Header.Add(key, val)
+				}
+			}
+			h.ServeHTTP(httptest.NewRecorder(), req)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestContext_SchemeHost(t *testing.T) {
+	testCases := []struct {
+		name       string
+		remoteAddr string
+		tls        bool
+		headers    map[string]string
+		wantScheme string
+		wantHost   string
+	}{
+		{
+			name:       "不可信",
+			remoteAddr: "203.0.113.7:5000",
+			headers:    map[string]string{"X-Forwarded-Proto": "https", "X-Forwarded-Host": "evil.com"},
+			wantScheme: "http",
+			wantHost:   "example.com",
+		},
+		{
+			name:       "TLS",
+			remoteAddr: "203.0.113.7:5000",
+			tls:        true,
+			wantScheme: "https",
+			wantHost:   "example.com",
+		},
+		{
+			name:       "X-Forwarded",
+			remoteAddr: "10.0.0.2:5000",
+			headers:    map[string]string{"X-Forwarded-Proto": "HTTPS", "X-Forwarded-Host": "api.example.com"},
+			wantScheme: "https",
+			wantHost:   "api.example.com",
+		},
+		{
+			name:       "X-Forwarded多级代理",
+			remoteAddr: "10.0.0.2:5000",
+			headers: map[string]string{
+				"X-Forwarded-For":   "1.1.1.1, 10.0.0.3",
+				"X-Forwarded-Proto": "https, http",
+				"X-Forwarded-Host":  "api.example.com, internal.lb",
+			},
+			wantScheme: "https",
+			wantHost:   "api.example.com",
+		},
+		{
+			name:       "客户端伪造X-Forwarded-Host，代理追加",
+			remoteAddr: "10.0.0.2:5000",
+			headers: map[string]string{
+				"X-Forwarded-For":   "6.6.6.6, 1.1.1.1",
+				"X-Forwarded-Proto": "http, https",
+				"X-Forwarded-Host":  "evil.com, api.example.com",
+			},
+			wantScheme: "https",
+			wantHost:   "api.example.com",
+		},
+		{
+			name:       "客户端伪造X-Forwarded-Host，数量和X-Forwarded-For不一致",
+			remoteAddr: "10.0.0.2:5000",
+			headers: map[string]string{
+				"X-Forwarded-For":  "1.1.1.1",
+				"X-Forwarded-Host": "evil.com, api.example.com",
+			},
+			wantScheme: "http",
+			wantHost:   "api.example.com",
+		},
+		{
+			name:       "客户端伪造Forwarded",
+			remoteAddr: "10.0.0.2:5000",
+			headers: map[string]string{
+				"Forwarded": `for=6.6.6.6;proto=http;host=evil.com, for=1.1.1.1;proto=https;host="shop.example.com", for=10.0.0.3`,
+			},
+			wantScheme: "https",
+			wantHost:   "shop.example.com",
+		},
+		{
+			name:       "非法的proto",
+			remoteAddr: "10.0.0.2:5000",
+			tls:        true,
+			headers:    map[string]string{"X-Forwarded-Proto": "javascript"},
+			wantScheme: "https",
+			wantHost:   "example.com",
+		},
+		{
+			name:       "Forwarded",
+			remoteAddr: "10.0.0.2:5000",
+			headers: map[string]string{
+				"Forwarded":         `for=1.1.1.1;proto=https;host="shop.example.com"`,
+				"X-Forwarded-Proto": "http",
+			},
+			wantScheme: "https",
+			wantHost:   "shop.example.com",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "http://example.com/", nil)
+			req.RemoteAddr = tc.remoteAddr
+			if tc.tls {
+				req.TLS = &tls.ConnectionState{}
+			}
+			for key, val := range tc.headers {
+				req.Header.Set(key, val)
+			}
+			ctx := &Context{Req: req, trustedProxies: []*net.IPNet{parseTrustedProxy("10.0.0.0/8")}}
+			assert.Equal(t, tc.wantScheme, ctx.Scheme())
+			assert.Equal(t, tc.wantHost, ctx.Host())
+		})
+	}
+}
+
+func TestTrustedProxies_Invalid(t *testing.T) {
+	assert.Panics(t, func() {
+		TrustedProxies("10.0.0.0/33")
+	})
+	assert.Panics(t, func() {
+		TrustedProxies("localhost")
+	})
+}
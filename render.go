@@ -0,0 +1,246 @@
+package lr
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+var ErrNotAcceptable = errors.New("lr: 没有可以满足Accept的响应格式")
+
+// Renderer 把数据编码为某一种格式的响应体
+type Renderer interface {
+	// ContentType 响应的Content-Type，例如 application/json
+	ContentType() string
+	// Render 编码数据
+	Render(val any) ([]byte, error)
+}
+
+// defaultRenderers 默认支持的格式，没有Accept请求头时使用第一个
+func defaultRenderers() []Renderer {
+	return []Renderer{
+		JSONRenderer{},
+		XMLRenderer{},
+		YAMLRenderer{},
+		MsgPackRenderer{},
+		TextRenderer{},
+	}
+}
+
+// JSONRenderer application/json
+type JSONRenderer struct {
+	// 为nil时使用encoding/json
+	Codec JSONCodec
+}
+
+func (JSONRenderer) ContentType() string {
+	return "application/json"
+}
+
+func (r JSONRenderer) Render(val any) ([]byte, error) {
+	if r.Codec != nil {
+		return r.Codec.Marshal(val)
+	}
+	return json.Marshal(val)
+}
+
+// XMLRenderer application/xml
+type XMLRenderer struct{}
+
+func (XMLRenderer) ContentType() string {
+	return "application/xml"
+}
+
+func (XMLRenderer) Render(val any) ([]byte, error) {
+	return xml.Marshal(val)
+}
+
+// YAMLRenderer application/yaml
+type YAMLRenderer struct{}
+
+func (YAMLRenderer) ContentType() string {
+	return "application/yaml"
+}
+
+func (YAMLRenderer) Render(val any) ([]byte, error) {
+	return yaml.Marshal(val)
+}
+
+// MsgPackRenderer application/msgpack
+type MsgPackRenderer struct{}
+
+func (MsgPackRenderer) ContentType() string {
+	return "application/msgpack"
+}
+
+func (MsgPackRenderer) Render(val any) ([]byte, error) {
+	return marshalMsgPack(val)
+}
+
+// TextRenderer text/plain，字符串和[]byte原样输出，其他类型使用fmt格式化
+type TextRenderer struct{}
+
+func (TextRenderer) ContentType() string {
+	return "text/plain; charset=utf-8"
+}
+
+func (TextRenderer) Render(val any) ([]byte, error) {
+	switch v := val.(type) {
+	case string:
+		return []byte(v), nil
+	case []byte:
+		return v, nil
+	case fmt.Stringer:
+		return []byte(v.String()), nil
+	case error:
+		return []byte(v.Error()), nil
+	}
+	return []byte(fmt.Sprintf("%v", val)), nil
+}
+
+// Renderers 注册响应格式，同一个媒体类型的Renderer会覆盖默认的实现
+func Renderers(rs ...Renderer) HTTPServerOptions {
+	return func(s *HTTPServer) {
+		for _, r := range rs {
+			s.RegisterRenderer(r)
+		}
+	}
+}
+
+// RegisterRenderer 注册响应格式，媒体类型已经存在时替换，否则追加到最后
+func (h *HTTPServer) RegisterRenderer(r Renderer) {
+	mediaType := baseMediaType(r.ContentType())
+	for i, exist := range h.renderers {
+		if baseMediaType(exist.ContentType()) == mediaType {
+			h.renderers[i] = r
+			return
+		}
+	}
+	h.renderers = append(h.renderers, r)
+}
+
+// Negotiate 根据Accept请求头(包括q值)选择响应格式并编码val
+// 没有Accept时使用第一个注册的格式，没有可以接受的格式时返回406和ErrNotAcceptable
+func (c *Context) Negotiate(status int, val any) error {
+	renderers := c.renderers
+	if len(renderers) == 0 {
+		renderers = defaultRenderers()
+	}
+	c.Resp.Header().Add("Vary", "Accept")
+
+	r, ok := selectRenderer(renderers, c.Req.Header.Get("Accept"))
+	if !ok {
+		types := make([]string, 0, len(renderers))
+		for _, r := range renderers {
+			types = append(types, baseMediaType(r.ContentType()))
+		}
+		supported := strings.Join(types, ", ")
+		c.setResp(http.StatusNotAcceptable, "text/plain; charset=utf-8",
+			[]byte(c.localize("lr.not_acceptable", "Not Acceptable, 支持的类型: "+supported, "types", supported)))
+		return ErrNotAcceptable
+	}
+
+	data, err := r.Render(val)
+	if err != nil {
+		return err
+	}
+	c.setResp(status, r.ContentType(), data)
+	return nil
+}
+
+// selectRenderer q值最高的优先，q值相同时匹配得更精确的优先，再相同时按照注册顺序
+func selectRenderer(renderers []Renderer, accept string) (Renderer, bool) {
+	if strings.TrimSpace(accept) == "" {
+		return renderers[0], true
+	}
+
+	ranges := parseQualityValues(accept)
+	var (
+		best            Renderer
+		bestQ           float64
+		bestSpecificity = -1
+	)
+	for _, r := range renderers {
+		q, specificity, ok := matchMediaRange(ranges, baseMediaType(r.ContentType()))
+		if !ok || q <= 0 {
+			continue
+		}
+		if q > bestQ || (q == bestQ && specificity > bestSpecificity) {
+			best, bestQ, bestSpecificity = r, q, specificity
+		}
+	}
+	return best, best != nil
+}
+
+// matchMediaRange 找到和mediaType匹配的最精确的范围，返回它的q值和精确程度
+// 精确程度 */* 为0，type/* 为1，type/subtype 为2
+func matchMediaRange(ranges []qualityValue, mediaType string) (float64, int, bool) {
+	typ, subtype, _ := strings.Cut(mediaType, "/")
+	q, specificity, found := 0.0, -1, false
+	for _, r := range ranges {
+		rt, rs, _ := strings.Cut(r.value, "/")
+		s := -1
+		switch {
+		case rt == typ && rs == subtype:
+			s = 2
+		case rt == typ && rs == "*":
+			s = 1
+		case rt == "*" && rs == "*":
+			s = 0
+		}
+		if s > specificity {
+			q, specificity, found = r.q, s, true
+		}
+	}
+	return q, specificity, found
+}
+
+// qualityValue Accept、Accept-Language这类带有q值的请求头中的一项
+type qualityValue struct {
+	value string
+	q     float64
+}
+
+// parseQualityValues 解析带q值的请求头，结果按照q值从高到低稳定排序
+func parseQualityValues(header string) []qualityValue {
+	var res []qualityValue
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(part, ";")
+		value := strings.ToLower(strings.TrimSpace(fields[0]))
+		if value == "" {
+			continue
+		}
+		item := qualityValue{value: value, q: 1}
+		for _, param := range fields[1:] {
+			key, val, _ := strings.Cut(strings.TrimSpace(param), "=")
+			if strings.TrimSpace(key) != "q" {
+				continue
+			}
+			if q, err := strconv.ParseFloat(strings.TrimSpace(val), 64); err == nil && q >= 0 && q <= 1 {
+				item.q = q
+			}
+		}
+		res = append(res, item)
+	}
+	sort.SliceStable(res, func(i, j int) bool {
+		return res[i].q > res[j].q
+	})
+	return res
+}
+
+// baseMediaType 去掉Content-Type中的参数部分
+func baseMediaType(contentType string) string {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return strings.ToLower(strings.TrimSpace(contentType))
+	}
+	return mediaType
+}
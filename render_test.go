@@ -0,0 +1,148 @@
+package lr
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type renderUser struct {
+	Name string `json:"name" xml:"name" yaml:"name"`
+	Age  int    `json:"age" xml:"age" yaml:"age"`
+}
+
+func (u renderUser) String() string {
+	return u.Name
+}
+
+type csvRenderer struct{}
+
+func (csvRenderer) ContentType() string {
+	return "text/csv"
+}
+
+func (csvRenderer) Render(val any) ([]byte, error) {
+	u := val.(renderUser)
+	return []byte("name,age\n" + u.Name + ",18\n"), nil
+}
+
+func TestContext_Negotiate(t *testing.T) {
+	testCases := []struct {
+		name       string
+		accept     string
+		wantStatus int
+		wantType   string
+		wantBody   string
+	}{
+		{
+			name:       "没有Accept",
+			wantStatus: http.StatusCreated,
+			wantType:   "application/json",
+			wantBody:   `{"name":"Tom","age":18}`,
+		},
+		{
+			name:       "xml",
+			accept:     "application/xml",
+			wantStatus: http.StatusCreated,
+			wantType:   "application/xml",
+			wantBody:   `<renderUser><name>Tom</name><age>18</age></renderUser>`,
+		},
+		{
+			name:       "q值",
+			accept:     "application/json;q=0.5, application/yaml;q=0.9, */*;q=0.1",
+			wantStatus: http.StatusCreated,
+			wantType:   "application/yaml",
+			wantBody:   "name: Tom\nage: 18\n",
+		},
+		{
+			name:       "通配",
+			accept:     "text/*",
+			wantStatus: http.StatusCreated,
+			wantType:   "text/plain; charset=utf-8",
+			wantBody:   "Tom",
+		},
+		{
+			name:       "自定义格式",
+			accept:     "text/csv, text/plain;q=0.8",
+			wantStatus: http.StatusCreated,
+			wantType:   "text/csv",
+			wantBody:   "name,age\nTom,18\n",
+		},
+		{
+			name:       "q为0",
+			accept:     "application/json;q=0, application/xml;q=0.1",
+			wantStatus: http.StatusCreated,
+			wantType:   "application/xml",
+			wantBody:   `<renderUser><name>Tom</name><age>18</age></renderUser>`,
+		},
+		{
+			name:       "不支持",
+			accept:     "image/png",
+			wantStatus: http.StatusNotAcceptable,
+			wantType:   "text/plain; charset=utf-8",
+			wantBody:   "Not Acceptable, 支持的类型: application/json, application/xml, application/yaml, application/msgpack, text/plain, text/csv",
+		},
+	}
+
+	h := NewHTTPServer("tcp", ":8081", Renderers(csvRenderer{}))
+	h.GET("/user", func(ctx *Context) {
+		_ = ctx.Negotiate(http.StatusCreated, renderUser{Name: "Tom", Age: 18})
+	})
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/user", nil)
+			if tc.accept != "" {
+				req.Header.Set("Accept", tc.accept)
+			}
+			recorder := httptest.NewRecorder()
+			h.ServeHTTP(recorder, req)
+
+			assert.Equal(t, tc.wantStatus, recorder.Code)
+			assert.Equal(t, tc.wantType, recorder.Header().Get("Content-Type"))
+			assert.Equal(t, "Accept", recorder.Header().Get("Vary"))
+			assert.Equal(t, tc.wantBody, recorder.Body.String())
+		})
+	}
+}
+
+func TestMarshalMsgPack(t *testing.T) {
+	testCases := []struct {
+		name string
+		val  any
+		want []byte
+	}{
+		{
+			name: "结构体",
+			val:  renderUser{Name: "Tom", Age: 18},
+			want: []byte{0x82, 0xa4, 'n', 'a', 'm', 'e', 0xa3, 'T', 'o', 'm', 0xa3, 'a', 'g', 'e', 0x12},
+		},
+		{
+			name: "整数",
+			val:  []int64{-1, -33, 200, 70000},
+			want: []byte{0x94, 0xff, 0xd0, 0xdf, 0xcc, 0xc8, 0xce, 0x00, 0x01, 0x11, 0x70},
+		},
+		{
+			name: "map和nil",
+			val:  map[string]any{"b": nil, "a": true},
+			want: []byte{0x82, 0xa1, 'a', 0xc3, 0xa1, 'b', 0xc0},
+		},
+		{
+			name: "时间",
+			val:  time.Unix(1, 0),
+			want: []byte{0xd6, 0xff, 0x00, 0x00, 0x00, 0x01},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			data, err := marshalMsgPack(tc.val)
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, data)
+		})
+	}
+}
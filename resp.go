@@ -0,0 +1,183 @@
+package lr
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var (
+	ErrInvalidRedirectCode  = errors.New("lr: 重定向的状态码必须是3xx")
+	ErrInvalidJSONPCallback = errors.New("lr: 不合法的JSONP回调函数名")
+
+	// jsonpCallbackRegexp 只允许js标识符和.，防止回调参数被用来注入脚本
+	jsonpCallbackRegexp = regexp.MustCompile(`^[a-zA-Z_$][a-zA-Z0-9_$]*(\.[a-zA-Z_$][a-zA-Z0-9_$]*)*$`)
+)
+
+// setResp 所有响应方法的统一出口，只修改Status、RespData和Content-Type
+// Content-Length在刷新响应时根据最终的RespData计算，中间件可以放心改写RespData
+func (c *Context) setResp(status int, contentType string, data []byte) {
+	if contentType != "" {
+		c.Resp.Header().Set("Content-Type", contentType)
+	}
+	c.Status = status
+	c.RespData = data
+}
+
+// RespJSON 返回json响应
+func (c *Context) RespJSON(status int, val any) error {
+	data, err := c.marshalJSON(val)
+	if err != nil {
+		return err
+	}
+	c.setResp(status, "application/json", data)
+	return nil
+}
+
+// RespXML 返回xml响应
+func (c *Context) RespXML(status int, val any) error {
+	data, err := xml.Marshal(val)
+	if err != nil {
+		return err
+	}
+	c.setResp(status, "application/xml", data)
+	return nil
+}
+
+// RespString 返回纯文本响应
+func (c *Context) RespString(status int, s string) error {
+	c.setResp(status, "text/plain; charset=utf-8", []byte(s))
+	return nil
+}
+
+// RespBytes 使用指定的Content-Type返回原始数据
+func (c *Context) RespBytes(status int, contentType string, data []byte) error {
+	c.setResp(status, contentType, data)
+	return nil
+}
+
+// NoContent 返回204，并清掉之前设置的响应数据
+func (c *Context) NoContent() error {
+	c.Resp.Header().Del("Content-Type")
+	c.setResp(http.StatusNoContent, "", nil)
+	return nil
+}
+
+// Redirect 重定向到location，code必须是3xx
+func (c *Context) Redirect(code int, location string) error {
+	if code < http.StatusMultipleChoices || code > http.StatusPermanentRedirect {
+		return ErrInvalidRedirectCode
+	}
+	c.Resp.Header().Set("Location", location)
+	c.setResp(code, "", nil)
+	return nil
+}
+
+// JSONP 返回JSONP响应，callback只能是合法的js标识符
+func (c *Context) JSONP(status int, callback string, val any) error {
+	if !jsonpCallbackRegexp.MatchString(callback) {
+		return ErrInvalidJSONPCallback
+	}
+	data, err := c.marshalJSON(val)
+	if err != nil {
+		return err
+	}
+	// 开头的注释可以避免Rosetta Flash之类的攻击
+	body := fmt.Sprintf("/**/ typeof %s === 'function' && %s(%s);", callback, callback, data)
+	c.Resp.Header().Set("X-Content-Type-Options", "nosniff")
+	c.setResp(status, "application/javascript; charset=utf-8", []byte(body))
+	return nil
+}
+
+// Attachment 把文件作为附件返回，name为空时使用文件名
+// 文件不存在或者是目录时设置404，读取失败时设置500。
+// 文件内容保存在RespData中，和其他响应一样可以被中间件改写；条件请求返回304或者412，
+// 单个Range返回206，范围不合法时返回416
+func (c *Context) Attachment(path, name string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			c.Status = http.StatusNotFound
+		} else {
+			c.Status = http.StatusInternalServerError
+		}
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		c.Status = http.StatusInternalServerError
+		return err
+	}
+	if info.IsDir() {
+		c.Status = http.StatusNotFound
+		return fmt.Errorf("lr: %s是目录", path)
+	}
+
+	header := c.Resp.Header()
+	c.SetLastModified(info.ModTime())
+	if c.CheckPreconditions() {
+		return nil
+	}
+	data, err := io.ReadAll(f)
+	if err != nil {
+		c.Status = http.StatusInternalServerError
+		return err
+	}
+
+	if name == "" {
+		name = filepath.Base(path)
+	}
+	contentType := mime.TypeByExtension(filepath.Ext(name))
+	if contentType == "" {
+		contentType = http.DetectContentType(data)
+	}
+	header.Set("Content-Disposition", contentDisposition("attachment", name))
+	header.Set("Accept-Ranges", "bytes")
+	c.setResp(http.StatusOK, contentType, data)
+	c.applyRange()
+	return nil
+}
+
+// contentDisposition 同时设置filename和filename*，兼容不支持RFC 6266的客户端
+func contentDisposition(disposition, name string) string {
+	ascii := strings.Map(func(r rune) rune {
+		if r > 0x7e || r < 0x20 || r == '"' || r == '\\' {
+			return '_'
+		}
+		return r
+	}, name)
+	return fmt.Sprintf(`%s; filename="%s"; filename*=UTF-8''%s`, disposition, ascii, encodeRFC5987(name))
+}
+
+// encodeRFC5987 RFC 5987 ext-value的编码，attr-char之外的字节都使用%XX
+func encodeRFC5987(val string) string {
+	const hex = "0123456789ABCDEF"
+	var sb strings.Builder
+	sb.Grow(len(val) * 3)
+	for i := 0; i < len(val); i++ {
+		b := val[i]
+		if isAttrChar(b) {
+			sb.WriteByte(b)
+			continue
+		}
+		sb.WriteByte('%')
+		sb.WriteByte(hex[b>>4])
+		sb.WriteByte(hex[b&0x0f])
+	}
+	return sb.String()
+}
+
+func isAttrChar(b byte) bool {
+	if b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z' || b >= '0' && b <= '9' {
+		return true
+	}
+	return strings.IndexByte("!#$&+-.^_`|~", b) >= 0
+}
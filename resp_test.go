@@ -0,0 +1,207 @@
+package lr
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestContext_Resp(t *testing.T) {
+	testCases := []struct {
+		name       string
+		handler    HandleFunc
+		wantStatus int
+		wantHeader http.Header
+		wantBody   string
+	}{
+		{
+			name: "json",
+			handler: func(ctx *Context) {
+				_ = ctx.RespJSON(http.StatusCreated, map[string]int{"id": 1})
+			},
+			wantStatus: http.StatusCreated,
+			wantHeader: http.Header{
+				"Content-Type":   {"application/json"},
+				"Content-Length": {"8"},
+			},
+			wantBody: `{"id":1}`,
+		},
+		{
+			name: "xml",
+			handler: func(ctx *Context) {
+				_ = ctx.RespXML(http.StatusOK, renderUser{Name: "Tom", Age: 18})
+			},
+			wantStatus: http.StatusOK,
+			wantHeader: http.Header{"Content-Type": {"application/xml"}},
+			wantBody:   `<renderUser><name>Tom</name><age>18</age></renderUser>`,
+		},
+		{
+			name: "string",
+			handler: func(ctx *Context) {
+				_ = ctx.RespString(http.StatusOK, "你好")
+			},
+			wantStatus: http.StatusOK,
+			wantHeader: http.Header{
+				"Content-Type":   {"text/plain; charset=utf-8"},
+				"Content-Length": {"6"},
+			},
+			wantBody: "你好",
+		},
+		{
+			name: "bytes",
+			handler: func(ctx *Context) {
+				_ = ctx.RespBytes(http.StatusOK, "image/png", []byte{0x89, 'P', 'N', 'G'})
+			},
+			wantStatus: http.StatusOK,
+			wantHeader: http.Header{"Content-Type": {"image/png"}},
+			wantBody:   "\x89PNG",
+		},
+		{
+			name: "no content",
+			handler: func(ctx *Context) {
+				_ = ctx.RespString(http.StatusOK, "会被清掉")
+				_ = ctx.NoContent()
+			},
+			wantStatus: http.StatusNoContent,
+			wantHeader: http.Header{"Content-Type": nil},
+		},
+		{
+			name: "redirect",
+			handler: func(ctx *Context) {
+				_ = ctx.Redirect(http.StatusFound, "/login")
+			},
+			wantStatus: http.StatusFound,
+			wantHeader: http.Header{"Location": {"/login"}},
+		},
+		{
+			name: "jsonp",
+			handler: func(ctx *Context) {
+				_ = ctx.JSONP(http.StatusOK, "app.cb", []int{1})
+			},
+			wantStatus: http.StatusOK,
+			wantHeader: http.Header{"Content-Type": {"application/javascript; charset=utf-8"}},
+			wantBody:   "/**/ typeof app.cb === 'function' && app.cb([1]);",
+		},
+		{
+			name: "attachment",
+			handler: func(ctx *Context) {
+				_ = ctx.Attachment(filepath.Join("testdata", "download", "new_file.txt"), "报告.txt")
+			},
+			wantStatus: http.StatusOK,
+			wantHeader: http.Header{
+				"Content-Type":        {"text/plain; charset=utf-8"},
+				"Content-Disposition": {`attachment; filename="__.txt"; filename*=UTF-8''%E6%8A%A5%E5%91%8A.txt`},
+			},
+		},
+		{
+			name: "中间件改写RespData",
+			handler: func(ctx *Context) {
+				_ = ctx.RespString(http.StatusOK, "原始数据")
+				ctx.RespData = []byte("new")
+			},
+			wantStatus: http.StatusOK,
+			wantHeader: http.Header{"Content-Length": {"3"}},
+			wantBody:   "new",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			h := NewHTTPServer("tcp", ":8081")
+			h.GET("/", tc.handler)
+			recorder := httptest.NewRecorder()
+			h.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+
+			assert.Equal(t, tc.wantStatus, recorder.Code)
+			for key, vals := range tc.wantHeader {
+				assert.Equal(t, vals, recorder.Header().Values(key), key)
+			}
+			if tc.wantBody != "" {
+				assert.Equal(t, tc.wantBody, recorder.Body.String())
+			}
+		})
+	}
+}
+
+func TestContext_RespInvalidArgs(t *testing.T) {
+	ctx := &Context{Req: httptest.NewRequest(http.MethodGet, "/", nil), Resp: httptest.NewRecorder()}
+	assert.Equal(t, ErrInvalidRedirectCode, ctx.Redirect(http.StatusOK, "/"))
+	assert.Equal(t, ErrInvalidJSONPCallback, ctx.JSONP(http.StatusOK, "alert(1)//", 1))
+	assert.Error(t, ctx.Attachment(filepath.Join("testdata", "not_exist"), ""))
+	assert.Equal(t, http.StatusNotFound, ctx.Status)
+}
+
+func TestContext_AttachmentRange(t *testing.T) {
+	file := filepath.Join("testdata", "download", "new_file.txt")
+	data, err := os.ReadFile(file)
+	require.NoError(t, err)
+	info, err := os.Stat(file)
+	require.NoError(t, err)
+	lastModified := info.ModTime().UTC().Format(http.TimeFormat)
+
+	testCases := []struct {
+		name       string
+		headers    map[string]string
+		wantStatus int
+		wantBody   string
+		wantRange  string
+	}{
+		{name: "完整内容", wantStatus: http.StatusOK, wantBody: string(data)},
+		{name: "Range", headers: map[string]string{"Range": "bytes=0-2"}, wantStatus: http.StatusPartialContent, wantBody: string(data[:3]), wantRange: fmt.Sprintf("bytes 0-2/%d", len(data))},
+		{name: "后缀Range", headers: map[string]string{"Range": "bytes=-3"}, wantStatus: http.StatusPartialContent, wantBody: string(data[len(data)-3:]), wantRange: fmt.Sprintf("bytes %d-%d/%d", len(data)-3, len(data)-1, len(data))},
+		{name: "Range超出范围", headers: map[string]string{"Range": "bytes=100000-"}, wantStatus: http.StatusRequestedRangeNotSatisfiable, wantRange: fmt.Sprintf("bytes */%d", len(data))},
+		{name: "多个Range返回完整内容", headers: map[string]string{"Range": "bytes=0-1,3-4"}, wantStatus: http.StatusOK, wantBody: string(data)},
+		{name: "If-Range过期", headers: map[string]string{"Range": "bytes=0-2", "If-Range": "Mon, 02 Jan 2006 15:04:05 GMT"}, wantStatus: http.StatusOK, wantBody: string(data)},
+		{name: "If-Range一致", headers: map[string]string{"Range": "bytes=0-2", "If-Range": lastModified}, wantStatus: http.StatusPartialContent, wantBody: string(data[:3]), wantRange: fmt.Sprintf("bytes 0-2/%d", len(data))},
+		{name: "If-Modified-Since", headers: map[string]string{"If-Modified-Since": lastModified}, wantStatus: http.StatusNotModified},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var seen []byte
+			h := NewHTTPServer("tcp", ":8081", Use(func(next HandleFunc) HandleFunc {
+				return func(ctx *Context) {
+					next(ctx)
+					// 中间件可以读取和改写文件的内容
+					seen = ctx.RespData
+				}
+			}))
+			h.GET("/", func(ctx *Context) {
+				_ = ctx.Attachment(file, "")
+			})
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			for key, val := range tc.headers {
+				req.Header.Set(key, val)
+			}
+			recorder := httptest.NewRecorder()
+			h.ServeHTTP(recorder, req)
+			assert.Equal(t, tc.wantStatus, recorder.Code)
+			assert.Equal(t, tc.wantBody, recorder.Body.String())
+			assert.Equal(t, tc.wantBody, string(seen))
+			assert.Equal(t, tc.wantRange, recorder.Header().Get("Content-Range"))
+			assert.Equal(t, lastModified, recorder.Header().Get("Last-Modified"))
+		})
+	}
+
+	ctx := &Context{Req: httptest.NewRequest(http.MethodGet, "/", nil), Resp: httptest.NewRecorder()}
+	assert.Error(t, ctx.Attachment("testdata", ""))
+	assert.Equal(t, http.StatusNotFound, ctx.Status)
+}
+
+func TestContentDisposition(t *testing.T) {
+	testCases := map[string]string{
+		"a.txt":             `attachment; filename="a.txt"; filename*=UTF-8''a.txt`,
+		"报告.txt":            `attachment; filename="__.txt"; filename*=UTF-8''%E6%8A%A5%E5%91%8A.txt`,
+		`a'b (1);c=*@,.txt`: `attachment; filename="a'b (1);c=*@,.txt"; filename*=UTF-8''a%27b%20%281%29%3Bc%3D%2A%40%2C.txt`,
+		"x\"y\\z&$~.txt":    `attachment; filename="x_y_z&$~.txt"; filename*=UTF-8''x%22y%5Cz&$~.txt`,
+	}
+	for name, want := range testCases {
+		assert.Equal(t, want, contentDisposition("attachment", name), name)
+	}
+}
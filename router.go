@@ -2,6 +2,7 @@ package lr
 
 import (
 	"fmt"
+	"sort"
 	"strings"
 )
 
@@ -27,6 +28,8 @@ type node struct {
 
 	// 处理具体的业务逻辑
 	handler HandleFunc
+	// 注册时通过RouteOption记录的请求和响应类型
+	types *TypedHandlerInfo
 }
 
 func newRouter() *router {
@@ -40,7 +43,7 @@ func newRouter() *router {
 // 2. 不能以 / 结尾
 // 3. 不能是空字符串
 // 4. 不能是连续的 ///，无论是开头、结尾、还是路径中间
-func (r *router) addRouter(method, path string, handler HandleFunc) {
+func (r *router) addRouter(method, path string, handler HandleFunc, opts ...RouteOption) {
 	if len(path) == 0 {
 		panic("请求路径不能为空")
 	}
@@ -70,6 +73,7 @@ func (r *router) addRouter(method, path string, handler HandleFunc) {
 			panic("路由冲突，重复注册[/]")
 		}
 		root.handler = handler
+		root.types = routeTypes(method, path, handler, opts)
 		return
 	}
 
@@ -88,6 +92,16 @@ func (r *router) addRouter(method, path string, handler HandleFunc) {
 	}
 
 	root.handler = handler
+	root.types = routeTypes(method, path, handler, opts)
+}
+
+// routeTypes 执行注册时的RouteOption，返回记录的类型信息
+func routeTypes(method, path string, handler HandleFunc, opts []RouteOption) *TypedHandlerInfo {
+	info := RouteInfo{Method: method, Path: path, Handler: handler}
+	for _, opt := range opts {
+		opt(&info)
+	}
+	return info.Types
 }
 
 // findRouter 匹配路由
@@ -119,7 +133,7 @@ func (r *router) findRouter(method, path string) (*matchInfo, bool) {
 			if params == nil {
 				params = make(map[string]string, 4)
 			}
-			params[child.path[1:]] = seg[1:]
+			params[child.path[1:]] = seg
 		}
 
 		root = child
@@ -137,6 +151,72 @@ func (r *router) findRouter(method, path string) (*matchInfo, bool) {
 	}, true
 }
 
+// RouteInfo 注册的路由信息
+type RouteInfo struct {
+	// 请求方法
+	Method string
+	// 注册时的路径，例如 /user/:id
+	Path string
+	// 处理请求的handler
+	Handler HandleFunc
+	// 通过HandleTyped、WithTypes记录的请求和响应类型，没有记录时为nil
+	Types *TypedHandlerInfo
+}
+
+// RouteOption 注册路由时的选项
+type RouteOption func(r *RouteInfo)
+
+// routes 遍历路由森林，返回所有注册了handler的路由，按照路径和方法排序
+func (r *router) routes() []RouteInfo {
+	var res []RouteInfo
+	for method, root := range r.trees {
+		if root.handler != nil {
+			res = append(res, RouteInfo{Method: method, Path: "/", Handler: root.handler, Types: root.types})
+		}
+		root.walk("", func(path string, n *node) {
+			res = append(res, RouteInfo{Method: method, Path: path, Handler: n.handler, Types: n.types})
+		})
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Path != res[j].Path {
+			return res[i].Path < res[j].Path
+		}
+		return res[i].Method < res[j].Method
+	})
+	return res
+}
+
+// walk 深度优先遍历子节点，对每个有handler的节点调用fn
+func (n *node) walk(prefix string, fn func(path string, n *node)) {
+	children := make([]*node, 0, len(n.children)+1)
+	for _, child := range n.children {
+		children = append(children, child)
+	}
+	if n.paramChild != nil {
+		children = append(children, n.paramChild)
+	}
+
+	for _, child := range children {
+		path := prefix + "/" + child.path
+		if child.handler != nil {
+			fn(path, child)
+		}
+		child.walk(path, fn)
+	}
+}
+
+// allowedMethods 注册了path的所有方法，用于返回405和Allow响应头
+func (r *router) allowedMethods(path string) []string {
+	var methods []string
+	for method := range r.trees {
+		if res, ok := r.findRouter(method, path); ok && res.n.handler != nil {
+			methods = append(methods, method)
+		}
+	}
+	sort.Strings(methods)
+	return methods
+}
+
 // matchChildOf 优先匹配静态路径，然后再匹配参数路径
 // @return *node 匹配的信息
 // @return bool 是否是路径参数
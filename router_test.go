@@ -305,7 +305,7 @@ func TestFindPath(t *testing.T) {
 		},
 		{
 			name:      "参数路径",
-			path:      "/user/signUp/:1234455345345345",
+			path:      "/user/signUp/1234455345345345",
 			method:    http.MethodGet,
 			wantFound: true,
 			wantNode: &matchInfo{
@@ -337,7 +337,29 @@ func TestFindPath(t *testing.T) {
 			nHandler := reflect.ValueOf(n.n.handler)
 			wHandler := reflect.ValueOf(tc.wantNode.n.handler)
 			assert.True(t, nHandler == wHandler)
-			t.Log(n.pathParams)
+			assert.Equal(t, tc.wantNode.pathParams, n.pathParams)
 		})
 	}
 }
+
+func TestRouter_Routes(t *testing.T) {
+	r := newRouter()
+	var handler HandleFunc = func(ctx *Context) {}
+	r.addRouter(http.MethodGet, "/", handler)
+	r.addRouter(http.MethodGet, "/user/:id", handler)
+	r.addRouter(http.MethodDelete, "/user/:id", handler)
+	r.addRouter(http.MethodPost, "/user/signIn", handler)
+	r.addRouter(http.MethodGet, "/order/detail", handler)
+
+	var got []string
+	for _, route := range r.routes() {
+		got = append(got, route.Method+" "+route.Path)
+	}
+	assert.Equal(t, []string{
+		"GET /",
+		"GET /order/detail",
+		"DELETE /user/:id",
+		"GET /user/:id",
+		"POST /user/signIn",
+	}, got)
+}
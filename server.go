@@ -4,6 +4,8 @@ import (
 	"log"
 	"net"
 	"net/http"
+	"strconv"
+	"strings"
 )
 
 type Server interface {
@@ -12,7 +14,7 @@ type Server interface {
 	// Server 启动服务的方法
 	Server() error
 	// AddRoute 注册路由信息
-	AddRoute(method, path string, handler HandleFunc)
+	AddRoute(method, path string, handler HandleFunc, opts ...RouteOption)
 }
 
 var _ Server = (*HTTPServer)(nil)
@@ -29,20 +31,43 @@ type HTTPServer struct {
 	mdls []Middleware
 	// 模版渲染引擎
 	tplEngine TemplateEngine
+	// 按照扩展名选择的模版引擎
+	tplEngines map[string]TemplateEngine
+	// 流式渲染的缓冲区大小
+	streamBufSize int
+	// 每次渲染结束之后的回调
+	renderObserver func(ctx *Context, m RenderMetrics)
+	// 内容协商可以使用的响应格式
+	renderers []Renderer
+	// json编解码配置
+	jsonCfg JSONConfig
+	// 统一的错误处理
+	errHandler ErrorHandler
+	// 可信的代理
+	trustedProxies []*net.IPNet
+	// 命名路由
+	routeNames map[string]string
+	// 国际化的消息目录
+	i18n *I18nBundle
+	// flash消息cookie的签名
+	flashCodec CookieCodec
 }
 
 type HTTPServerOptions func(server *HTTPServer)
 
-func (h *HTTPServer) AddRoute(method, path string, handler HandleFunc) {
-	//TODO implement me
-	panic("implement me")
+// AddRoute 注册任意方法的路由，例如HEAD
+func (h *HTTPServer) AddRoute(method, path string, handler HandleFunc, opts ...RouteOption) {
+	h.router.addRouter(method, path, handler, opts...)
 }
 
 func NewHTTPServer(network, addr string, opts ...HTTPServerOptions) *HTTPServer {
 	s := &HTTPServer{
-		addr:    addr,
-		network: network,
-		router:  newRouter(),
+		addr:       addr,
+		network:    network,
+		router:     newRouter(),
+		renderers:  defaultRenderers(),
+		jsonCfg:    JSONConfig{Codec: StdJSONCodec{}},
+		routeNames: make(map[string]string),
 	}
 
 	for _, opt := range opts {
@@ -66,48 +91,82 @@ func Template(engine TemplateEngine) HTTPServerOptions {
 	}
 }
 
+// TemplateFor 给某个扩展名注册模版引擎，Context.Render根据模版名字的扩展名选择引擎，
+// 没有匹配的扩展名时使用Template设置的引擎
+//
+//	lr.NewHTTPServer("tcp", ":8081",
+//		lr.Template(pages),
+//		lr.TemplateFor(".tmpl", lr.TextTemplateEngine{T: emails}),
+//		lr.TemplateFor(".md", lr.MarkdownEngine{T: docs}))
+func TemplateFor(ext string, engine TemplateEngine) HTTPServerOptions {
+	return func(s *HTTPServer) {
+		if s.tplEngines == nil {
+			s.tplEngines = make(map[string]TemplateEngine, 4)
+		}
+		if !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+		s.tplEngines[ext] = engine
+	}
+}
+
+// Routes 所有注册的路由，按照路径和方法排序
+func (h *HTTPServer) Routes() []RouteInfo {
+	return h.router.routes()
+}
+
 // GET 注册GET方法
-func (h *HTTPServer) GET(path string, handler HandleFunc) {
-	h.router.addRouter(http.MethodGet, path, handler)
+func (h *HTTPServer) GET(path string, handler HandleFunc, opts ...RouteOption) {
+	h.router.addRouter(http.MethodGet, path, handler, opts...)
 }
 
 // POST 注册POST方法
-func (h *HTTPServer) POST(path string, handler HandleFunc) {
-	h.router.addRouter(http.MethodPost, path, handler)
+func (h *HTTPServer) POST(path string, handler HandleFunc, opts ...RouteOption) {
+	h.router.addRouter(http.MethodPost, path, handler, opts...)
 }
 
 // PUT 注册PUT方法
-func (h *HTTPServer) PUT(path string, handler HandleFunc) {
-	h.router.addRouter(http.MethodPut, path, handler)
+func (h *HTTPServer) PUT(path string, handler HandleFunc, opts ...RouteOption) {
+	h.router.addRouter(http.MethodPut, path, handler, opts...)
 }
 
 // PATCH 注册PATCH方法
-func (h *HTTPServer) PATCH(path string, handler HandleFunc) {
-	h.router.addRouter(http.MethodPatch, path, handler)
+func (h *HTTPServer) PATCH(path string, handler HandleFunc, opts ...RouteOption) {
+	h.router.addRouter(http.MethodPatch, path, handler, opts...)
 }
 
 // DELETE 注册DELETE方法
-func (h *HTTPServer) DELETE(path string, handler HandleFunc) {
-	h.router.addRouter(http.MethodDelete, path, handler)
+func (h *HTTPServer) DELETE(path string, handler HandleFunc, opts ...RouteOption) {
+	h.router.addRouter(http.MethodDelete, path, handler, opts...)
 }
 
 // OPTIONS 注册DELETE方法
-func (h *HTTPServer) OPTIONS(path string, handler HandleFunc) {
-	h.router.addRouter(http.MethodOptions, path, handler)
+func (h *HTTPServer) OPTIONS(path string, handler HandleFunc, opts ...RouteOption) {
+	h.router.addRouter(http.MethodOptions, path, handler, opts...)
 }
 
 // ServerHTTP 处理请求的入口方法
 func (h *HTTPServer) ServeHTTP(response http.ResponseWriter, request *http.Request) {
 	ctx := &Context{
-		Req:       request,
-		Resp:      response,
-		TplEngine: h.tplEngine,
+		Req:              request,
+		Resp:             response,
+		TplEngine:        h.tplEngine,
+		tplEngines:       h.tplEngines,
+		streamBufSize:    h.streamBufSize,
+		renderObserver:   h.renderObserver,
+		renderers:        h.renderers,
+		jsonCfg:          &h.jsonCfg,
+		errHandler:       h.errHandler,
+		trustedProxies:   h.trustedProxies,
+		routeNames:       h.routeNames,
+		i18n:             h.i18n,
+		flashCookieCodec: h.flashCodec,
 	}
 
-	// 中间件的处理逻辑，从后往前的方式挂载
-	root := h.serve
+	// 中间件的处理逻辑，从后往前的方式挂载，每一层都检查是否已经Abort
+	root := abortable(h.serve)
 	for i := len(h.mdls) - 1; i >= 0; i-- {
-		root = h.mdls[i](root)
+		root = abortable(h.mdls[i](root))
 	}
 
 	var m Middleware = func(next HandleFunc) HandleFunc {
@@ -123,6 +182,15 @@ func (h *HTTPServer) ServeHTTP(response http.ResponseWriter, request *http.Reque
 }
 
 func (h *HTTPServer) flushResp(ctx *Context) {
+	// 响应已经由handler直接写出，这里不能再重复写入
+	if ctx.committed {
+		return
+	}
+
+	// 中间件可能在handler之后改写RespData，所以Content-Length在这里统一计算
+	if len(ctx.RespData) != 0 {
+		ctx.Resp.Header().Set("Content-Length", strconv.Itoa(len(ctx.RespData)))
+	}
 	if ctx.Status != 0 {
 		ctx.Resp.WriteHeader(ctx.Status)
 	}
@@ -139,10 +207,14 @@ func (h *HTTPServer) flushResp(ctx *Context) {
 func (h *HTTPServer) serve(ctx *Context) {
 	res, ok := h.router.findRouter(ctx.Req.Method, ctx.Req.URL.Path)
 	if !ok || res.n.handler == nil {
+		// 路径在其他方法上注册过时返回405，否则返回404
+		if allowed := h.router.allowedMethods(ctx.Req.URL.Path); len(allowed) > 0 {
+			ctx.Resp.Header().Set("Allow", strings.Join(allowed, ", "))
+			h.routeError(ctx, http.StatusMethodNotAllowed, ctx.localize("lr.method_not_allowed", "METHOD NOT ALLOWED"))
+			return
+		}
 		// 不存在路径或者路径查到但是没有handler
-		ctx.Status = http.StatusNotFound
-		ctx.Resp.WriteHeader(http.StatusNotFound)
-		_, _ = ctx.Resp.Write([]byte("NOT FOUND"))
+		h.routeError(ctx, http.StatusNotFound, ctx.localize("lr.not_found", "NOT FOUND"))
 		return
 	}
 
@@ -151,6 +223,16 @@ func (h *HTTPServer) serve(ctx *Context) {
 	res.n.handler(ctx)
 }
 
+// routeError 客户端接受json时返回problem文档，否则返回纯文本，交给中间件(例如errorHandler)继续处理
+func (h *HTTPServer) routeError(ctx *Context, status int, msg string) {
+	if ctx.PrefersJSON() {
+		_ = ctx.RespProblem(NewProblem(status, ""))
+		return
+	}
+	ctx.Status = status
+	ctx.RespData = []byte(msg)
+}
+
 // Server 启动程序
 func (h *HTTPServer) Server() error {
 	listener, err := net.Listen(h.network, h.addr)
@@ -0,0 +1,80 @@
+package cookie
+
+import (
+	"net/http"
+
+	"github.com/liquanhui-99/lr"
+	"github.com/liquanhui-99/lr/session"
+)
+
+var _ session.Propagator = &Propagator{}
+
+// Propagator 通过cookie传递session id
+type Propagator struct {
+	cookieName string
+	cookieOpts []lr.CookieOption
+	codec      lr.CookieCodec
+}
+
+type PropagatorOption func(p *Propagator)
+
+// NewPropagator cookie默认名为sessid，属性使用lr.SetCookie的安全默认值
+func NewPropagator(opts ...PropagatorOption) *Propagator {
+	p := &Propagator{
+		cookieName: "sessid",
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+// WithCookieName 设置cookie的名字
+func WithCookieName(name string) PropagatorOption {
+	return func(p *Propagator) {
+		p.cookieName = name
+	}
+}
+
+// WithCookieOptions 设置cookie的属性，例如有效期和域名
+func WithCookieOptions(opts ...lr.CookieOption) PropagatorOption {
+	return func(p *Propagator) {
+		p.cookieOpts = opts
+	}
+}
+
+// WithCodec 对session id签名或者加密，伪造的id在访问Store之前就会被拒绝
+func WithCodec(codec lr.CookieCodec) PropagatorOption {
+	return func(p *Propagator) {
+		p.codec = codec
+	}
+}
+
+// Inject 把session id写入cookie
+func (p *Propagator) Inject(id string, writer http.ResponseWriter) error {
+	if p.codec != nil {
+		encoded, err := p.codec.Encode(p.cookieName, id)
+		if err != nil {
+			return err
+		}
+		id = encoded
+	}
+	return lr.SetCookie(writer, p.cookieName, id, p.cookieOpts...)
+}
+
+// Extract 从cookie中取出session id
+func (p *Propagator) Extract(req *http.Request) (string, error) {
+	c, err := req.Cookie(p.cookieName)
+	if err != nil {
+		return "", err
+	}
+	if p.codec != nil {
+		return p.codec.Decode(p.cookieName, c.Value)
+	}
+	return c.Value, nil
+}
+
+// Remove 让浏览器删除cookie
+func (p *Propagator) Remove(writer http.ResponseWriter) error {
+	return lr.DeleteCookie(writer, p.cookieName, p.cookieOpts...)
+}
@@ -0,0 +1,64 @@
+package cookie
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/liquanhui-99/lr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPropagator(t *testing.T) {
+	testCases := []struct {
+		name string
+		p    *Propagator
+	}{
+		{
+			name: "默认",
+			p:    NewPropagator(),
+		},
+		{
+			name: "签名",
+			p: NewPropagator(
+				WithCookieName("token"),
+				WithCookieOptions(lr.CookieMaxAge(time.Hour)),
+				WithCodec(lr.NewCookieSigner([]byte("0123456789abcdef0123456789abcdef"))),
+			),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			require.NoError(t, tc.p.Inject("sess-1", recorder))
+			cookie := recorder.Result().Cookies()[0]
+			assert.Equal(t, tc.p.cookieName, cookie.Name)
+			assert.True(t, cookie.HttpOnly)
+			assert.True(t, cookie.Secure)
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.AddCookie(cookie)
+			id, err := tc.p.Extract(req)
+			require.NoError(t, err)
+			assert.Equal(t, "sess-1", id)
+
+			recorder = httptest.NewRecorder()
+			require.NoError(t, tc.p.Remove(recorder))
+			assert.Equal(t, -1, recorder.Result().Cookies()[0].MaxAge)
+		})
+	}
+}
+
+func TestPropagator_Forged(t *testing.T) {
+	p := NewPropagator(WithCodec(lr.NewCookieSigner([]byte("0123456789abcdef0123456789abcdef"))))
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(&http.Cookie{Name: "sessid", Value: "sess-1"})
+	_, err := p.Extract(req)
+	assert.Equal(t, lr.ErrCookieTampered, err)
+
+	_, err = p.Extract(httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.ErrNoCookie, err)
+}
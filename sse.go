@@ -0,0 +1,200 @@
+package lr
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrSSENotSupported = errors.New("lr: ResponseWriter不支持Flush，无法使用SSE")
+	ErrSSEClosed       = errors.New("lr: SSE连接已关闭")
+)
+
+// SSEWriter Server-Sent Events的事件写入器
+// 每次Send都会立即Flush到客户端，请求的context取消之后所有写入都会返回错误
+type SSEWriter struct {
+	// 加锁保护写入，心跳和业务事件可能并发写
+	mu      sync.Mutex
+	resp    http.ResponseWriter
+	flusher http.Flusher
+	// 从请求的上下文派生，客户端断开或者调用Close时会被取消
+	ctx    context.Context
+	cancel context.CancelFunc
+	// 客户端断线重连时携带的最后一个事件id
+	lastEventID string
+	closed      bool
+}
+
+// SSE 把当前请求切换为Server-Sent Events响应
+// 设置好响应头并立即把响应头刷新到客户端，之后框架不会再统一写入RespData
+func (c *Context) SSE() (*SSEWriter, error) {
+	flusher, ok := c.Resp.(http.Flusher)
+	if !ok {
+		return nil, ErrSSENotSupported
+	}
+
+	header := c.Resp.Header()
+	header.Set("Content-Type", "text/event-stream")
+	header.Set("Cache-Control", "no-cache")
+	header.Set("Connection", "keep-alive")
+	// 防止nginx之类的反向代理缓存响应
+	header.Set("X-Accel-Buffering", "no")
+	header.Del("Content-Length")
+
+	c.Resp.WriteHeader(http.StatusOK)
+	flusher.Flush()
+	c.Status = http.StatusOK
+	c.committed = true
+
+	ctx, cancel := context.WithCancel(c.Req.Context())
+	return &SSEWriter{
+		resp:        c.Resp,
+		flusher:     flusher,
+		ctx:         ctx,
+		cancel:      cancel,
+		lastEventID: c.Req.Header.Get("Last-Event-ID"),
+	}, nil
+}
+
+// LastEventID 客户端重连时通过Last-Event-ID请求头携带的事件id，首次连接为空字符串
+func (s *SSEWriter) LastEventID() string {
+	return s.lastEventID
+}
+
+// Done 客户端断开连接或者写入器关闭时返回的channel会被关闭
+func (s *SSEWriter) Done() <-chan struct{} {
+	return s.ctx.Done()
+}
+
+// Send 发送一个事件
+// @param event 事件名称，为空时客户端按message事件处理
+// @param id 事件id，为空时不发送id字段
+// @param data 事件数据，string和[]byte原样发送，其他类型编码为json
+func (s *SSEWriter) Send(event, id string, data any) error {
+	payload, err := s.encode(data)
+	if err != nil {
+		return err
+	}
+
+	var sb strings.Builder
+	if id != "" {
+		sb.WriteString("id: ")
+		sb.WriteString(sanitizeSSEField(id))
+		sb.WriteByte('\n')
+	}
+	if event != "" {
+		sb.WriteString("event: ")
+		sb.WriteString(sanitizeSSEField(event))
+		sb.WriteByte('\n')
+	}
+	// 多行数据需要拆成多个data字段，客户端把\r\n、\r和\n都当作换行
+	for _, line := range splitSSELines(payload) {
+		sb.WriteString("data: ")
+		sb.WriteString(line)
+		sb.WriteByte('\n')
+	}
+	sb.WriteByte('\n')
+
+	return s.write(sb.String())
+}
+
+// Retry 通知客户端断线后的重连间隔
+func (s *SSEWriter) Retry(d time.Duration) error {
+	return s.write(fmt.Sprintf("retry: %d\n\n", d.Milliseconds()))
+}
+
+// Comment 发送注释行，客户端会忽略，常用于保持连接
+func (s *SSEWriter) Comment(text string) error {
+	var sb strings.Builder
+	for _, line := range splitSSELines(text) {
+		sb.WriteString(": ")
+		sb.WriteString(line)
+		sb.WriteByte('\n')
+	}
+	sb.WriteByte('\n')
+	return s.write(sb.String())
+}
+
+// Heartbeat 按照固定间隔发送注释作为心跳，防止代理因为空闲断开连接
+// 请求的context取消或者调用Close之后心跳自动停止，interval不大于0时不发送心跳
+func (s *SSEWriter) Heartbeat(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := s.write(": ping\n\n"); err != nil {
+					return
+				}
+			case <-s.ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// Close 关闭写入器，停止心跳，之后的写入都会返回ErrSSEClosed
+func (s *SSEWriter) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	s.closed = true
+	s.cancel()
+}
+
+func (s *SSEWriter) write(msg string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return ErrSSEClosed
+	}
+	if err := s.ctx.Err(); err != nil {
+		return err
+	}
+
+	if _, err := s.resp.Write([]byte(msg)); err != nil {
+		return err
+	}
+	s.flusher.Flush()
+	return nil
+}
+
+func (s *SSEWriter) encode(data any) (string, error) {
+	switch val := data.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return val, nil
+	case []byte:
+		return string(val), nil
+	default:
+		bs, err := json.Marshal(val)
+		if err != nil {
+			return "", err
+		}
+		return string(bs), nil
+	}
+}
+
+// sanitizeSSEField id和event字段中不能包含换行，否则会破坏事件格式
+// splitSSELines 按照\r\n、\r、\n拆分，单独的\r也会让客户端换行
+func splitSSELines(val string) []string {
+	val = strings.ReplaceAll(val, "\r\n", "\n")
+	return strings.Split(strings.ReplaceAll(val, "\r", "\n"), "\n")
+}
+
+func sanitizeSSEField(val string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(val)
+}
@@ -0,0 +1,84 @@
+package lr
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestContext_SSE(t *testing.T) {
+	h := NewHTTPServer("tcp", ":8081")
+	h.GET("/jobs/progress", func(ctx *Context) {
+		w, err := ctx.SSE()
+		require.NoError(t, err)
+		assert.Equal(t, "41", w.LastEventID())
+
+		require.NoError(t, w.Retry(3*time.Second))
+		require.NoError(t, w.Send("progress", "42", map[string]int{"percent": 50}))
+		require.NoError(t, w.Send("", "", "第一行\n第二行"))
+		// 单独的\r不能让客户端把后面的内容当作新的字段
+		require.NoError(t, w.Send("", "", "a\r\nb\revent: fake"))
+		require.NoError(t, w.Comment("keep"))
+		w.Close()
+		assert.Equal(t, ErrSSEClosed, w.Send("progress", "43", "done"))
+		// Close之后Done同样会被关闭
+		select {
+		case <-w.Done():
+		case <-time.After(time.Second):
+			t.Fatal("Close之后Done没有关闭")
+		}
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/jobs/progress", nil)
+	req.Header.Set("Last-Event-ID", "41")
+	recorder := httptest.NewRecorder()
+	h.ServeHTTP(recorder, req)
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.True(t, recorder.Flushed)
+	assert.Equal(t, "text/event-stream", recorder.Header().Get("Content-Type"))
+	assert.Equal(t, "no-cache", recorder.Header().Get("Cache-Control"))
+	assert.Equal(t, "retry: 3000\n\n"+
+		"id: 42\nevent: progress\ndata: {\"percent\":50}\n\n"+
+		"data: 第一行\ndata: 第二行\n\n"+
+		"data: a\ndata: b\ndata: event: fake\n\n"+
+		": keep\n\n", recorder.Body.String())
+}
+
+func TestSSEWriter_Cancel(t *testing.T) {
+	reqCtx, cancel := context.WithCancel(context.Background())
+	req := httptest.NewRequest(http.MethodGet, "/", nil).WithContext(reqCtx)
+	ctx := &Context{Req: req, Resp: httptest.NewRecorder()}
+
+	w, err := ctx.SSE()
+	require.NoError(t, err)
+	w.Heartbeat(time.Millisecond)
+	require.NoError(t, w.Send("tick", "", "1"))
+
+	cancel()
+	<-w.Done()
+	assert.ErrorIs(t, w.Send("tick", "", "2"), context.Canceled)
+}
+
+func TestSSEWriter_Heartbeat(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	ctx := &Context{Req: httptest.NewRequest(http.MethodGet, "/", nil), Resp: recorder}
+
+	w, err := ctx.SSE()
+	require.NoError(t, err)
+	// 不合法的间隔不会panic
+	w.Heartbeat(0)
+	w.Heartbeat(-time.Second)
+	w.Heartbeat(5 * time.Millisecond)
+	time.Sleep(30 * time.Millisecond)
+	w.Close()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	assert.Contains(t, recorder.Body.String(), ": ping\n\n")
+}
@@ -0,0 +1,211 @@
+package local
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/liquanhui-99/lr/storage"
+)
+
+// tmpPrefix 写入过程中的临时文件，List时跳过
+const tmpPrefix = ".lr-tmp-"
+
+// Storage 保存在本地目录中的存储，key对应目录下的相对路径
+//
+// 内容先写入同目录下的临时文件，完成之后再改名，读取者不会看到写了一半的文件。
+// 文件系统不保存内容类型，Stat返回的ContentType根据扩展名推断
+type Storage struct {
+	dir string
+}
+
+func NewStorage(dir string) *Storage {
+	return &Storage{dir: dir}
+}
+
+// Path key对应的文件路径
+func (s *Storage) Path(key string) (string, error) {
+	if err := storage.ValidKey(key); err != nil {
+		return "", err
+	}
+	return filepath.Join(s.dir, filepath.FromSlash(key)), nil
+}
+
+func (s *Storage) Put(ctx context.Context, key string, r io.Reader, opts storage.PutOptions) (storage.ObjectInfo, error) {
+	dest, err := s.Path(key)
+	if err != nil {
+		return storage.ObjectInfo{}, err
+	}
+	if opts.IfNotExists {
+		// 提前检查，避免读取了全部内容之后才发现已经存在，真正的判断在link的时候
+		if _, err = os.Lstat(dest); err == nil {
+			return storage.ObjectInfo{}, storage.ErrExists
+		}
+	}
+	if err = os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+		return storage.ObjectInfo{}, err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(dest), tmpPrefix+"*")
+	if err != nil {
+		return storage.ObjectInfo{}, err
+	}
+	tmpName := tmp.Name()
+	defer func() {
+		// 成功时临时文件已经改名或者删除，这里只处理失败的情况
+		_ = os.Remove(tmpName)
+	}()
+
+	_, err = io.Copy(tmp, &storage.ContextReader{Ctx: ctx, R: r})
+	if err == nil {
+		err = tmp.Chmod(0o644)
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return storage.ObjectInfo{}, err
+	}
+
+	if opts.IfNotExists {
+		// link在目标存在时失败，可以原子地判断是否存在
+		if err = os.Link(tmpName, dest); err != nil {
+			if errors.Is(err, fs.ErrExist) {
+				return storage.ObjectInfo{}, storage.ErrExists
+			}
+			return storage.ObjectInfo{}, err
+		}
+	} else if err = os.Rename(tmpName, dest); err != nil {
+		return storage.ObjectInfo{}, err
+	}
+	return s.Stat(ctx, key)
+}
+
+// Get 返回的reader是*os.File，实现了io.ReadSeeker
+func (s *Storage) Get(ctx context.Context, key string) (io.ReadCloser, storage.ObjectInfo, error) {
+	dest, err := s.Path(key)
+	if err != nil {
+		return nil, storage.ObjectInfo{}, err
+	}
+	file, err := os.Open(dest)
+	if err != nil {
+		return nil, storage.ObjectInfo{}, convertErr(err)
+	}
+	fi, err := file.Stat()
+	if err == nil && fi.IsDir() {
+		err = storage.ErrNotFound
+	}
+	if err != nil {
+		_ = file.Close()
+		return nil, storage.ObjectInfo{}, err
+	}
+	return file, objectInfo(key, fi), nil
+}
+
+func (s *Storage) Stat(ctx context.Context, key string) (storage.ObjectInfo, error) {
+	dest, err := s.Path(key)
+	if err != nil {
+		return storage.ObjectInfo{}, err
+	}
+	fi, err := os.Stat(dest)
+	if err != nil {
+		return storage.ObjectInfo{}, convertErr(err)
+	}
+	if fi.IsDir() {
+		return storage.ObjectInfo{}, storage.ErrNotFound
+	}
+	return objectInfo(key, fi), nil
+}
+
+func (s *Storage) Delete(ctx context.Context, key string) error {
+	dest, err := s.Path(key)
+	if err != nil {
+		return err
+	}
+	if err = os.Remove(dest); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (s *Storage) List(ctx context.Context, prefix string) ([]storage.ObjectInfo, error) {
+	// 只遍历前缀所在的目录
+	root := s.dir
+	if i := strings.LastIndexByte(prefix, '/'); i > 0 {
+		if err := storage.ValidKey(prefix[:i]); err != nil {
+			return nil, err
+		}
+		root = filepath.Join(s.dir, filepath.FromSlash(prefix[:i]))
+	}
+
+	var res []storage.ObjectInfo
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if err = ctx.Err(); err != nil {
+			return err
+		}
+		if d.IsDir() || strings.HasPrefix(d.Name(), tmpPrefix) {
+			return nil
+		}
+		rel, err := filepath.Rel(s.dir, p)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+		fi, err := d.Info()
+		if err != nil {
+			// 遍历过程中被删除
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		res = append(res, objectInfo(key, fi))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	// WalkDir按照文件名排序，目录和文件交错时和key的顺序不一定相同
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Key < res[j].Key
+	})
+	return res, nil
+}
+
+func objectInfo(key string, fi fs.FileInfo) storage.ObjectInfo {
+	contentType := mime.TypeByExtension(path.Ext(key))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	return storage.ObjectInfo{
+		Key:         key,
+		Size:        fi.Size(),
+		ContentType: contentType,
+		ModTime:     fi.ModTime(),
+		// 和nginx一样使用修改时间和大小
+		ETag: fmt.Sprintf(`"%x-%x"`, fi.ModTime().UnixNano(), fi.Size()),
+	}
+}
+
+func convertErr(err error) error {
+	if errors.Is(err, fs.ErrNotExist) {
+		return storage.ErrNotFound
+	}
+	return err
+}
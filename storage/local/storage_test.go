@@ -0,0 +1,61 @@
+package local
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/liquanhui-99/lr/storage"
+	"github.com/liquanhui-99/lr/storage/storagetest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStorage(t *testing.T) {
+	storagetest.TestStorage(t, func(t *testing.T) storage.Storage {
+		return NewStorage(t.TempDir())
+	})
+}
+
+func TestStorage_Files(t *testing.T) {
+	dir := t.TempDir()
+	s := NewStorage(dir)
+	ctx := context.Background()
+
+	info, err := s.Put(ctx, "img/a.png", strings.NewReader("png"), storage.PutOptions{Size: 3})
+	require.NoError(t, err)
+	assert.Equal(t, "image/png", info.ContentType)
+	data, err := os.ReadFile(filepath.Join(dir, "img", "a.png"))
+	require.NoError(t, err)
+	assert.Equal(t, "png", string(data))
+
+	// 失败和成功都不留下临时文件
+	_, err = s.Put(ctx, "img/a.png", strings.NewReader("png"), storage.PutOptions{Size: 3, IfNotExists: true})
+	assert.Equal(t, storage.ErrExists, err)
+	entries, err := os.ReadDir(filepath.Join(dir, "img"))
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "a.png", entries[0].Name())
+
+	// 目录不是对象
+	_, err = s.Stat(ctx, "img")
+	assert.Equal(t, storage.ErrNotFound, err)
+	_, _, err = s.Get(ctx, "img")
+	assert.Equal(t, storage.ErrNotFound, err)
+
+	rc, _, err := s.Get(ctx, "img/a.png")
+	require.NoError(t, err)
+	defer rc.Close()
+	_, ok := rc.(io.ReadSeeker)
+	assert.True(t, ok)
+
+	// 其他程序写入的临时文件不出现在列表中
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "img", tmpPrefix+"123"), nil, 0o644))
+	infos, err := s.List(ctx, "")
+	require.NoError(t, err)
+	require.Len(t, infos, 1)
+	assert.Equal(t, "img/a.png", infos[0].Key)
+}
@@ -0,0 +1,137 @@
+package memory
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/liquanhui-99/lr/storage"
+)
+
+// Storage 保存在内存中的存储，适合测试和单机的小文件
+type Storage struct {
+	mu      sync.RWMutex
+	objects map[string]*object
+}
+
+type object struct {
+	data []byte
+	info storage.ObjectInfo
+}
+
+func NewStorage() *Storage {
+	return &Storage{
+		objects: make(map[string]*object, 16),
+	}
+}
+
+// Put 先读取全部内容，再保存到内存中，读取失败时不会留下部分内容
+func (s *Storage) Put(ctx context.Context, key string, r io.Reader, opts storage.PutOptions) (storage.ObjectInfo, error) {
+	if err := storage.ValidKey(key); err != nil {
+		return storage.ObjectInfo{}, err
+	}
+	if opts.IfNotExists {
+		// 提前检查，避免读取了全部内容之后才发现已经存在
+		s.mu.RLock()
+		_, ok := s.objects[key]
+		s.mu.RUnlock()
+		if ok {
+			return storage.ObjectInfo{}, storage.ErrExists
+		}
+	}
+	data, err := io.ReadAll(&storage.ContextReader{Ctx: ctx, R: r})
+	if err != nil {
+		return storage.ObjectInfo{}, err
+	}
+
+	contentType := opts.ContentType
+	if contentType == "" {
+		contentType = http.DetectContentType(data)
+	}
+	sum := md5.Sum(data)
+	info := storage.ObjectInfo{
+		Key:         key,
+		Size:        int64(len(data)),
+		ContentType: contentType,
+		ModTime:     time.Now(),
+		ETag:        `"` + hex.EncodeToString(sum[:]) + `"`,
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.objects[key]; ok && opts.IfNotExists {
+		return storage.ObjectInfo{}, storage.ErrExists
+	}
+	s.objects[key] = &object{data: data, info: info}
+	return info, nil
+}
+
+// Get 返回的reader实现了io.ReadSeeker，读取的是调用时的内容，不受之后的Put影响
+func (s *Storage) Get(ctx context.Context, key string) (io.ReadCloser, storage.ObjectInfo, error) {
+	obj, err := s.get(key)
+	if err != nil {
+		return nil, storage.ObjectInfo{}, err
+	}
+	return readSeekCloser{Reader: bytes.NewReader(obj.data)}, obj.info, nil
+}
+
+func (s *Storage) Stat(ctx context.Context, key string) (storage.ObjectInfo, error) {
+	obj, err := s.get(key)
+	if err != nil {
+		return storage.ObjectInfo{}, err
+	}
+	return obj.info, nil
+}
+
+func (s *Storage) Delete(ctx context.Context, key string) error {
+	if err := storage.ValidKey(key); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.objects, key)
+	return nil
+}
+
+func (s *Storage) List(ctx context.Context, prefix string) ([]storage.ObjectInfo, error) {
+	s.mu.RLock()
+	res := make([]storage.ObjectInfo, 0, len(s.objects))
+	for key, obj := range s.objects {
+		if strings.HasPrefix(key, prefix) {
+			res = append(res, obj.info)
+		}
+	}
+	s.mu.RUnlock()
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Key < res[j].Key
+	})
+	return res, nil
+}
+
+func (s *Storage) get(key string) (*object, error) {
+	if err := storage.ValidKey(key); err != nil {
+		return nil, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	obj, ok := s.objects[key]
+	if !ok {
+		return nil, storage.ErrNotFound
+	}
+	return obj, nil
+}
+
+type readSeekCloser struct {
+	*bytes.Reader
+}
+
+func (readSeekCloser) Close() error {
+	return nil
+}
@@ -0,0 +1,41 @@
+package memory
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/liquanhui-99/lr/storage"
+	"github.com/liquanhui-99/lr/storage/storagetest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStorage(t *testing.T) {
+	storagetest.TestStorage(t, func(t *testing.T) storage.Storage {
+		return NewStorage()
+	})
+}
+
+func TestStorage_Get(t *testing.T) {
+	s := NewStorage()
+	ctx := context.Background()
+	info, err := s.Put(ctx, "a.html", strings.NewReader("<html>old"), storage.PutOptions{Size: -1})
+	require.NoError(t, err)
+	assert.Equal(t, "text/html; charset=utf-8", info.ContentType)
+
+	rc, _, err := s.Get(ctx, "a.html")
+	require.NoError(t, err)
+	_, err = s.Put(ctx, "a.html", strings.NewReader("<html>new"), storage.PutOptions{Size: -1})
+	require.NoError(t, err)
+
+	// 读取的是Get时的内容，并且可以Seek
+	seeker, ok := rc.(io.ReadSeeker)
+	require.True(t, ok)
+	_, err = seeker.Seek(6, io.SeekStart)
+	require.NoError(t, err)
+	data, err := io.ReadAll(rc)
+	require.NoError(t, err)
+	assert.Equal(t, "old", string(data))
+}
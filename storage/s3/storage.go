@@ -0,0 +1,305 @@
+package s3
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/liquanhui-99/lr/storage"
+)
+
+// Config S3以及兼容S3协议的服务(MinIO、Ceph、各家云厂商的对象存储)的配置
+type Config struct {
+	// 服务地址，例如https://s3.us-east-1.amazonaws.com、http://localhost:9000
+	Endpoint string
+	// 区域，例如us-east-1，MinIO一般使用us-east-1
+	Region string
+	Bucket string
+
+	AccessKeyID     string
+	SecretAccessKey string
+	// 临时凭证的token，可以为空
+	SessionToken string
+
+	// 使用endpoint/bucket/key形式的地址，否则使用bucket.endpoint/key；
+	// MinIO这类没有配置域名的服务需要设置为true
+	PathStyle bool
+	// 为空时使用http.DefaultClient
+	Client *http.Client
+}
+
+// Storage 通过S3 REST API读写对象，使用Signature Version 4签名，不依赖AWS SDK
+//
+//	store, err := s3.NewStorage(s3.Config{
+//		Endpoint:        "http://localhost:9000",
+//		Region:          "us-east-1",
+//		Bucket:          "uploads",
+//		AccessKeyID:     "minioadmin",
+//		SecretAccessKey: "minioadmin",
+//		PathStyle:       true,
+//	})
+type Storage struct {
+	endpoint  *url.URL
+	bucket    string
+	pathStyle bool
+	client    *http.Client
+	signer    signer
+	// 方便测试替换
+	now func() time.Time
+}
+
+func NewStorage(cfg Config) (*Storage, error) {
+	if cfg.Bucket == "" || cfg.Region == "" {
+		return nil, errors.New("s3: 需要设置Bucket和Region")
+	}
+	endpoint, err := url.Parse(cfg.Endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("s3: 不合法的Endpoint: %w", err)
+	}
+	if endpoint.Scheme != "http" && endpoint.Scheme != "https" || endpoint.Host == "" {
+		return nil, fmt.Errorf("s3: 不合法的Endpoint %s", cfg.Endpoint)
+	}
+	client := cfg.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &Storage{
+		endpoint:  endpoint,
+		bucket:    cfg.Bucket,
+		pathStyle: cfg.PathStyle,
+		client:    client,
+		signer: signer{
+			accessKeyID:     cfg.AccessKeyID,
+			secretAccessKey: cfg.SecretAccessKey,
+			sessionToken:    cfg.SessionToken,
+			region:          cfg.Region,
+			service:         "s3",
+		},
+		now: time.Now,
+	}, nil
+}
+
+// Error S3返回的错误
+type Error struct {
+	StatusCode int
+	Code       string `xml:"Code"`
+	Message    string `xml:"Message"`
+	RequestID  string `xml:"RequestId"`
+}
+
+func (e *Error) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("s3: 响应状态码 %d", e.StatusCode)
+	}
+	return fmt.Sprintf("s3: %s %s (状态码 %d, RequestId %s)", e.Code, e.Message, e.StatusCode, e.RequestID)
+}
+
+// Put 上传对象，内容不参与签名，可以直接把r流式写入请求体
+// S3需要提前知道长度，opts.Size为-1时先把全部内容读到内存中；
+// IfNotExists使用If-None-Match: *，需要服务端支持条件写入；
+// PUT的响应中没有修改时间，返回的ModTime是零值，需要时调用Stat
+func (s *Storage) Put(ctx context.Context, key string, r io.Reader, opts storage.PutOptions) (storage.ObjectInfo, error) {
+	if err := storage.ValidKey(key); err != nil {
+		return storage.ObjectInfo{}, err
+	}
+	size := opts.Size
+	if size < 0 {
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return storage.ObjectInfo{}, err
+		}
+		r, size = bytes.NewReader(data), int64(len(data))
+	}
+	contentType := opts.ContentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	req, err := s.newRequest(ctx, http.MethodPut, key, nil, r)
+	if err != nil {
+		return storage.ObjectInfo{}, err
+	}
+	// 避免http.Client对ReadCloser之外的body再包一层，同时保证长度准确
+	req.ContentLength = size
+	if size == 0 {
+		req.Body = http.NoBody
+	}
+	req.Header.Set("Content-Type", contentType)
+	if opts.IfNotExists {
+		req.Header.Set("If-None-Match", "*")
+	}
+	resp, err := s.do(req, unsignedPayload)
+	if err != nil {
+		var s3Err *Error
+		if opts.IfNotExists && errors.As(err, &s3Err) && s3Err.StatusCode == http.StatusPreconditionFailed {
+			return storage.ObjectInfo{}, storage.ErrExists
+		}
+		return storage.ObjectInfo{}, err
+	}
+	_ = resp.Body.Close()
+	return storage.ObjectInfo{
+		Key:         key,
+		Size:        size,
+		ContentType: contentType,
+		ETag:        resp.Header.Get("ETag"),
+	}, nil
+}
+
+// Get 返回的reader是响应体，不支持Seek
+func (s *Storage) Get(ctx context.Context, key string) (io.ReadCloser, storage.ObjectInfo, error) {
+	if err := storage.ValidKey(key); err != nil {
+		return nil, storage.ObjectInfo{}, err
+	}
+	req, err := s.newRequest(ctx, http.MethodGet, key, nil, nil)
+	if err != nil {
+		return nil, storage.ObjectInfo{}, err
+	}
+	resp, err := s.do(req, emptyPayloadHash)
+	if err != nil {
+		return nil, storage.ObjectInfo{}, err
+	}
+	return resp.Body, objectInfo(key, resp), nil
+}
+
+// Stat 使用HEAD请求，响应没有body，bucket不存在时也返回storage.ErrNotFound
+func (s *Storage) Stat(ctx context.Context, key string) (storage.ObjectInfo, error) {
+	if err := storage.ValidKey(key); err != nil {
+		return storage.ObjectInfo{}, err
+	}
+	req, err := s.newRequest(ctx, http.MethodHead, key, nil, nil)
+	if err != nil {
+		return storage.ObjectInfo{}, err
+	}
+	resp, err := s.do(req, emptyPayloadHash)
+	if err != nil {
+		return storage.ObjectInfo{}, err
+	}
+	_ = resp.Body.Close()
+	return objectInfo(key, resp), nil
+}
+
+// Delete S3删除不存在的对象也返回成功
+func (s *Storage) Delete(ctx context.Context, key string) error {
+	if err := storage.ValidKey(key); err != nil {
+		return err
+	}
+	req, err := s.newRequest(ctx, http.MethodDelete, key, nil, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := s.do(req, emptyPayloadHash)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil
+		}
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// List 使用ListObjectsV2，自动处理分页；S3的列表中没有内容类型，ContentType为空
+func (s *Storage) List(ctx context.Context, prefix string) ([]storage.ObjectInfo, error) {
+	var (
+		res   []storage.ObjectInfo
+		token string
+	)
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		req, err := s.newRequest(ctx, http.MethodGet, "", query, nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := s.do(req, emptyPayloadHash)
+		if err != nil {
+			return nil, err
+		}
+		var page listBucketResult
+		err = xml.NewDecoder(resp.Body).Decode(&page)
+		_ = resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("s3: 解析ListObjectsV2的响应失败: %w", err)
+		}
+		for _, obj := range page.Contents {
+			res = append(res, storage.ObjectInfo{
+				Key:     obj.Key,
+				Size:    obj.Size,
+				ModTime: obj.LastModified,
+				ETag:    obj.ETag,
+			})
+		}
+		if !page.IsTruncated || page.NextContinuationToken == "" {
+			return res, nil
+		}
+		token = page.NextContinuationToken
+	}
+}
+
+type listBucketResult struct {
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+	Contents              []struct {
+		Key          string    `xml:"Key"`
+		LastModified time.Time `xml:"LastModified"`
+		ETag         string    `xml:"ETag"`
+		Size         int64     `xml:"Size"`
+	} `xml:"Contents"`
+}
+
+// newRequest key为空时是bucket本身的地址
+func (s *Storage) newRequest(ctx context.Context, method, key string, query url.Values, body io.Reader) (*http.Request, error) {
+	u := *s.endpoint
+	p := strings.TrimSuffix(u.Path, "/")
+	if s.pathStyle {
+		p += "/" + s.bucket
+	} else {
+		u.Host = s.bucket + "." + u.Host
+	}
+	p += "/" + key
+	u.Path = p
+	// 发送的路径和签名时使用相同的编码
+	u.RawPath = uriEncode(p, false)
+	u.RawQuery = canonicalQuery(query)
+	return http.NewRequestWithContext(ctx, method, u.String(), body)
+}
+
+// do 签名之后发送请求，状态码不是2xx时关闭响应体并返回*Error，404返回storage.ErrNotFound
+func (s *Storage) do(req *http.Request, payloadHash string) (*http.Response, error) {
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	s.signer.sign(req, payloadHash, s.now())
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+	s3Err := &Error{StatusCode: resp.StatusCode}
+	// 错误的响应体不会很大，HEAD的响应没有body
+	_ = xml.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(s3Err)
+	if resp.StatusCode == http.StatusNotFound && s3Err.Code != "NoSuchBucket" {
+		return nil, storage.ErrNotFound
+	}
+	return nil, s3Err
+}
+
+func objectInfo(key string, resp *http.Response) storage.ObjectInfo {
+	modTime, _ := http.ParseTime(resp.Header.Get("Last-Modified"))
+	return storage.ObjectInfo{
+		Key:         key,
+		Size:        resp.ContentLength,
+		ContentType: resp.Header.Get("Content-Type"),
+		ModTime:     modTime,
+		ETag:        resp.Header.Get("ETag"),
+	}
+}
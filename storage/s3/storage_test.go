@@ -0,0 +1,256 @@
+package s3
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/liquanhui-99/lr/storage"
+	"github.com/liquanhui-99/lr/storage/storagetest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStorage(t *testing.T) {
+	storagetest.TestStorage(t, func(t *testing.T) storage.Storage {
+		return newTestStorage(t, newFakeS3(), "secret")
+	})
+}
+
+func TestStorage_Errors(t *testing.T) {
+	ctx := context.Background()
+	fake := newFakeS3()
+
+	// 密钥错误
+	s := newTestStorage(t, fake, "wrong")
+	_, err := s.Put(ctx, "a.txt", strings.NewReader("a"), storage.PutOptions{Size: 1})
+	var s3Err *Error
+	require.True(t, errors.As(err, &s3Err), err)
+	assert.Equal(t, http.StatusForbidden, s3Err.StatusCode)
+	assert.Equal(t, "SignatureDoesNotMatch", s3Err.Code)
+
+	// bucket不存在不是对象不存在，HEAD的响应没有body，无法区分
+	s = newTestStorage(t, fake, "secret")
+	s.bucket = "missing"
+	_, _, err = s.Get(ctx, "a.txt")
+	require.True(t, errors.As(err, &s3Err), err)
+	assert.Equal(t, "NoSuchBucket", s3Err.Code)
+	_, err = s.List(ctx, "")
+	require.True(t, errors.As(err, &s3Err), err)
+
+	_, err = NewStorage(Config{Endpoint: "localhost:9000", Region: "us-east-1", Bucket: "b"})
+	assert.Error(t, err)
+	_, err = NewStorage(Config{Endpoint: "http://localhost:9000", Bucket: "b"})
+	assert.Error(t, err)
+}
+
+func TestStorage_Wire(t *testing.T) {
+	ctx := context.Background()
+	fake := newFakeS3()
+	s := newTestStorage(t, fake, "secret")
+
+	// 需要编码的key，签名和发送的路径要一致
+	key := "目录/a b+c!(1).txt"
+	info, err := s.Put(ctx, key, strings.NewReader("hello"), storage.PutOptions{Size: 5, ContentType: "text/plain"})
+	require.NoError(t, err)
+	assert.Equal(t, `"5d41402abc4b2a76b9719d911017c592"`, info.ETag)
+	// PUT的响应中没有修改时间
+	assert.True(t, info.ModTime.IsZero())
+
+	fake.mu.Lock()
+	obj := fake.objects[key]
+	fake.mu.Unlock()
+	require.NotNil(t, obj)
+	assert.Equal(t, "text/plain", obj.contentType)
+
+	rc, got, err := s.Get(ctx, key)
+	require.NoError(t, err)
+	defer rc.Close()
+	assert.Equal(t, "text/plain", got.ContentType)
+	assert.Equal(t, obj.modTime.Truncate(time.Second), got.ModTime.UTC())
+
+	// 分页
+	for _, k := range []string{"p/1", "p/2", "p/3", "p/4", "p/5"} {
+		_, err = s.Put(ctx, k, strings.NewReader(k), storage.PutOptions{Size: int64(len(k))})
+		require.NoError(t, err)
+	}
+	infos, err := s.List(ctx, "p/")
+	require.NoError(t, err)
+	assert.Len(t, infos, 5)
+	assert.Greater(t, fake.listCalls, 2)
+}
+
+func newTestStorage(t *testing.T, fake *fakeS3, secret string) *Storage {
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	s, err := NewStorage(Config{
+		Endpoint:        server.URL,
+		Region:          "us-east-1",
+		Bucket:          "bucket",
+		AccessKeyID:     "key",
+		SecretAccessKey: secret,
+		PathStyle:       true,
+		Client:          server.Client(),
+	})
+	require.NoError(t, err)
+	return s
+}
+
+// fakeS3 在本地模拟S3，校验每个请求的签名
+type fakeS3 struct {
+	signer signer
+
+	mu        sync.Mutex
+	objects   map[string]*fakeObject
+	listCalls int
+}
+
+type fakeObject struct {
+	data        []byte
+	contentType string
+	modTime     time.Time
+	etag        string
+}
+
+func newFakeS3() *fakeS3 {
+	return &fakeS3{
+		signer:  signer{accessKeyID: "key", secretAccessKey: "secret", region: "us-east-1", service: "s3"},
+		objects: map[string]*fakeObject{},
+	}
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !f.verify(r) {
+		writeError(w, http.StatusForbidden, "SignatureDoesNotMatch")
+		return
+	}
+	if r.URL.Path != "/bucket" && !strings.HasPrefix(r.URL.Path, "/bucket/") {
+		writeError(w, http.StatusNotFound, "NoSuchBucket")
+		return
+	}
+	key := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/bucket"), "/")
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if key == "" && r.Method == http.MethodGet {
+		f.list(w, r.URL.Query())
+		return
+	}
+	obj := f.objects[key]
+	switch r.Method {
+	case http.MethodPut:
+		if r.ContentLength < 0 {
+			writeError(w, http.StatusLengthRequired, "MissingContentLength")
+			return
+		}
+		if obj != nil && r.Header.Get("If-None-Match") == "*" {
+			writeError(w, http.StatusPreconditionFailed, "PreconditionFailed")
+			return
+		}
+		data, err := io.ReadAll(r.Body)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "IncompleteBody")
+			return
+		}
+		sum := md5.Sum(data)
+		obj = &fakeObject{
+			data:        data,
+			contentType: r.Header.Get("Content-Type"),
+			modTime:     time.Now().UTC(),
+			etag:        `"` + hex.EncodeToString(sum[:]) + `"`,
+		}
+		f.objects[key] = obj
+		w.Header().Set("ETag", obj.etag)
+	case http.MethodGet, http.MethodHead:
+		if obj == nil {
+			writeError(w, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		w.Header().Set("Content-Type", obj.contentType)
+		w.Header().Set("Last-Modified", obj.modTime.Format(http.TimeFormat))
+		w.Header().Set("ETag", obj.etag)
+		http.ServeContent(w, r, "", obj.modTime, strings.NewReader(string(obj.data)))
+	case http.MethodDelete:
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// list 每页最多两个对象
+func (f *fakeS3) list(w http.ResponseWriter, query url.Values) {
+	f.listCalls++
+	var keys []string
+	for key := range f.objects {
+		if strings.HasPrefix(key, query.Get("prefix")) && key > query.Get("continuation-token") {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	var res listBucketResult
+	if len(keys) > 2 {
+		keys = keys[:2]
+		res.IsTruncated = true
+		res.NextContinuationToken = keys[1]
+	}
+	for _, key := range keys {
+		obj := f.objects[key]
+		res.Contents = append(res.Contents, struct {
+			Key          string    `xml:"Key"`
+			LastModified time.Time `xml:"LastModified"`
+			ETag         string    `xml:"ETag"`
+			Size         int64     `xml:"Size"`
+		}{Key: key, LastModified: obj.modTime, ETag: obj.etag, Size: int64(len(obj.data))})
+	}
+	w.Header().Set("Content-Type", "application/xml")
+	_ = xml.NewEncoder(w).Encode(res)
+}
+
+// verify 使用收到的请求重新计算签名
+func (f *fakeS3) verify(r *http.Request) bool {
+	auth := r.Header.Get("Authorization")
+	_, signed, ok := strings.Cut(auth, "SignedHeaders=")
+	if !ok {
+		return false
+	}
+	signed, _, _ = strings.Cut(signed, ",")
+	date, err := time.Parse(amzDateFormat, r.Header.Get("X-Amz-Date"))
+	if err != nil {
+		return false
+	}
+
+	req := &http.Request{
+		Method: r.Method,
+		URL:    &url.URL{Path: r.URL.Path, RawQuery: r.URL.RawQuery},
+		Host:   r.Host,
+		Header: http.Header{},
+	}
+	for _, name := range strings.Split(signed, ";") {
+		if name != "host" && name != "x-amz-date" {
+			req.Header[http.CanonicalHeaderKey(name)] = r.Header.Values(name)
+		}
+	}
+	f.signer.sign(req, r.Header.Get("X-Amz-Content-Sha256"), date)
+	return req.Header.Get("Authorization") == auth
+}
+
+func writeError(w http.ResponseWriter, status int, code string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	_ = xml.NewEncoder(w).Encode(struct {
+		XMLName   xml.Name `xml:"Error"`
+		Code      string
+		Message   string
+		RequestId string
+	}{Code: code, Message: code, RequestId: "test"})
+}
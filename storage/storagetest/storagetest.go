@@ -0,0 +1,148 @@
+// Package storagetest 检查storage.Storage的实现是否符合接口的约定
+package storagetest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/liquanhui-99/lr/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestStorage 使用newStorage创建的空存储依次检查读写、条件写入、删除和列表
+//
+//	func TestStorage(t *testing.T) {
+//		storagetest.TestStorage(t, func(t *testing.T) storage.Storage {
+//			return NewStorage(t.TempDir())
+//		})
+//	}
+func TestStorage(t *testing.T, newStorage func(t *testing.T) storage.Storage) {
+	ctx := context.Background()
+
+	t.Run("Put Get Stat", func(t *testing.T) {
+		s := newStorage(t)
+		info, err := s.Put(ctx, "docs/a.txt", strings.NewReader("hello"), storage.PutOptions{Size: 5, ContentType: "text/plain"})
+		require.NoError(t, err)
+		assert.Equal(t, "docs/a.txt", info.Key)
+		assert.Equal(t, int64(5), info.Size)
+
+		rc, got, err := s.Get(ctx, "docs/a.txt")
+		require.NoError(t, err)
+		data, err := io.ReadAll(rc)
+		require.NoError(t, err)
+		require.NoError(t, rc.Close())
+		assert.Equal(t, "hello", string(data))
+		assert.Equal(t, int64(5), got.Size)
+		assert.NotEmpty(t, got.ETag)
+		assert.False(t, got.ModTime.IsZero())
+
+		stat, err := s.Stat(ctx, "docs/a.txt")
+		require.NoError(t, err)
+		assert.Equal(t, "docs/a.txt", stat.Key)
+		assert.Equal(t, int64(5), stat.Size)
+		assert.Equal(t, got.ETag, stat.ETag)
+	})
+
+	t.Run("未知长度", func(t *testing.T) {
+		s := newStorage(t)
+		_, err := s.Put(ctx, "a.bin", bytes.NewBufferString("abc"), storage.PutOptions{Size: -1})
+		require.NoError(t, err)
+		stat, err := s.Stat(ctx, "a.bin")
+		require.NoError(t, err)
+		assert.Equal(t, int64(3), stat.Size)
+	})
+
+	t.Run("覆盖和条件写入", func(t *testing.T) {
+		s := newStorage(t)
+		_, err := s.Put(ctx, "a.txt", strings.NewReader("old"), storage.PutOptions{Size: 3, IfNotExists: true})
+		require.NoError(t, err)
+		_, err = s.Put(ctx, "a.txt", strings.NewReader("new!"), storage.PutOptions{Size: 4, IfNotExists: true})
+		assert.Equal(t, storage.ErrExists, err)
+		assert.Equal(t, "old", read(t, s, "a.txt"))
+
+		_, err = s.Put(ctx, "a.txt", strings.NewReader("new!"), storage.PutOptions{Size: 4})
+		require.NoError(t, err)
+		assert.Equal(t, "new!", read(t, s, "a.txt"))
+	})
+
+	t.Run("读取失败不留下对象", func(t *testing.T) {
+		s := newStorage(t)
+		_, err := s.Put(ctx, "broken.txt", io.MultiReader(strings.NewReader("part"), errReader{}), storage.PutOptions{Size: -1})
+		assert.Error(t, err)
+		_, err = s.Stat(ctx, "broken.txt")
+		assert.Equal(t, storage.ErrNotFound, err)
+	})
+
+	t.Run("不存在", func(t *testing.T) {
+		s := newStorage(t)
+		_, _, err := s.Get(ctx, "missing.txt")
+		assert.Equal(t, storage.ErrNotFound, err)
+		_, err = s.Stat(ctx, "missing.txt")
+		assert.Equal(t, storage.ErrNotFound, err)
+		assert.NoError(t, s.Delete(ctx, "missing.txt"))
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		s := newStorage(t)
+		_, err := s.Put(ctx, "a.txt", strings.NewReader("a"), storage.PutOptions{Size: 1})
+		require.NoError(t, err)
+		require.NoError(t, s.Delete(ctx, "a.txt"))
+		_, err = s.Stat(ctx, "a.txt")
+		assert.Equal(t, storage.ErrNotFound, err)
+	})
+
+	t.Run("List", func(t *testing.T) {
+		s := newStorage(t)
+		for _, key := range []string{"img/b.png", "img/a.png", "img/2023/c.png", "imgx.png", "doc/a.txt"} {
+			_, err := s.Put(ctx, key, strings.NewReader(key), storage.PutOptions{Size: int64(len(key))})
+			require.NoError(t, err)
+		}
+		testCases := map[string][]string{
+			"":      {"doc/a.txt", "img/2023/c.png", "img/a.png", "img/b.png", "imgx.png"},
+			"img/":  {"img/2023/c.png", "img/a.png", "img/b.png"},
+			"img":   {"img/2023/c.png", "img/a.png", "img/b.png", "imgx.png"},
+			"img/a": {"img/a.png"},
+			"none/": nil,
+		}
+		for prefix, want := range testCases {
+			infos, err := s.List(ctx, prefix)
+			require.NoError(t, err)
+			var keys []string
+			for _, info := range infos {
+				keys = append(keys, info.Key)
+				assert.Equal(t, int64(len(info.Key)), info.Size, info.Key)
+			}
+			assert.Equal(t, want, keys, prefix)
+		}
+	})
+
+	t.Run("不合法的key", func(t *testing.T) {
+		s := newStorage(t)
+		for _, key := range []string{"", "/a.txt", "../a.txt", "a/../../b", "a//b", "a\\b"} {
+			_, err := s.Put(ctx, key, strings.NewReader("a"), storage.PutOptions{Size: 1})
+			assert.True(t, errors.Is(err, storage.ErrInvalidKey), key)
+			_, _, err = s.Get(ctx, key)
+			assert.True(t, errors.Is(err, storage.ErrInvalidKey), key)
+		}
+	})
+}
+
+func read(t *testing.T, s storage.Storage, key string) string {
+	rc, _, err := s.Get(context.Background(), key)
+	require.NoError(t, err)
+	defer rc.Close()
+	data, err := io.ReadAll(rc)
+	require.NoError(t, err)
+	return string(data)
+}
+
+type errReader struct{}
+
+func (errReader) Read([]byte) (int, error) {
+	return 0, errors.New("storagetest: 读取失败")
+}
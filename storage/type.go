@@ -0,0 +1,78 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"time"
+)
+
+var (
+	ErrNotFound   = errors.New("storage: 对象不存在")
+	ErrExists     = errors.New("storage: 对象已经存在")
+	ErrInvalidKey = errors.New("storage: 不合法的key")
+)
+
+// Storage 文件存储，key是用/分隔的相对路径，例如avatars/2023/tom.png
+type Storage interface {
+	// Put 从r中读取内容保存为key，已经存在时覆盖，opts.IfNotExists为true时返回ErrExists
+	Put(ctx context.Context, key string, r io.Reader, opts PutOptions) (ObjectInfo, error)
+	// Get 读取对象，调用者负责关闭返回的reader；不存在时返回ErrNotFound
+	// reader实现了io.Seeker时可以用于断点续传
+	Get(ctx context.Context, key string) (io.ReadCloser, ObjectInfo, error)
+	// Stat 对象的信息，不存在时返回ErrNotFound
+	Stat(ctx context.Context, key string) (ObjectInfo, error)
+	// Delete 删除对象，不存在时不返回错误
+	Delete(ctx context.Context, key string) error
+	// List 前缀为prefix的所有对象，按照key排序
+	List(ctx context.Context, prefix string) ([]ObjectInfo, error)
+}
+
+// PutOptions 保存对象的选项
+type PutOptions struct {
+	// 内容的类型，为空时由实现决定
+	ContentType string
+	// 内容的长度，-1表示未知，有些实现(例如S3)需要先读取全部内容才能知道长度
+	Size int64
+	// 只在key不存在时保存
+	IfNotExists bool
+}
+
+// ObjectInfo 对象的信息
+type ObjectInfo struct {
+	Key         string
+	Size        int64
+	ContentType string
+	// 最后修改的时间，为零值时表示未知
+	ModTime time.Time
+	// 内容的标识，内容变化时改变，可以作为http的ETag
+	ETag string
+}
+
+// ValidKey 检查key是否合法：不能为空，不能以/开头或者结尾，不能包含\、空字符、.和..这样的片段
+func ValidKey(key string) error {
+	if key == "" || strings.HasPrefix(key, "/") || strings.HasSuffix(key, "/") ||
+		strings.ContainsAny(key, "\\\x00") {
+		return ErrInvalidKey
+	}
+	for _, seg := range strings.Split(key, "/") {
+		if seg == "" || seg == "." || seg == ".." {
+			return ErrInvalidKey
+		}
+	}
+	return nil
+}
+
+// ContextReader 每次读取之前检查ctx，请求取消之后停止读取，Storage的实现在复制内容时使用
+type ContextReader struct {
+	Ctx context.Context
+	R   io.Reader
+}
+
+func (c *ContextReader) Read(p []byte) (int, error) {
+	if err := c.Ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.R.Read(p)
+}
@@ -3,7 +3,11 @@ package lr
 import (
 	"bytes"
 	"context"
+	"fmt"
 	"html/template"
+	"io"
+	"path"
+	texttemplate "text/template"
 )
 
 // TemplateEngine 模版引擎接口
@@ -14,6 +18,15 @@ type TemplateEngine interface {
 	Render(ctx context.Context, tplName string, data any) ([]byte, error)
 }
 
+type contextValuesKey struct{}
+
+// ContextValues 在TemplateEngine.Render中获取Context.Set保存的数据
+// 返回的是渲染时的副本，没有数据时返回nil
+func ContextValues(ctx context.Context) map[string]any {
+	vals, _ := ctx.Value(contextValuesKey{}).(map[string]any)
+	return vals
+}
+
 // GoTemplateEngine 基于go的基础包实现的模版引擎
 type GoTemplateEngine struct {
 	T *template.Template
@@ -26,3 +39,37 @@ func (g GoTemplateEngine) Render(ctx context.Context, tplName string, data any)
 	}
 	return bs.Bytes(), nil
 }
+
+// RenderTo 实现StreamingEngine
+func (g GoTemplateEngine) RenderTo(ctx context.Context, w io.Writer, tplName string, data any) error {
+	return g.T.ExecuteTemplate(w, tplName, data)
+}
+
+// TextTemplateEngine 基于text/template的模版引擎，不做HTML转义，适合纯文本邮件、短信之类的内容
+type TextTemplateEngine struct {
+	T *texttemplate.Template
+}
+
+func (t TextTemplateEngine) Render(ctx context.Context, tplName string, data any) ([]byte, error) {
+	bs := &bytes.Buffer{}
+	if err := t.T.ExecuteTemplate(bs, tplName, data); err != nil {
+		return nil, err
+	}
+	return bs.Bytes(), nil
+}
+
+// RenderTo 实现StreamingEngine
+func (t TextTemplateEngine) RenderTo(ctx context.Context, w io.Writer, tplName string, data any) error {
+	return t.T.ExecuteTemplate(w, tplName, data)
+}
+
+// templateEngine 根据模版名字的扩展名选择引擎，没有匹配时使用默认的引擎
+func (c *Context) templateEngine(tplName string) (TemplateEngine, error) {
+	if engine, ok := c.tplEngines[path.Ext(tplName)]; ok {
+		return engine, nil
+	}
+	if c.TplEngine == nil {
+		return nil, fmt.Errorf("lr: 没有可以渲染模版 %s 的引擎", tplName)
+	}
+	return c.TplEngine, nil
+}
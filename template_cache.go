@@ -0,0 +1,191 @@
+package lr
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+var errRenderPanicked = errors.New("lr: 渲染模版时panic")
+
+type renderCacheKey struct{}
+
+// WithRenderCacheKey 在context中携带缓存的key，RenderCache.Render只缓存带key的渲染
+func WithRenderCacheKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, renderCacheKey{}, key)
+}
+
+// RenderCacheKey 读取WithRenderCacheKey设置的key
+func RenderCacheKey(ctx context.Context) (string, bool) {
+	key, ok := ctx.Value(renderCacheKey{}).(string)
+	return key, ok && key != ""
+}
+
+// RenderCache 渲染结果的缓存，可以放在任意TemplateEngine前面
+//
+// 缓存的key由模版名字和调用者提供的key组成，调用者需要保证key相同时渲染的结果相同，
+// 例如文章页面使用文章的id和更新时间。同一个key并发未命中时只渲染一次，其他请求等待结果；
+// 渲染失败的结果不缓存。
+//
+//	cache := lr.NewRenderCache(engine, time.Minute)
+//	server := lr.NewHTTPServer("tcp", ":8081", lr.Template(cache))
+//
+//	server.GET("/about", func(ctx *lr.Context) {
+//		_ = ctx.RenderCached("about", "v1", nil)
+//	})
+type RenderCache struct {
+	engine TemplateEngine
+	ttl    time.Duration
+	// 方便测试替换
+	now func() time.Time
+
+	mu        sync.Mutex
+	entries   map[renderCacheEntryKey]*renderCacheEntry
+	calls     map[renderCacheEntryKey]*renderCall
+	lastSweep time.Time
+}
+
+type renderCacheEntryKey struct {
+	tplName string
+	key     string
+}
+
+type renderCacheEntry struct {
+	data     []byte
+	expireAt time.Time
+}
+
+// renderCall 正在进行的渲染，并发未命中的请求共享结果
+type renderCall struct {
+	// 渲染结束之后关闭
+	done chan struct{}
+	data []byte
+	err  error
+	// 渲染期间这个key被Invalidate，结果只返回给已经在等待的请求，不再缓存
+	stale bool
+}
+
+// NewRenderCache ttl是缓存的有效期，为0时永不过期，只能通过Invalidate删除
+func NewRenderCache(engine TemplateEngine, ttl time.Duration) *RenderCache {
+	return &RenderCache{
+		engine:  engine,
+		ttl:     ttl,
+		now:     time.Now,
+		entries: make(map[renderCacheEntryKey]*renderCacheEntry, 64),
+		calls:   make(map[renderCacheEntryKey]*renderCall, 8),
+	}
+}
+
+// Render 实现TemplateEngine，ctx中没有WithRenderCacheKey设置的key时直接渲染
+func (r *RenderCache) Render(ctx context.Context, tplName string, data any) ([]byte, error) {
+	key, ok := RenderCacheKey(ctx)
+	if !ok {
+		return r.engine.Render(ctx, tplName, data)
+	}
+	res, _, err := r.do(ctx, tplName, key, func() ([]byte, error) {
+		return r.engine.Render(ctx, tplName, data)
+	})
+	return res, err
+}
+
+// Invalidate 删除模版的缓存，没有传入key时删除这个模版所有的缓存
+func (r *RenderCache) Invalidate(tplName string, keys ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(keys) > 0 {
+		for _, key := range keys {
+			k := renderCacheEntryKey{tplName: tplName, key: key}
+			delete(r.entries, k)
+			if call, ok := r.calls[k]; ok {
+				call.stale = true
+			}
+		}
+		return
+	}
+	for k := range r.entries {
+		if k.tplName == tplName {
+			delete(r.entries, k)
+		}
+	}
+	for k, call := range r.calls {
+		if k.tplName == tplName {
+			call.stale = true
+		}
+	}
+}
+
+// InvalidateAll 删除所有的缓存，例如模版重新加载之后
+func (r *RenderCache) InvalidateAll() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entries = make(map[renderCacheEntryKey]*renderCacheEntry, 64)
+	for _, call := range r.calls {
+		call.stale = true
+	}
+}
+
+// do 命中时直接返回缓存，未命中时同一个key只有一个调用者执行render，
+// 其他调用者等待结果，ctx结束时提前返回ctx.Err()
+func (r *RenderCache) do(ctx context.Context, tplName, key string, render func() ([]byte, error)) ([]byte, bool, error) {
+	k := renderCacheEntryKey{tplName: tplName, key: key}
+	r.mu.Lock()
+	now := r.now()
+	if entry, ok := r.entries[k]; ok {
+		if r.ttl <= 0 || now.Before(entry.expireAt) {
+			r.mu.Unlock()
+			return entry.data, true, nil
+		}
+		delete(r.entries, k)
+	}
+	if call, ok := r.calls[k]; ok {
+		r.mu.Unlock()
+		select {
+		case <-call.done:
+			return call.data, false, call.err
+		case <-ctx.Done():
+			return nil, false, ctx.Err()
+		}
+	}
+	call := &renderCall{done: make(chan struct{})}
+	r.calls[k] = call
+	r.mu.Unlock()
+
+	// render panic时也要唤醒等待的请求
+	call.err = errRenderPanicked
+	defer func() {
+		r.mu.Lock()
+		delete(r.calls, k)
+		if call.err == nil && !call.stale {
+			r.store(k, call.data)
+		}
+		r.mu.Unlock()
+		close(call.done)
+	}()
+
+	data, err := render()
+	// 限制容量，调用者append时不会改写缓存中的数据
+	call.data, call.err = data[:len(data):len(data)], err
+	return call.data, false, call.err
+}
+
+// store 调用者需要持有锁，每过一个ttl顺便清理过期的缓存
+func (r *RenderCache) store(k renderCacheEntryKey, data []byte) {
+	now := r.now()
+	r.entries[k] = &renderCacheEntry{data: data, expireAt: now.Add(r.ttl)}
+	if r.ttl <= 0 || now.Sub(r.lastSweep) < r.ttl {
+		return
+	}
+	r.lastSweep = now
+	for key, entry := range r.entries {
+		if !now.Before(entry.expireAt) {
+			delete(r.entries, key)
+		}
+	}
+}
+
+// RenderCached 和Render相同，engine是RenderCache时使用key缓存渲染结果
+// 缓存的是完整的页面，包括csrfField、flashes这种和请求相关的内容，key需要能够区分它们
+func (c *Context) RenderCached(tplName, key string, data any) error {
+	return c.renderWithMetrics(tplName, key, data)
+}
@@ -0,0 +1,198 @@
+package lr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type countingEngine struct {
+	cnt     int64
+	release chan struct{}
+}
+
+func (e *countingEngine) Render(ctx context.Context, tplName string, data any) ([]byte, error) {
+	if e.release != nil {
+		<-e.release
+	}
+	cnt := atomic.AddInt64(&e.cnt, 1)
+	if data == "fail" {
+		return nil, errors.New("fail")
+	}
+	return []byte(fmt.Sprintf("%s:%v:%d", tplName, data, cnt)), nil
+}
+
+func TestRenderCache_Render(t *testing.T) {
+	engine := &countingEngine{}
+	cache := NewRenderCache(engine, time.Minute)
+	now := time.Now()
+	cache.now = func() time.Time { return now }
+	render := func(tplName, key string, data any) string {
+		ctx := context.Background()
+		if key != "" {
+			ctx = WithRenderCacheKey(ctx, key)
+		}
+		res, err := cache.Render(ctx, tplName, data)
+		if err != nil {
+			return err.Error()
+		}
+		return string(res)
+	}
+
+	// 没有key时不缓存
+	assert.Equal(t, "index:a:1", render("index", "", "a"))
+	assert.Equal(t, "index:a:2", render("index", "", "a"))
+
+	// 模版名字和key一起作为缓存的key
+	assert.Equal(t, "index:a:3", render("index", "v1", "a"))
+	assert.Equal(t, "index:a:3", render("index", "v1", "b"))
+	assert.Equal(t, "about:a:4", render("about", "v1", "a"))
+	assert.Equal(t, "index:b:5", render("index", "v2", "b"))
+
+	// 过期
+	now = now.Add(time.Minute)
+	assert.Equal(t, "index:a:6", render("index", "v1", "a"))
+
+	// 删除指定的key、整个模版和所有缓存
+	cache.Invalidate("index", "v1")
+	assert.Equal(t, "index:a:7", render("index", "v1", "a"))
+	assert.Equal(t, "index:b:8", render("index", "v2", "b"))
+	cache.Invalidate("index")
+	assert.Equal(t, "index:a:9", render("index", "v1", "a"))
+	assert.Equal(t, "index:b:10", render("index", "v2", "b"))
+	assert.Equal(t, "about:a:11", render("about", "v1", "a"))
+	cache.InvalidateAll()
+	assert.Equal(t, "about:a:12", render("about", "v1", "a"))
+
+	// 失败的结果不缓存
+	assert.Equal(t, "fail", render("error", "v1", "fail"))
+	assert.Equal(t, "error:ok:14", render("error", "v1", "ok"))
+
+	// 过期的缓存会被清理
+	now = now.Add(2 * time.Minute)
+	render("index", "v3", "a")
+	cache.mu.Lock()
+	assert.Len(t, cache.entries, 1)
+	cache.mu.Unlock()
+}
+
+func TestRenderCache_SingleFlight(t *testing.T) {
+	engine := &countingEngine{release: make(chan struct{})}
+	cache := NewRenderCache(engine, 0)
+	ctx := WithRenderCacheKey(context.Background(), "v1")
+
+	var wg sync.WaitGroup
+	results := make([]string, 10)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			res, err := cache.Render(ctx, "report", "a")
+			require.NoError(t, err)
+			results[i] = string(res)
+		}(i)
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(engine.release)
+	wg.Wait()
+
+	assert.Equal(t, int64(1), atomic.LoadInt64(&engine.cnt))
+	for _, res := range results {
+		assert.Equal(t, "report:a:1", res)
+	}
+}
+
+func TestRenderCache_InvalidateDuringRender(t *testing.T) {
+	engine := &countingEngine{release: make(chan struct{})}
+	cache := NewRenderCache(engine, 0)
+	render := func(tplName, key string) <-chan struct{} {
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			_, err := cache.Render(WithRenderCacheKey(context.Background(), key), tplName, "a")
+			require.NoError(t, err)
+		}()
+		return done
+	}
+
+	done1, done2, done3 := render("article", "1"), render("article", "2"), render("about", "v1")
+	time.Sleep(50 * time.Millisecond)
+	// 只影响正在渲染的article 1，其它key的结果照常缓存
+	cache.Invalidate("article", "1")
+	close(engine.release)
+	<-done1
+	<-done2
+	<-done3
+
+	cache.mu.Lock()
+	assert.NotContains(t, cache.entries, renderCacheEntryKey{tplName: "article", key: "1"})
+	assert.Contains(t, cache.entries, renderCacheEntryKey{tplName: "article", key: "2"})
+	assert.Contains(t, cache.entries, renderCacheEntryKey{tplName: "about", key: "v1"})
+	cache.mu.Unlock()
+}
+
+func TestRenderCache_WaiterCanceled(t *testing.T) {
+	engine := &countingEngine{release: make(chan struct{})}
+	cache := NewRenderCache(engine, 0)
+	first := make(chan struct{})
+	go func() {
+		defer close(first)
+		_, _ = cache.Render(WithRenderCacheKey(context.Background(), "v1"), "report", "a")
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	// 等待中的请求被取消时不必等到渲染结束
+	ctx, cancel := context.WithTimeout(WithRenderCacheKey(context.Background(), "v1"), 50*time.Millisecond)
+	defer cancel()
+	_, err := cache.Render(ctx, "report", "a")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	close(engine.release)
+	<-first
+	assert.Equal(t, int64(1), atomic.LoadInt64(&engine.cnt))
+}
+
+func TestContext_RenderCached(t *testing.T) {
+	engine, err := NewFSTemplateEngine(fstest.MapFS{
+		"article.gohtml": {Data: []byte(`{{.title}}|{{query "page"}}`)},
+	})
+	require.NoError(t, err)
+	cache := NewRenderCache(engine, time.Minute)
+
+	var hits []bool
+	h := NewHTTPServer("tcp", ":8081", Template(cache), RenderObserver(func(ctx *Context, m RenderMetrics) {
+		hits = append(hits, m.CacheHit)
+	}))
+	h.GET("/article", func(ctx *Context) {
+		page, _ := ctx.QueryValue("page").String()
+		_ = ctx.RenderCached("article", "article-1", map[string]any{"title": "标题" + page})
+	})
+	h.GET("/preview", func(ctx *Context) {
+		_ = ctx.Render("article", map[string]any{"title": "预览"})
+	})
+
+	serve := func(path string) string {
+		recorder := httptest.NewRecorder()
+		h.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		return recorder.Body.String()
+	}
+	// RenderCache后面的引擎依然可以使用请求相关的函数
+	assert.Equal(t, "标题1|1", serve("/article?page=1"))
+	assert.Equal(t, "标题1|1", serve("/article?page=2"))
+	assert.Equal(t, "预览|3", serve("/preview?page=3"))
+	assert.Equal(t, []bool{false, true, false}, hits)
+
+	cache.Invalidate("article")
+	assert.Equal(t, "标题4|4", serve("/article?page=4"))
+}
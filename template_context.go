@@ -0,0 +1,182 @@
+package lr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/url"
+	"strings"
+)
+
+// CSRFTokenKey CSRF中间件通过Context.Set保存token时使用的key，模版中通过csrfToken和csrfField读取
+const CSRFTokenKey = "lr.csrf_token"
+
+// CSRFFieldName csrfField生成的隐藏表单字段的名字
+const CSRFFieldName = "csrf_token"
+
+var errRequestFuncOutsideRequest = errors.New("lr: 请求相关的模版函数只能在Context.Render中使用")
+
+// ViewData 模版最终拿到的数据，中间件通过Context.Set保存的数据和handler传入的数据合并而成，handler的数据优先
+type ViewData map[string]any
+
+// ContextAwareEngine 需要请求信息的模版引擎实现这个接口，Context.Render会优先调用RenderView
+// funcs是和当前请求绑定的模版函数，见Context.TemplateFuncs
+type ContextAwareEngine interface {
+	TemplateEngine
+	RenderView(ctx context.Context, tplName string, view ViewData, funcs map[string]any) ([]byte, error)
+}
+
+// ViewData 合并Context.Set保存的数据和handler的数据
+// data是map[string]any或者ViewData时逐个key合并，其他类型保存在Data中
+func (c *Context) ViewData(data any) ViewData {
+	keys := c.Keys()
+	view := make(ViewData, len(keys)+4)
+	for key, val := range keys {
+		view[key] = val
+	}
+
+	switch d := data.(type) {
+	case nil:
+	case ViewData:
+		for key, val := range d {
+			view[key] = val
+		}
+	case map[string]any:
+		for key, val := range d {
+			view[key] = val
+		}
+	default:
+		view["Data"] = data
+	}
+	return view
+}
+
+// TemplateFuncs 和当前请求绑定的模版函数
+//
+//	path                  当前请求的路径
+//	query "page"          query参数的第一个值
+//	url "user" "id" 1     命名路由生成的地址，见HTTPServer.Name
+//	flashes               上一个请求通过AddFlash保存的消息，读取之后删除
+//	csrfToken             CSRF中间件保存的token
+//	csrfField             包含CSRF token的隐藏表单字段
+//	value "user"          Context.Get保存的数据
+//	T "cart.items" "count" 3  按照当前请求的语言翻译，见Context.T
+//	locale                当前请求的语言
+func (c *Context) TemplateFuncs() map[string]any {
+	return map[string]any{
+		"path": func() string {
+			if c.Req.URL == nil {
+				return ""
+			}
+			return c.Req.URL.Path
+		},
+		"query": func(key string) string {
+			val, _ := c.QueryValue(key).String()
+			return val
+		},
+		"url": func(name string, pairs ...any) (string, error) {
+			return c.URLFor(name, pairs...)
+		},
+		"flashes": func() []string {
+			return c.Flashes()
+		},
+		"csrfToken": func() string {
+			return c.csrfToken()
+		},
+		"csrfField": func() template.HTML {
+			return template.HTML(`<input type="hidden" name="` + CSRFFieldName + `" value="` +
+				template.HTMLEscapeString(c.csrfToken()) + `">`)
+		},
+		"value": func(key string) any {
+			val, _ := c.Get(key)
+			return val
+		},
+		"T": func(key string, args ...any) string {
+			return c.T(key, args...)
+		},
+		"locale": func() string {
+			return c.Locale()
+		},
+	}
+}
+
+func (c *Context) csrfToken() string {
+	token, _ := GetAs[string](c, CSRFTokenKey)
+	return token
+}
+
+// requestFuncPlaceholders 模版解析时函数必须已经存在，先注册占位函数，渲染时再替换为和请求绑定的版本
+func requestFuncPlaceholders() map[string]any {
+	placeholder := func(...any) (string, error) {
+		return "", errRequestFuncOutsideRequest
+	}
+	funcs := (&Context{}).TemplateFuncs()
+	for name := range funcs {
+		funcs[name] = placeholder
+	}
+	return funcs
+}
+
+// Name 给路由命名，模版中可以通过url函数生成地址，path和注册路由时一致
+//
+//	h.GET("/user/:id", handler)
+//	h.Name("user", "/user/:id")
+func (h *HTTPServer) Name(name, path string) {
+	h.routeNames[name] = path
+}
+
+// URLFor 根据命名路由生成地址，pairs是key、value交替的参数，路径参数之外的参数作为query
+//
+//	h.URLFor("user", "id", 42, "tab", "posts") // /user/42?tab=posts
+func (h *HTTPServer) URLFor(name string, pairs ...any) (string, error) {
+	return buildURL(h.routeNames, name, pairs...)
+}
+
+// URLFor 根据命名路由生成地址，见HTTPServer.URLFor
+func (c *Context) URLFor(name string, pairs ...any) (string, error) {
+	return buildURL(c.routeNames, name, pairs...)
+}
+
+func buildURL(routeNames map[string]string, name string, pairs ...any) (string, error) {
+	path, ok := routeNames[name]
+	if !ok {
+		return "", fmt.Errorf("lr: 命名路由 %s 不存在", name)
+	}
+	if len(pairs)%2 != 0 {
+		return "", fmt.Errorf("lr: 命名路由 %s 的参数必须是key、value成对出现", name)
+	}
+
+	params := make(map[string]string, len(pairs)/2)
+	keys := make([]string, 0, len(pairs)/2)
+	for i := 0; i < len(pairs); i += 2 {
+		key := fmt.Sprint(pairs[i])
+		params[key] = fmt.Sprint(pairs[i+1])
+		keys = append(keys, key)
+	}
+
+	segs := strings.Split(path, "/")
+	for i, seg := range segs {
+		if !strings.HasPrefix(seg, ":") {
+			continue
+		}
+		val, ok := params[seg[1:]]
+		if !ok {
+			return "", fmt.Errorf("lr: 命名路由 %s 缺少路径参数 %s", name, seg[1:])
+		}
+		segs[i] = url.PathEscape(val)
+		delete(params, seg[1:])
+	}
+
+	res := strings.Join(segs, "/")
+	if len(params) == 0 {
+		return res, nil
+	}
+	query := make(url.Values, len(params))
+	for _, key := range keys {
+		if val, ok := params[key]; ok {
+			query.Set(key, val)
+		}
+	}
+	return res + "?" + query.Encode(), nil
+}
@@ -0,0 +1,211 @@
+package lr
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestContext_ViewData(t *testing.T) {
+	ctx := &Context{}
+	ctx.Set("user", "Tom")
+	ctx.Set("title", "中间件的标题")
+
+	assert.Equal(t, ViewData{"user": "Tom", "title": "handler的标题", "id": 1},
+		ctx.ViewData(map[string]any{"title": "handler的标题", "id": 1}))
+	assert.Equal(t, ViewData{"user": "Tom", "title": "中间件的标题", "Data": []int{1, 2}},
+		ctx.ViewData([]int{1, 2}))
+	assert.Equal(t, ViewData{"user": "Tom", "title": "中间件的标题"}, ctx.ViewData(nil))
+}
+
+func TestHTTPServer_URLFor(t *testing.T) {
+	h := NewHTTPServer("tcp", ":8081")
+	h.Name("user", "/user/:id")
+	h.Name("home", "/")
+
+	testCases := []struct {
+		name    string
+		route   string
+		pairs   []any
+		want    string
+		wantErr string
+	}{
+		{
+			name:  "路径参数",
+			route: "user",
+			pairs: []any{"id", 42},
+			want:  "/user/42",
+		},
+		{
+			name:  "转义和query",
+			route: "user",
+			pairs: []any{"id", "a b/c", "tab", "posts", "q", "x&y"},
+			want:  "/user/a%20b%2Fc?q=x%26y&tab=posts",
+		},
+		{
+			name:  "根路径",
+			route: "home",
+			want:  "/",
+		},
+		{
+			name:    "缺少路径参数",
+			route:   "user",
+			wantErr: "lr: 命名路由 user 缺少路径参数 id",
+		},
+		{
+			name:    "参数不成对",
+			route:   "user",
+			pairs:   []any{"id"},
+			wantErr: "lr: 命名路由 user 的参数必须是key、value成对出现",
+		},
+		{
+			name:    "不存在",
+			route:   "order",
+			wantErr: "lr: 命名路由 order 不存在",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := h.URLFor(tc.route, tc.pairs...)
+			if tc.wantErr != "" {
+				assert.EqualError(t, err, tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestContext_Flashes(t *testing.T) {
+	h := NewHTTPServer("tcp", ":8081")
+	h.POST("/article", func(ctx *Context) {
+		require.NoError(t, ctx.AddFlash("保存成功"))
+		require.NoError(t, ctx.AddFlash("已通知作者"))
+		_ = ctx.Redirect(http.StatusSeeOther, "/article")
+	})
+	var got []string
+	h.GET("/article", func(ctx *Context) {
+		got = ctx.Flashes()
+		assert.Equal(t, got, ctx.Flashes())
+	})
+
+	recorder := httptest.NewRecorder()
+	h.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/article", nil))
+	cookies := recorder.Result().Cookies()
+	require.Len(t, cookies, 1)
+
+	req := httptest.NewRequest(http.MethodGet, "/article", nil)
+	req.AddCookie(cookies[0])
+	recorder = httptest.NewRecorder()
+	h.ServeHTTP(recorder, req)
+	assert.Equal(t, []string{"保存成功", "已通知作者"}, got)
+	// 读取之后删除
+	assert.Equal(t, -1, recorder.Result().Cookies()[0].MaxAge)
+
+	recorder = httptest.NewRecorder()
+	h.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/article", nil))
+	assert.Empty(t, got)
+	assert.Empty(t, recorder.Result().Cookies())
+
+	// 客户端伪造的消息被丢弃
+	for _, val := range []string{
+		"WyLkv53lrZjmiJDlip8iXQ",
+		cookies[0].Value[:strings.LastIndexByte(cookies[0].Value, '.')] + ".AAAA",
+	} {
+		got = []string{"未执行"}
+		req = httptest.NewRequest(http.MethodGet, "/article", nil)
+		req.AddCookie(&http.Cookie{Name: flashCookieName, Value: val})
+		recorder = httptest.NewRecorder()
+		h.ServeHTTP(recorder, req)
+		assert.Empty(t, got)
+		assert.Equal(t, -1, recorder.Result().Cookies()[0].MaxAge)
+	}
+}
+
+func TestFlashCodec(t *testing.T) {
+	codec := NewCookieSigner([]byte("0123456789abcdef0123456789abcdef"))
+	h := NewHTTPServer("tcp", ":8081", FlashCodec(codec))
+	var got []string
+	h.GET("/article", func(ctx *Context) {
+		got = ctx.Flashes()
+	})
+
+	// 其它实例使用相同的key写入的cookie可以读取
+	val, err := codec.Encode(flashCookieName, `["保存成功"]`)
+	require.NoError(t, err)
+	req := httptest.NewRequest(http.MethodGet, "/article", nil)
+	req.AddCookie(&http.Cookie{Name: flashCookieName, Value: val})
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	assert.Equal(t, []string{"保存成功"}, got)
+
+	// 默认的key签名的cookie无法通过校验
+	val, err = defaultFlashCodec.Encode(flashCookieName, `["保存成功"]`)
+	require.NoError(t, err)
+	req = httptest.NewRequest(http.MethodGet, "/article", nil)
+	req.AddCookie(&http.Cookie{Name: flashCookieName, Value: val})
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	assert.Empty(t, got)
+}
+
+func TestContext_RenderView(t *testing.T) {
+	engine, err := NewFSTemplateEngine(fstest.MapFS{
+		"layouts/base.gohtml": {Data: []byte(`<nav>{{value "user"}}</nav>{{block "content" .}}{{end}}`)},
+		"article.gohtml": {Data: []byte(`{{define "content"}}` +
+			`{{.title}}|{{path}}|{{query "page"}}|{{url "article" "id" .id}}|` +
+			`{{range flashes}}<p>{{.}}</p>{{end}}|{{csrfField}}{{end}}`)},
+	}, TemplateLayout("base"))
+	require.NoError(t, err)
+
+	h := NewHTTPServer("tcp", ":8081", Template(engine), Use(func(next HandleFunc) HandleFunc {
+		return func(ctx *Context) {
+			ctx.Set("user", "Tom")
+			ctx.Set(CSRFTokenKey, `a"b`)
+			next(ctx)
+		}
+	}))
+	h.GET("/article/:id", func(ctx *Context) {
+		require.NoError(t, ctx.Render("article", map[string]any{"title": "<标题>", "id": 42}))
+	})
+	h.Name("article", "/article/:id")
+
+	flash, err := defaultFlashCodec.Encode(flashCookieName, `["保存成功"]`)
+	require.NoError(t, err)
+	req := httptest.NewRequest(http.MethodGet, "/article/42?page=2", nil)
+	req.AddCookie(&http.Cookie{Name: flashCookieName, Value: flash})
+	recorder := httptest.NewRecorder()
+	h.ServeHTTP(recorder, req)
+	assert.Equal(t, `<nav>Tom</nav>&lt;标题&gt;|/article/42|2|/article/42|<p>保存成功</p>|`+
+		`<input type="hidden" name="csrf_token" value="a&#34;b">`, recorder.Body.String())
+
+	// 并发渲染时每个请求使用自己的函数
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(page int) {
+			defer wg.Done()
+			ctx := &Context{
+				Req:        httptest.NewRequest(http.MethodGet, fmt.Sprintf("/article/42?page=%d", page), nil),
+				Resp:       httptest.NewRecorder(),
+				routeNames: h.routeNames,
+			}
+			data, err := engine.RenderView(context.Background(), "article", ctx.ViewData(map[string]any{"id": 42}), ctx.TemplateFuncs())
+			require.NoError(t, err)
+			assert.Contains(t, string(data), fmt.Sprintf("|%d|", page))
+		}(i)
+	}
+	wg.Wait()
+
+	// 不通过Context.Render时请求相关的函数返回错误
+	_, err = engine.Render(context.Background(), "article", map[string]any{"id": 1})
+	assert.ErrorIs(t, err, errRequestFuncOutsideRequest)
+}
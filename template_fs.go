@@ -0,0 +1,377 @@
+package lr
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// layoutDirective 页面第一行可以通过注释指定布局，none表示不使用布局
+//
+//	{{/* layout: admin */}}
+var layoutDirective = regexp.MustCompile(`^\s*\{\{-?\s*/\*\s*layout:\s*([^\s*]+)\s*\*/\s*-?\}\}`)
+
+// FSTemplateOption FSTemplateEngine的配置
+type FSTemplateOption func(e *FSTemplateEngine)
+
+// TemplateFuncs 注册模版函数，需要在解析之前设置
+func TemplateFuncs(funcs template.FuncMap) FSTemplateOption {
+	return func(e *FSTemplateEngine) {
+		for name, fn := range funcs {
+			e.funcs[name] = fn
+		}
+	}
+}
+
+// TemplateLayout 页面默认使用的布局，例如base对应layouts/base.gohtml
+func TemplateLayout(name string) FSTemplateOption {
+	return func(e *FSTemplateEngine) {
+		e.layout = name
+	}
+}
+
+// TemplateDirs 布局和公共片段所在的目录，默认为layouts和partials
+func TemplateDirs(layouts, partials string) FSTemplateOption {
+	return func(e *FSTemplateEngine) {
+		e.layoutDir = strings.Trim(layouts, "/")
+		e.partialDir = strings.Trim(partials, "/")
+	}
+}
+
+// TemplateExtensions 模版文件的扩展名，默认为.gohtml、.html和.tmpl
+func TemplateExtensions(exts ...string) FSTemplateOption {
+	return func(e *FSTemplateEngine) {
+		e.exts = exts
+	}
+}
+
+// TemplateDevMode 开发模式，渲染时检查文件是否修改，修改之后重新解析
+// interval是两次检查的最小间隔，为0时每次渲染都检查；解析失败时返回错误而不是退出
+func TemplateDevMode(interval time.Duration) FSTemplateOption {
+	return func(e *FSTemplateEngine) {
+		e.dev = true
+		e.checkInterval = interval
+	}
+}
+
+// FSTemplateEngine 从目录、fs.FS或者embed.FS加载模版，支持布局和公共片段
+//
+// layouts目录中的文件是布局，通过{{block "content" .}}{{end}}预留位置；
+// partials目录中的文件是公共片段，所有页面都可以通过{{template "partials/nav" .}}引用；
+// 其他文件是页面，页面通过{{define "content"}}填充布局，模版的名字是去掉扩展名的相对路径，例如users/index
+type FSTemplateEngine struct {
+	fsys          fs.FS
+	funcs         template.FuncMap
+	layout        string
+	layoutDir     string
+	partialDir    string
+	exts          []string
+	dev           bool
+	checkInterval time.Duration
+
+	mu        sync.RWMutex
+	set       *templateSet
+	lastCheck time.Time
+}
+
+// templateSet 一次解析的结果，重新加载时整体替换
+type templateSet struct {
+	pages map[string]*pageTemplate
+	// 用于直接渲染公共片段和布局
+	shared *template.Template
+	// shared的副本，从来不执行，RenderView每次从它克隆
+	sharedMaster *template.Template
+	// 所有文件的修改时间和大小，开发模式下用于判断是否需要重新加载
+	fingerprint string
+}
+
+type pageTemplate struct {
+	t *template.Template
+	// t的副本，从来不执行，html/template执行之后不能再克隆，RenderView每次从它克隆之后替换请求相关的函数
+	master *template.Template
+	// 执行的入口，使用布局时是布局的名字
+	entry string
+}
+
+// NewFSTemplateEngine 立即解析所有模版，有错误时直接返回，生产环境可以在启动时发现问题
+//
+//	//go:embed views
+//	var views embed.FS
+//
+//	sub, _ := fs.Sub(views, "views")
+//	engine, err := lr.NewFSTemplateEngine(sub, lr.TemplateLayout("base"))
+func NewFSTemplateEngine(fsys fs.FS, opts ...FSTemplateOption) (*FSTemplateEngine, error) {
+	e := &FSTemplateEngine{
+		fsys:       fsys,
+		funcs:      requestFuncPlaceholders(),
+		layoutDir:  "layouts",
+		partialDir: "partials",
+		exts:       []string{".gohtml", ".html", ".tmpl"},
+	}
+	for _, opt := range opts {
+		opt(e)
+	}
+
+	set, err := e.parse()
+	if err != nil {
+		return nil, err
+	}
+	e.set = set
+	e.lastCheck = time.Now()
+	return e, nil
+}
+
+// NewDirTemplateEngine 从本地目录加载模版，配合TemplateDevMode可以在修改文件之后自动生效
+func NewDirTemplateEngine(dir string, opts ...FSTemplateOption) (*FSTemplateEngine, error) {
+	return NewFSTemplateEngine(os.DirFS(dir), opts...)
+}
+
+// Render tplName可以带扩展名，也可以直接渲染布局和公共片段
+func (e *FSTemplateEngine) Render(ctx context.Context, tplName string, data any) ([]byte, error) {
+	bs := &bytes.Buffer{}
+	if err := e.RenderTo(ctx, bs, tplName, data); err != nil {
+		return nil, err
+	}
+	return bs.Bytes(), nil
+}
+
+// RenderTo 实现StreamingEngine
+func (e *FSTemplateEngine) RenderTo(ctx context.Context, w io.Writer, tplName string, data any) error {
+	if e.dev {
+		if err := e.reloadIfChanged(); err != nil {
+			return err
+		}
+	}
+
+	t, _, entry, err := e.lookup(tplName)
+	if err != nil {
+		return err
+	}
+	return t.ExecuteTemplate(w, entry, data)
+}
+
+// RenderView 实现ContextAwareEngine，模版中可以使用path、url、csrfField等和请求绑定的函数
+func (e *FSTemplateEngine) RenderView(ctx context.Context, tplName string, view ViewData, funcs map[string]any) ([]byte, error) {
+	bs := &bytes.Buffer{}
+	if err := e.RenderViewTo(ctx, bs, tplName, view, funcs); err != nil {
+		return nil, err
+	}
+	return bs.Bytes(), nil
+}
+
+// RenderViewTo 实现ContextAwareStreamingEngine
+func (e *FSTemplateEngine) RenderViewTo(ctx context.Context, w io.Writer, tplName string, view ViewData, funcs map[string]any) error {
+	if e.dev {
+		if err := e.reloadIfChanged(); err != nil {
+			return err
+		}
+	}
+
+	_, master, entry, err := e.lookup(tplName)
+	if err != nil {
+		return err
+	}
+	t, err := master.Clone()
+	if err != nil {
+		return err
+	}
+	return t.Funcs(funcs).ExecuteTemplate(w, entry, view)
+}
+
+// Reload 重新解析所有模版，失败时保留原来的模版
+func (e *FSTemplateEngine) Reload() error {
+	set, err := e.parse()
+	if err != nil {
+		return err
+	}
+	e.mu.Lock()
+	e.set = set
+	e.mu.Unlock()
+	return nil
+}
+
+func (e *FSTemplateEngine) lookup(tplName string) (t, master *template.Template, entry string, err error) {
+	name := e.trimExt(strings.TrimPrefix(tplName, "/"))
+
+	e.mu.RLock()
+	set := e.set
+	e.mu.RUnlock()
+
+	if page, ok := set.pages[name]; ok {
+		return page.t, page.master, page.entry, nil
+	}
+	if set.shared.Lookup(name) != nil {
+		return set.shared, set.sharedMaster, name, nil
+	}
+	return nil, nil, "", fmt.Errorf("lr: 模版 %s 不存在", tplName)
+}
+
+// reloadIfChanged 文件有变化时重新解析，解析失败的错误返回给调用者，修复之后下一次渲染会重新加载
+func (e *FSTemplateEngine) reloadIfChanged() error {
+	e.mu.Lock()
+	if e.checkInterval > 0 && time.Since(e.lastCheck) < e.checkInterval {
+		e.mu.Unlock()
+		return nil
+	}
+	e.lastCheck = time.Now()
+	current := e.set.fingerprint
+	e.mu.Unlock()
+
+	files, fingerprint, err := e.scan()
+	if err != nil {
+		return err
+	}
+	if fingerprint == current {
+		return nil
+	}
+
+	set, err := e.parseFiles(files, fingerprint)
+	if err != nil {
+		return err
+	}
+	e.mu.Lock()
+	e.set = set
+	e.mu.Unlock()
+	return nil
+}
+
+func (e *FSTemplateEngine) parse() (*templateSet, error) {
+	files, fingerprint, err := e.scan()
+	if err != nil {
+		return nil, err
+	}
+	return e.parseFiles(files, fingerprint)
+}
+
+// scan 找出所有模版文件，按照路径排序
+func (e *FSTemplateEngine) scan() ([]string, string, error) {
+	var (
+		files []string
+		sb    strings.Builder
+	)
+	err := fs.WalkDir(e.fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !e.isTemplate(p) {
+			return nil
+		}
+		files = append(files, p)
+		if e.dev {
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(&sb, "%s:%d:%d;", p, info.ModTime().UnixNano(), info.Size())
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, "", fmt.Errorf("lr: 加载模版失败: %w", err)
+	}
+	sort.Strings(files)
+	return files, sb.String(), nil
+}
+
+// parseFiles 先解析布局和公共片段，每个页面再基于它们的副本解析，页面之间定义的同名block互不影响
+func (e *FSTemplateEngine) parseFiles(files []string, fingerprint string) (*templateSet, error) {
+	shared := template.New("").Funcs(e.funcs)
+	var pages []string
+	for _, file := range files {
+		if !e.inDir(file, e.layoutDir) && !e.inDir(file, e.partialDir) {
+			pages = append(pages, file)
+			continue
+		}
+		content, err := fs.ReadFile(e.fsys, file)
+		if err != nil {
+			return nil, err
+		}
+		if _, err = shared.New(e.trimExt(file)).Parse(string(content)); err != nil {
+			return nil, fmt.Errorf("lr: 解析模版 %s 失败: %w", file, err)
+		}
+	}
+
+	set := &templateSet{
+		pages:       make(map[string]*pageTemplate, len(pages)),
+		fingerprint: fingerprint,
+	}
+	for _, file := range pages {
+		content, err := fs.ReadFile(e.fsys, file)
+		if err != nil {
+			return nil, err
+		}
+
+		name := e.trimExt(file)
+		t, err := shared.Clone()
+		if err != nil {
+			return nil, err
+		}
+		if _, err = t.New(name).Parse(string(content)); err != nil {
+			return nil, fmt.Errorf("lr: 解析模版 %s 失败: %w", file, err)
+		}
+
+		entry := name
+		if layout := e.pageLayout(content); layout != "" {
+			entry = path.Join(e.layoutDir, layout)
+			if t.Lookup(entry) == nil {
+				return nil, fmt.Errorf("lr: 模版 %s 使用的布局 %s 不存在", file, layout)
+			}
+		}
+		exec, err := t.Clone()
+		if err != nil {
+			return nil, err
+		}
+		set.pages[name] = &pageTemplate{t: exec, master: t, entry: entry}
+	}
+
+	// shared在克隆之后才能执行
+	exec, err := shared.Clone()
+	if err != nil {
+		return nil, err
+	}
+	set.shared, set.sharedMaster = exec, shared
+	return set, nil
+}
+
+// pageLayout 页面指定的布局优先，none表示不使用布局
+func (e *FSTemplateEngine) pageLayout(content []byte) string {
+	layout := e.layout
+	if m := layoutDirective.FindSubmatch(content); m != nil {
+		layout = string(m[1])
+	}
+	if layout == "none" {
+		return ""
+	}
+	return e.trimExt(strings.TrimPrefix(layout, e.layoutDir+"/"))
+}
+
+func (e *FSTemplateEngine) isTemplate(name string) bool {
+	ext := path.Ext(name)
+	for _, candidate := range e.exts {
+		if ext == candidate {
+			return true
+		}
+	}
+	return false
+}
+
+func (e *FSTemplateEngine) trimExt(name string) string {
+	if e.isTemplate(name) {
+		return strings.TrimSuffix(name, path.Ext(name))
+	}
+	return name
+}
+
+func (e *FSTemplateEngine) inDir(file, dir string) bool {
+	return dir != "" && strings.HasPrefix(file, dir+"/")
+}
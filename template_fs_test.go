@@ -0,0 +1,176 @@
+package lr
+
+import (
+	"context"
+	"html/template"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testTemplateFS() fstest.MapFS {
+	return fstest.MapFS{
+		"layouts/base.gohtml":  {Data: []byte(`<html><title>{{block "title" .}}默认标题{{end}}</title>{{template "partials/nav" .}}<main>{{block "content" .}}{{end}}</main></html>`)},
+		"layouts/admin.gohtml": {Data: []byte(`<admin>{{block "content" .}}{{end}}</admin>`)},
+		"partials/nav.gohtml":  {Data: []byte(`<nav>{{.User | upper}}</nav>`)},
+		"users/index.gohtml":   {Data: []byte(`{{define "title"}}用户列表{{end}}{{define "content"}}<p>{{.User}}</p>{{end}}`)},
+		"users/edit.gohtml":    {Data: []byte(`{{/* layout: admin */}}{{define "content"}}编辑{{.User}}{{end}}`)},
+		"404.gohtml":           {Data: []byte(`{{/* layout: none */}}<h1>{{.User}} NOT FOUND</h1>`)},
+		"about.gohtml":         {Data: []byte(`{{define "content"}}关于{{end}}`)},
+		"README.md":            {Data: []byte(`不是模版`)},
+	}
+}
+
+func TestFSTemplateEngine_Render(t *testing.T) {
+	engine, err := NewFSTemplateEngine(testTemplateFS(),
+		TemplateLayout("base"),
+		TemplateFuncs(template.FuncMap{"upper": strings.ToUpper}))
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name    string
+		tplName string
+		want    string
+		wantErr string
+	}{
+		{
+			name:    "布局和公共片段",
+			tplName: "users/index",
+			want:    `<html><title>用户列表</title><nav>TOM</nav><main><p>Tom</p></main></html>`,
+		},
+		{
+			name:    "带扩展名",
+			tplName: "users/index.gohtml",
+			want:    `<html><title>用户列表</title><nav>TOM</nav><main><p>Tom</p></main></html>`,
+		},
+		{
+			name:    "使用默认的block",
+			tplName: "about",
+			want:    `<html><title>默认标题</title><nav>TOM</nav><main>关于</main></html>`,
+		},
+		{
+			name:    "页面指定布局",
+			tplName: "users/edit",
+			want:    `<admin>编辑Tom</admin>`,
+		},
+		{
+			name:    "不使用布局",
+			tplName: "404",
+			want:    `<h1>Tom NOT FOUND</h1>`,
+		},
+		{
+			name:    "直接渲染公共片段",
+			tplName: "partials/nav",
+			want:    `<nav>TOM</nav>`,
+		},
+		{
+			name:    "不存在",
+			tplName: "README",
+			wantErr: "lr: 模版 README 不存在",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			data, err := engine.Render(context.Background(), tc.tplName, map[string]string{"User": "Tom"})
+			if tc.wantErr != "" {
+				assert.EqualError(t, err, tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, string(data))
+		})
+	}
+}
+
+func TestFSTemplateEngine_FailFast(t *testing.T) {
+	testCases := []struct {
+		name    string
+		fsys    fstest.MapFS
+		opts    []FSTemplateOption
+		wantErr string
+	}{
+		{
+			name:    "语法错误",
+			fsys:    fstest.MapFS{"index.gohtml": {Data: []byte(`{{if}}`)}},
+			wantErr: "lr: 解析模版 index.gohtml 失败",
+		},
+		{
+			name:    "未注册的函数",
+			fsys:    fstest.MapFS{"partials/nav.gohtml": {Data: []byte(`{{upper .}}`)}},
+			wantErr: "lr: 解析模版 partials/nav.gohtml 失败",
+		},
+		{
+			name:    "布局不存在",
+			fsys:    fstest.MapFS{"index.gohtml": {Data: []byte(`{{define "content"}}{{end}}`)}},
+			opts:    []FSTemplateOption{TemplateLayout("base")},
+			wantErr: "lr: 模版 index.gohtml 使用的布局 base 不存在",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := NewFSTemplateEngine(tc.fsys, tc.opts...)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tc.wantErr)
+		})
+	}
+}
+
+func TestFSTemplateEngine_DevMode(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "index.gohtml")
+	writeFile := func(content string, modTime time.Time) {
+		require.NoError(t, os.WriteFile(file, []byte(content), 0o644))
+		require.NoError(t, os.Chtimes(file, modTime, modTime))
+	}
+	now := time.Now()
+	writeFile(`v1 {{.}}`, now)
+
+	engine, err := NewDirTemplateEngine(dir, TemplateDevMode(0))
+	require.NoError(t, err)
+	data, err := engine.Render(context.Background(), "index", "Tom")
+	require.NoError(t, err)
+	assert.Equal(t, "v1 Tom", string(data))
+
+	writeFile(`v2 {{.}}`, now.Add(time.Second))
+	data, err = engine.Render(context.Background(), "index", "Tom")
+	require.NoError(t, err)
+	assert.Equal(t, "v2 Tom", string(data))
+
+	// 开发模式下解析失败返回错误，修复之后恢复
+	writeFile(`{{if}}`, now.Add(2*time.Second))
+	_, err = engine.Render(context.Background(), "index", "Tom")
+	assert.Error(t, err)
+	writeFile(`v3 {{.}}`, now.Add(3*time.Second))
+	data, err = engine.Render(context.Background(), "index", "Tom")
+	require.NoError(t, err)
+	assert.Equal(t, "v3 Tom", string(data))
+}
+
+func TestFSTemplateEngine_Production(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "index.gohtml")
+	require.NoError(t, os.WriteFile(file, []byte(`v1`), 0o644))
+
+	engine, err := NewDirTemplateEngine(dir)
+	require.NoError(t, err)
+
+	// 生产模式只解析一次
+	require.NoError(t, os.WriteFile(file, []byte(`v2`), 0o644))
+	require.NoError(t, os.Chtimes(file, time.Now().Add(time.Hour), time.Now().Add(time.Hour)))
+	data, err := engine.Render(context.Background(), "index", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "v1", string(data))
+
+	require.NoError(t, engine.Reload())
+	data, err = engine.Render(context.Background(), "index", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "v2", string(data))
+}
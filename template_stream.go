@@ -0,0 +1,169 @@
+package lr
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// StreamingEngine 可以直接把模版渲染到io.Writer的引擎，配合StreamTemplates使用
+type StreamingEngine interface {
+	TemplateEngine
+	RenderTo(ctx context.Context, w io.Writer, tplName string, data any) error
+}
+
+// ContextAwareStreamingEngine 需要请求信息并且可以直接渲染到io.Writer的引擎
+type ContextAwareStreamingEngine interface {
+	ContextAwareEngine
+	RenderViewTo(ctx context.Context, w io.Writer, tplName string, view ViewData, funcs map[string]any) error
+}
+
+// RenderMetrics 一次Context.Render的耗时等信息，通过RenderObserver获取
+type RenderMetrics struct {
+	// 模版的名字
+	Template string
+	// 渲染的总耗时
+	Duration time.Duration
+	// 开始渲染到第一次刷新到客户端的耗时，没有刷新时为0
+	TimeToFirstFlush time.Duration
+	// 渲染输出的字节数
+	Bytes int
+	// 渲染过程中是否已经把内容刷新到了客户端
+	Flushed bool
+	// 是否命中了RenderCache
+	CacheHit bool
+	// 渲染的错误
+	Err error
+}
+
+// RenderInterruptedError 模版已经开始输出到客户端之后渲染失败，响应状态码已经无法修改，
+// 客户端收到的是不完整的页面
+type RenderInterruptedError struct {
+	Template string
+	Err      error
+}
+
+func (e *RenderInterruptedError) Error() string {
+	return fmt.Sprintf("lr: 模版 %s 已经开始输出，渲染中断: %v", e.Template, e.Err)
+}
+
+func (e *RenderInterruptedError) Unwrap() error {
+	return e.Err
+}
+
+// StreamTemplates Context.Render直接把模版渲染到响应中，减少大页面的首字节时间
+//
+// 渲染结果先写入大小为bufSize的缓冲区，缓冲区满了才刷新到客户端：
+// 整个页面没有超过缓冲区时和普通渲染一样，失败时返回500，中间件也可以继续修改响应；
+// 已经刷新之后再失败只能中断输出，Render返回*RenderInterruptedError。
+// 引擎需要实现StreamingEngine或者ContextAwareStreamingEngine，否则依然完整渲染之后再返回
+func StreamTemplates(bufSize int) HTTPServerOptions {
+	return func(s *HTTPServer) {
+		s.streamBufSize = bufSize
+	}
+}
+
+// RenderObserver 每次Context.Render结束之后调用，可以用来统计渲染耗时
+//
+//	lr.RenderObserver(func(ctx *lr.Context, m lr.RenderMetrics) {
+//		renderDuration.WithLabelValues(m.Template).Observe(m.Duration.Seconds())
+//	})
+func RenderObserver(fn func(ctx *Context, m RenderMetrics)) HTTPServerOptions {
+	return func(s *HTTPServer) {
+		s.renderObserver = fn
+	}
+}
+
+// renderStream 引擎不支持流式渲染时返回false
+func (c *Context) renderStream(ctx context.Context, engine TemplateEngine, tplName string, data any, m *RenderMetrics) (bool, error) {
+	w := &streamWriter{c: c, limit: c.streamBufSize, start: time.Now()}
+	var err error
+	switch e := engine.(type) {
+	case ContextAwareStreamingEngine:
+		err = e.RenderViewTo(ctx, w, tplName, c.ViewData(data), c.TemplateFuncs())
+	case ContextAwareEngine:
+		// 流式渲染拿不到请求相关的函数，不如完整渲染
+		return false, nil
+	case StreamingEngine:
+		err = e.RenderTo(ctx, w, tplName, data)
+	default:
+		return false, nil
+	}
+
+	m.Bytes = w.written + len(w.buf)
+	m.Flushed = w.flushed
+	m.TimeToFirstFlush = w.firstFlush
+	if !w.flushed {
+		// 没有超过缓冲区，交给框架统一写入
+		if err != nil {
+			c.RespData = nil
+			c.Status = http.StatusInternalServerError
+			return true, err
+		}
+		c.RespData = w.buf
+		c.Status = http.StatusOK
+		return true, nil
+	}
+
+	if err != nil {
+		return true, &RenderInterruptedError{Template: tplName, Err: err}
+	}
+	return true, w.flush()
+}
+
+// streamWriter 缓冲区满了之后刷新到客户端，第一次刷新时写入响应头
+type streamWriter struct {
+	c     *Context
+	limit int
+	buf   []byte
+	start time.Time
+
+	flushed    bool
+	firstFlush time.Duration
+	written    int
+	err        error
+}
+
+func (w *streamWriter) Write(p []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+	w.buf = append(w.buf, p...)
+	if len(w.buf) < w.limit {
+		return len(p), nil
+	}
+	if err := w.flush(); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (w *streamWriter) flush() error {
+	if w.err != nil {
+		return w.err
+	}
+	resp := w.c.Resp
+	if !w.flushed {
+		resp.Header().Del("Content-Length")
+		resp.WriteHeader(http.StatusOK)
+		w.c.Status = http.StatusOK
+		w.c.committed = true
+		w.flushed = true
+		w.firstFlush = time.Since(w.start)
+	}
+
+	n, err := resp.Write(w.buf)
+	w.written += n
+	w.buf = w.buf[:0]
+	if err != nil {
+		// 客户端断开之后模版的后续写入都会失败，渲染随之结束
+		w.err = err
+		return err
+	}
+	if flusher, ok := resp.(http.Flusher); ok {
+		flusher.Flush()
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package lr
+
+import (
+	"errors"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestContext_RenderStream(t *testing.T) {
+	errBoom := errors.New("boom")
+	tpl := template.Must(template.New("").Funcs(template.FuncMap{
+		"fail": func(fail bool) (string, error) {
+			if fail {
+				return "", errBoom
+			}
+			return "", nil
+		},
+	}).Parse(`{{define "page"}}{{range .Rows}}<p>{{.}}</p>{{end}}{{fail .Fail}}{{end}}`))
+
+	testCases := []struct {
+		name string
+		rows int
+		fail bool
+
+		wantCode    int
+		wantBody    string
+		wantFlushed bool
+		wantErr     error
+	}{
+		{
+			name:     "没有超过缓冲区",
+			rows:     2,
+			wantCode: http.StatusOK,
+			wantBody: strings.Repeat("<p>row</p>", 2),
+		},
+		{
+			name:        "超过缓冲区",
+			rows:        100,
+			wantCode:    http.StatusOK,
+			wantBody:    strings.Repeat("<p>row</p>", 100),
+			wantFlushed: true,
+		},
+		{
+			name:     "刷新之前失败",
+			rows:     2,
+			fail:     true,
+			wantCode: http.StatusInternalServerError,
+			wantErr:  errBoom,
+		},
+		{
+			name:        "刷新之后失败",
+			rows:        100,
+			fail:        true,
+			wantCode:    http.StatusOK,
+			wantFlushed: true,
+			wantErr:     errBoom,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var (
+				metrics   RenderMetrics
+				renderErr error
+			)
+			h := NewHTTPServer("tcp", ":8081",
+				Template(GoTemplateEngine{T: tpl}),
+				StreamTemplates(256),
+				RenderObserver(func(ctx *Context, m RenderMetrics) {
+					metrics = m
+				}))
+			h.GET("/report", func(ctx *Context) {
+				rows := make([]string, tc.rows)
+				for i := range rows {
+					rows[i] = "row"
+				}
+				renderErr = ctx.Render("page", map[string]any{"Rows": rows, "Fail": tc.fail})
+			})
+
+			recorder := httptest.NewRecorder()
+			h.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/report", nil))
+			assert.Equal(t, tc.wantCode, recorder.Code)
+			assert.Equal(t, tc.wantFlushed, recorder.Flushed)
+			if tc.wantBody != "" {
+				assert.Equal(t, tc.wantBody, recorder.Body.String())
+			}
+			if tc.wantCode == http.StatusInternalServerError {
+				// 没有输出渲染了一半的页面
+				assert.Empty(t, recorder.Body.String())
+			}
+
+			assert.Equal(t, "page", metrics.Template)
+			assert.Equal(t, tc.wantFlushed, metrics.Flushed)
+			assert.True(t, metrics.Duration > 0)
+			assert.Equal(t, tc.wantFlushed, metrics.TimeToFirstFlush > 0)
+			if tc.wantErr == nil {
+				require.NoError(t, renderErr)
+				assert.Equal(t, len(tc.wantBody), metrics.Bytes)
+				return
+			}
+			assert.ErrorIs(t, renderErr, tc.wantErr)
+			assert.Equal(t, renderErr, metrics.Err)
+			var interrupted *RenderInterruptedError
+			assert.Equal(t, tc.wantFlushed, errors.As(renderErr, &interrupted))
+		})
+	}
+}
@@ -2,7 +2,12 @@ package lr
 
 import (
 	"html/template"
+	"net/http"
+	"net/http/httptest"
 	"testing"
+	texttemplate "text/template"
+
+	"github.com/stretchr/testify/assert"
 )
 
 func TestTemplate(t *testing.T) {
@@ -28,3 +33,64 @@ func TestTemplate(t *testing.T) {
 		panic(err)
 	}
 }
+
+func TestContext_RenderByExtension(t *testing.T) {
+	pages := template.Must(template.New("index.gohtml").Parse(`<p>{{.}}</p>`))
+	emails := texttemplate.Must(texttemplate.New("welcome.tmpl").Parse(`<p>{{.}}</p>`))
+	docs := texttemplate.Must(texttemplate.New("readme.md").Parse(`**{{.}}**`))
+
+	h := NewHTTPServer("tcp", ":8081",
+		Template(&GoTemplateEngine{T: pages}),
+		TemplateFor(".tmpl", TextTemplateEngine{T: emails}),
+		TemplateFor("md", MarkdownEngine{T: docs}))
+	h.GET("/render", func(ctx *Context) {
+		name, _ := ctx.QueryValue("name").String()
+		_ = ctx.Render(name, "<Tom>")
+	})
+
+	testCases := []struct {
+		name     string
+		tplName  string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "默认引擎",
+			tplName:  "index.gohtml",
+			wantCode: http.StatusOK,
+			wantBody: `<p>&lt;Tom&gt;</p>`,
+		},
+		{
+			name:     "text/template",
+			tplName:  "welcome.tmpl",
+			wantCode: http.StatusOK,
+			wantBody: `<p><Tom></p>`,
+		},
+		{
+			name:     "markdown",
+			tplName:  "readme.md",
+			wantCode: http.StatusOK,
+			wantBody: "<p><strong>&lt;Tom&gt;</strong></p>\n",
+		},
+		{
+			name:     "没有匹配的模版",
+			tplName:  "missing.md",
+			wantCode: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			h.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/render?name="+tc.tplName, nil))
+			assert.Equal(t, tc.wantCode, recorder.Code)
+			if tc.wantBody != "" {
+				assert.Equal(t, tc.wantBody, recorder.Body.String())
+			}
+		})
+	}
+
+	// 没有任何引擎时返回错误而不是panic
+	ctx := &Context{Req: httptest.NewRequest(http.MethodGet, "/", nil)}
+	assert.EqualError(t, ctx.Render("index.gohtml", nil), "lr: 没有可以渲染模版 index.gohtml 的引擎")
+}
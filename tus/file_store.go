@@ -0,0 +1,179 @@
+package tus
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	infoExt = ".info"
+	dataExt = ".bin"
+)
+
+// FileStore 保存在本地目录中的上传，每个上传对应id.info和id.bin两个文件
+//
+// 内容直接追加到id.bin，已经上传的长度就是文件的大小，进程崩溃之后也能从正确的位置恢复
+type FileStore struct {
+	dir string
+}
+
+func NewFileStore(dir string) *FileStore {
+	return &FileStore{dir: dir}
+}
+
+func (f *FileStore) Create(ctx context.Context, info Info) error {
+	if !validID(info.ID) {
+		return errors.New("tus: 不合法的id " + info.ID)
+	}
+	if err := os.MkdirAll(f.dir, 0o755); err != nil {
+		return err
+	}
+	data, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+	// 先创建内容文件，Info只认有.info的上传
+	file, err := os.OpenFile(f.path(info.ID, dataExt), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if err = file.Close(); err != nil {
+		return err
+	}
+	if err = os.WriteFile(f.path(info.ID, infoExt), data, 0o644); err != nil {
+		_ = os.Remove(f.path(info.ID, dataExt))
+		return err
+	}
+	return nil
+}
+
+func (f *FileStore) Info(ctx context.Context, id string) (Info, error) {
+	if !validID(id) {
+		return Info{}, ErrNotFound
+	}
+	data, err := os.ReadFile(f.path(id, infoExt))
+	if err != nil {
+		return Info{}, convertErr(err)
+	}
+	var info Info
+	if err = json.Unmarshal(data, &info); err != nil {
+		return Info{}, err
+	}
+	fi, err := os.Stat(f.path(id, dataExt))
+	if err != nil {
+		return Info{}, convertErr(err)
+	}
+	info.Offset = fi.Size()
+	return info, nil
+}
+
+// Append 直接写入文件，读取失败时已经写入的部分保留在文件中
+func (f *FileStore) Append(ctx context.Context, id string, offset int64, r io.Reader) (int64, error) {
+	if !validID(id) {
+		return 0, ErrNotFound
+	}
+	file, err := os.OpenFile(f.path(id, dataExt), os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return 0, convertErr(err)
+	}
+	fi, err := file.Stat()
+	if err == nil && fi.Size() != offset {
+		err = ErrOffsetMismatch
+	}
+	if err != nil {
+		_ = file.Close()
+		return 0, err
+	}
+	n, err := io.Copy(file, r)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return n, err
+}
+
+// Reader 返回的是*os.File
+func (f *FileStore) Reader(ctx context.Context, id string) (io.ReadCloser, error) {
+	if !validID(id) {
+		return nil, ErrNotFound
+	}
+	file, err := os.Open(f.path(id, dataExt))
+	if err != nil {
+		return nil, convertErr(err)
+	}
+	return file, nil
+}
+
+func (f *FileStore) Terminate(ctx context.Context, id string) error {
+	if !validID(id) {
+		return ErrNotFound
+	}
+	// 先删除.info，删除之后这个上传就不存在了
+	if err := os.Remove(f.path(id, infoExt)); err != nil {
+		return convertErr(err)
+	}
+	if err := os.Remove(f.path(id, dataExt)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (f *FileStore) List(ctx context.Context) ([]Info, error) {
+	entries, err := os.ReadDir(f.dir)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var res []Info
+	for _, entry := range entries {
+		id := strings.TrimSuffix(entry.Name(), infoExt)
+		if entry.IsDir() || id == entry.Name() {
+			continue
+		}
+		info, err := f.Info(ctx, id)
+		if err != nil {
+			// 遍历过程中被删除
+			if errors.Is(err, ErrNotFound) {
+				continue
+			}
+			return nil, err
+		}
+		res = append(res, info)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].ID < res[j].ID
+	})
+	return res, nil
+}
+
+func (f *FileStore) path(id, ext string) string {
+	return filepath.Join(f.dir, id+ext)
+}
+
+// validID id来自请求的路径，只允许字母、数字、-和_，防止跳出目录
+func validID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for _, c := range id {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+func convertErr(err error) error {
+	if errors.Is(err, fs.ErrNotExist) {
+		return ErrNotFound
+	}
+	return err
+}
@@ -0,0 +1,493 @@
+package tus
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/liquanhui-99/lr"
+)
+
+const (
+	// Version 支持的tus协议版本
+	Version = "1.0.0"
+	// Extensions 支持的扩展
+	Extensions = "creation,creation-with-upload,expiration,termination"
+
+	offsetContentType = "application/offset+octet-stream"
+)
+
+var errTooLarge = errors.New("tus: 上传的内容超过了Upload-Length")
+
+// Handler tus 1.0断点续传协议的实现，https://tus.io/protocols/resumable-upload
+//
+// 客户端先POST创建上传，然后用PATCH分段追加内容，中断之后通过HEAD查询已经上传的长度继续上传。
+// 同一个进程中同一个上传的PATCH和DELETE不会并发执行，多个实例部署时需要把同一个上传路由到同一个实例，
+// 或者使用自己实现的带分布式锁的Store。
+//
+//	handler := tus.NewHandler(tus.NewFileStore("uploads/tmp"),
+//		tus.MaxSize(10<<30),
+//		tus.Expiration(24*time.Hour),
+//		tus.OnComplete(func(ctx *lr.Context, info tus.Info) {
+//			// 把文件移动到正式的存储
+//		}))
+//	handler.Register(server, "/files")
+type Handler struct {
+	store      Store
+	basePath   string
+	maxSize    int64
+	expiration time.Duration
+	onComplete func(ctx *lr.Context, info Info)
+	// 方便测试替换
+	now func() time.Time
+
+	mu    sync.Mutex
+	locks map[string]struct{}
+}
+
+type Option func(h *Handler)
+
+// MaxSize 单个上传的最大字节数，0表示不限制
+func MaxSize(size int64) Option {
+	return func(h *Handler) {
+		h.maxSize = size
+	}
+}
+
+// Expiration 未完成的上传从创建开始的有效期，过期之后返回410，需要定期调用CleanupExpired删除；0表示不过期
+func Expiration(d time.Duration) Option {
+	return func(h *Handler) {
+		h.expiration = d
+	}
+}
+
+// OnComplete 上传完成之后调用，可以用Store.Reader读取内容保存到其他地方，然后调用Store.Terminate删除
+func OnComplete(fn func(ctx *lr.Context, info Info)) Option {
+	return func(h *Handler) {
+		h.onComplete = fn
+	}
+}
+
+func NewHandler(store Store, opts ...Option) *Handler {
+	h := &Handler{
+		store: store,
+		now:   time.Now,
+		locks: make(map[string]struct{}, 8),
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+// Register 在basePath注册创建上传的路由，在basePath/:id注册上传的路由
+func (h *Handler) Register(server *lr.HTTPServer, basePath string) {
+	h.basePath = strings.TrimSuffix(basePath, "/")
+	server.OPTIONS(h.basePath, h.options)
+	server.POST(h.basePath, h.create)
+
+	uploadPath := h.basePath + "/:id"
+	server.OPTIONS(uploadPath, h.options)
+	server.AddRoute(http.MethodHead, uploadPath, h.head)
+	server.PATCH(uploadPath, h.patch)
+	server.DELETE(uploadPath, h.terminate)
+	// 不支持PATCH、DELETE的环境可以使用POST加上X-HTTP-Method-Override
+	server.POST(uploadPath, h.override)
+}
+
+// CleanupExpired 删除过期的上传，返回删除的数量
+//
+//	go func() {
+//		for range time.Tick(time.Hour) {
+//			_, _ = handler.CleanupExpired(context.Background())
+//		}
+//	}()
+func (h *Handler) CleanupExpired(ctx context.Context) (int, error) {
+	infos, err := h.store.List(ctx)
+	if err != nil {
+		return 0, err
+	}
+	now := h.now()
+	cnt := 0
+	for _, info := range infos {
+		if !info.Expired(now) || !h.lock(info.ID) {
+			continue
+		}
+		err = h.store.Terminate(ctx, info.ID)
+		h.unlock(info.ID)
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			return cnt, err
+		}
+		cnt++
+	}
+	return cnt, nil
+}
+
+func (h *Handler) options(ctx *lr.Context) {
+	header := ctx.Resp.Header()
+	header.Set("Tus-Resumable", Version)
+	header.Set("Tus-Version", Version)
+	header.Set("Tus-Extension", Extensions)
+	if h.maxSize > 0 {
+		header.Set("Tus-Max-Size", strconv.FormatInt(h.maxSize, 10))
+	}
+	_ = ctx.NoContent()
+}
+
+func (h *Handler) create(ctx *lr.Context) {
+	if !h.checkVersion(ctx) {
+		return
+	}
+	req := ctx.Req
+	if req.Header.Get("Upload-Defer-Length") != "" {
+		h.fail(ctx, http.StatusBadRequest, "不支持creation-defer-length", nil)
+		return
+	}
+	size, err := strconv.ParseInt(req.Header.Get("Upload-Length"), 10, 64)
+	if err != nil || size < 0 {
+		h.fail(ctx, http.StatusBadRequest, "Upload-Length不合法", err)
+		return
+	}
+	if h.maxSize > 0 && size > h.maxSize {
+		h.fail(ctx, http.StatusRequestEntityTooLarge, "Upload-Length超过了"+strconv.FormatInt(h.maxSize, 10), nil)
+		return
+	}
+	metadata, err := ParseMetadata(req.Header.Get("Upload-Metadata"))
+	if err != nil {
+		h.fail(ctx, http.StatusBadRequest, "Upload-Metadata不合法", err)
+		return
+	}
+	withUpload := req.Header.Get("Content-Type") == offsetContentType
+	if withUpload && req.ContentLength > size {
+		h.fail(ctx, http.StatusRequestEntityTooLarge, errTooLarge.Error(), nil)
+		return
+	}
+
+	now := h.now()
+	info := Info{
+		ID:        newID(),
+		Size:      size,
+		Metadata:  metadata,
+		CreatedAt: now,
+	}
+	if h.expiration > 0 {
+		info.ExpiresAt = now.Add(h.expiration)
+	}
+	if err = h.store.Create(req.Context(), info); err != nil {
+		h.fail(ctx, http.StatusInternalServerError, "", err)
+		return
+	}
+	header := ctx.Resp.Header()
+	// 使用相对地址，客户端按照请求的地址解析，不依赖可能被伪造的Host
+	header.Set("Location", h.basePath+"/"+info.ID)
+
+	if withUpload {
+		// 创建已经成功，即使内容没有写完也返回201，客户端通过HEAD查询偏移量后继续上传
+		if !h.lock(info.ID) {
+			h.fail(ctx, http.StatusLocked, "上传正在被其他请求写入", nil)
+			return
+		}
+		n, err := h.store.Append(req.Context(), info.ID, 0, &limitedReader{r: req.Body, n: size})
+		h.unlock(info.ID)
+		info.Offset = n
+		if err != nil {
+			// 和PATCH一样返回错误，Location已经设置，客户端可以通过HEAD查询偏移量后继续上传
+			h.fail(ctx, appendStatus(err), "", err)
+			if !errors.Is(err, errTooLarge) {
+				return
+			}
+		} else {
+			header.Set("Upload-Offset", strconv.FormatInt(info.Offset, 10))
+			h.setExpires(ctx, info)
+			ctx.Status = http.StatusCreated
+		}
+	} else {
+		h.setExpires(ctx, info)
+		ctx.Status = http.StatusCreated
+	}
+	if info.Done() && h.onComplete != nil {
+		h.onComplete(ctx, info)
+	}
+}
+
+func (h *Handler) head(ctx *lr.Context) {
+	if !h.checkVersion(ctx) {
+		return
+	}
+	info, ok := h.info(ctx, uploadID(ctx))
+	if !ok {
+		return
+	}
+	header := ctx.Resp.Header()
+	header.Set("Upload-Offset", strconv.FormatInt(info.Offset, 10))
+	header.Set("Upload-Length", strconv.FormatInt(info.Size, 10))
+	if len(info.Metadata) > 0 {
+		header.Set("Upload-Metadata", EncodeMetadata(info.Metadata))
+	}
+	header.Set("Cache-Control", "no-store")
+	h.setExpires(ctx, info)
+	ctx.Status = http.StatusOK
+}
+
+func (h *Handler) patch(ctx *lr.Context) {
+	if !h.checkVersion(ctx) {
+		return
+	}
+	req := ctx.Req
+	if req.Header.Get("Content-Type") != offsetContentType {
+		h.fail(ctx, http.StatusUnsupportedMediaType, "Content-Type需要是"+offsetContentType, nil)
+		return
+	}
+	offset, err := strconv.ParseInt(req.Header.Get("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		h.fail(ctx, http.StatusBadRequest, "Upload-Offset不合法", err)
+		return
+	}
+
+	id := uploadID(ctx)
+	if !h.lock(id) {
+		h.fail(ctx, http.StatusLocked, "上传正在被其他请求写入", nil)
+		return
+	}
+	defer h.unlock(id)
+	info, ok := h.info(ctx, id)
+	if !ok {
+		return
+	}
+	if offset != info.Offset {
+		h.fail(ctx, http.StatusConflict, fmt.Sprintf("Upload-Offset应该是%d", info.Offset), ErrOffsetMismatch)
+		return
+	}
+	remaining := info.Size - offset
+	if req.ContentLength > remaining {
+		h.fail(ctx, http.StatusRequestEntityTooLarge, errTooLarge.Error(), nil)
+		return
+	}
+
+	n, err := h.store.Append(req.Context(), id, offset, &limitedReader{r: req.Body, n: remaining})
+	info.Offset += n
+	switch {
+	case err == nil:
+		ctx.Resp.Header().Set("Upload-Offset", strconv.FormatInt(info.Offset, 10))
+		h.setExpires(ctx, info)
+		_ = ctx.NoContent()
+	case errors.Is(err, errTooLarge):
+		// 已经写满了Upload-Length，多出来的内容被丢弃，上传依然可能已经完成
+		h.fail(ctx, http.StatusRequestEntityTooLarge, errTooLarge.Error(), err)
+	default:
+		h.fail(ctx, appendStatus(err), "", err)
+		return
+	}
+	if info.Done() && h.onComplete != nil {
+		h.onComplete(ctx, info)
+	}
+}
+
+func (h *Handler) terminate(ctx *lr.Context) {
+	if !h.checkVersion(ctx) {
+		return
+	}
+	id := uploadID(ctx)
+	if !h.lock(id) {
+		h.fail(ctx, http.StatusLocked, "上传正在被其他请求写入", nil)
+		return
+	}
+	defer h.unlock(id)
+	if err := h.store.Terminate(ctx.Req.Context(), id); err != nil {
+		if errors.Is(err, ErrNotFound) {
+			h.fail(ctx, http.StatusNotFound, "", err)
+			return
+		}
+		h.fail(ctx, http.StatusInternalServerError, "", err)
+		return
+	}
+	_ = ctx.NoContent()
+}
+
+// override 按照X-HTTP-Method-Override处理
+func (h *Handler) override(ctx *lr.Context) {
+	switch strings.ToUpper(ctx.Req.Header.Get("X-HTTP-Method-Override")) {
+	case http.MethodPatch:
+		h.patch(ctx)
+	case http.MethodDelete:
+		h.terminate(ctx)
+	case http.MethodHead:
+		h.head(ctx)
+	default:
+		ctx.Resp.Header().Set("Tus-Resumable", Version)
+		ctx.Resp.Header().Set("Allow", "DELETE, HEAD, OPTIONS, PATCH")
+		h.fail(ctx, http.StatusMethodNotAllowed, "", nil)
+	}
+}
+
+// info 查询上传，不存在或者已经过期时写入错误响应并返回false
+func (h *Handler) info(ctx *lr.Context, id string) (Info, bool) {
+	info, err := h.store.Info(ctx.Req.Context(), id)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			h.fail(ctx, http.StatusNotFound, "", err)
+			return info, false
+		}
+		h.fail(ctx, http.StatusInternalServerError, "", err)
+		return info, false
+	}
+	if info.Expired(h.now()) {
+		h.fail(ctx, http.StatusGone, "上传已经过期", nil)
+		return info, false
+	}
+	return info, true
+}
+
+// checkVersion 所有的响应都带上Tus-Resumable，除了OPTIONS之外的请求都需要是支持的版本
+func (h *Handler) checkVersion(ctx *lr.Context) bool {
+	header := ctx.Resp.Header()
+	header.Set("Tus-Resumable", Version)
+	if ctx.Req.Header.Get("Tus-Resumable") == Version {
+		return true
+	}
+	header.Set("Tus-Version", Version)
+	h.fail(ctx, http.StatusPreconditionFailed, "不支持的Tus-Resumable版本", nil)
+	return false
+}
+
+func (h *Handler) setExpires(ctx *lr.Context, info Info) {
+	if !info.Done() && !info.ExpiresAt.IsZero() {
+		ctx.Resp.Header().Set("Upload-Expires", info.ExpiresAt.UTC().Format(http.TimeFormat))
+	}
+}
+
+// fail 把*lr.HTTPError交给ErrorHandler处理
+func (h *Handler) fail(ctx *lr.Context, status int, msg string, err error) {
+	httpErr := lr.NewHTTPError(status, status, msg)
+	httpErr.Err = err
+	ctx.AbortWithError(httpErr)
+}
+
+// appendStatus Store.Append返回的错误对应的状态码
+func appendStatus(err error) int {
+	switch {
+	case errors.Is(err, errTooLarge):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, ErrOffsetMismatch):
+		return http.StatusConflict
+	case errors.Is(err, ErrNotFound):
+		return http.StatusNotFound
+	default:
+		// 一般是客户端断开，已经接收的内容保存下来了，响应多半也送不到客户端
+		return http.StatusInternalServerError
+	}
+}
+
+// lock 同一个上传同时只能有一个请求写入，已经被锁定时返回false
+func (h *Handler) lock(id string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.locks[id]; ok {
+		return false
+	}
+	h.locks[id] = struct{}{}
+	return true
+}
+
+func (h *Handler) unlock(id string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.locks, id)
+}
+
+// uploadID 路由中的:id参数
+func uploadID(ctx *lr.Context) string {
+	id, _ := ctx.PathValue("id").String()
+	return id
+}
+
+func newID() string {
+	buf := make([]byte, 16)
+	_, _ = rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+// limitedReader 最多读取n个字节，还有更多内容时返回errTooLarge
+type limitedReader struct {
+	r io.Reader
+	n int64
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.n <= 0 {
+		// 多读一个字节判断是否还有内容
+		var buf [1]byte
+		n, err := l.r.Read(buf[:])
+		if n > 0 {
+			return 0, errTooLarge
+		}
+		if err == nil {
+			return 0, nil
+		}
+		return 0, err
+	}
+	if int64(len(p)) > l.n {
+		p = p[:l.n]
+	}
+	n, err := l.r.Read(p)
+	l.n -= int64(n)
+	return n, err
+}
+
+// ParseMetadata 解析Upload-Metadata：逗号分隔的键值对，键和base64编码的值之间用空格分隔，值可以省略
+//
+//	ParseMetadata("filename d29ybGRfZG9taW5hdGlvbl9wbGFuLnBkZg==,is_confidential")
+func ParseMetadata(header string) (map[string]string, error) {
+	if strings.TrimSpace(header) == "" {
+		return nil, nil
+	}
+	res := make(map[string]string, 4)
+	for _, pair := range strings.Split(header, ",") {
+		fields := strings.Fields(pair)
+		if len(fields) == 0 || len(fields) > 2 {
+			return nil, fmt.Errorf("tus: 不合法的Upload-Metadata %q", pair)
+		}
+		key := fields[0]
+		if _, ok := res[key]; ok {
+			return nil, fmt.Errorf("tus: Upload-Metadata中重复的key %s", key)
+		}
+		if len(fields) == 1 {
+			res[key] = ""
+			continue
+		}
+		val, err := base64.StdEncoding.DecodeString(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("tus: Upload-Metadata中%s的值不是base64: %w", key, err)
+		}
+		res[key] = string(val)
+	}
+	return res, nil
+}
+
+// EncodeMetadata 按照key排序编码为Upload-Metadata
+func EncodeMetadata(metadata map[string]string) string {
+	keys := make([]string, 0, len(metadata))
+	for key := range metadata {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if metadata[key] == "" {
+			pairs = append(pairs, key)
+			continue
+		}
+		pairs = append(pairs, key+" "+base64.StdEncoding.EncodeToString([]byte(metadata[key])))
+	}
+	return strings.Join(pairs, ",")
+}
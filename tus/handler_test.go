@@ -0,0 +1,342 @@
+package tus
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/liquanhui-99/lr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestServer(handler *Handler) *lr.HTTPServer {
+	server := lr.NewHTTPServer("tcp", ":8081")
+	handler.Register(server, "/files")
+	return server
+}
+
+func tusRequest(method, target, body string, headers map[string]string) *http.Request {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set("Tus-Resumable", Version)
+	for name, value := range headers {
+		if value == "" {
+			req.Header.Del(name)
+			continue
+		}
+		req.Header.Set(name, value)
+	}
+	return req
+}
+
+func serve(server *lr.HTTPServer, req *http.Request) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	server.ServeHTTP(recorder, req)
+	return recorder
+}
+
+func TestHandler_Upload(t *testing.T) {
+	store := NewMemoryStore()
+	var completed []Info
+	handler := NewHandler(store, OnComplete(func(ctx *lr.Context, info Info) {
+		completed = append(completed, info)
+	}))
+	server := newTestServer(handler)
+
+	// 创建
+	resp := serve(server, tusRequest(http.MethodPost, "http://example.com/files", "", map[string]string{
+		"Upload-Length":   "10",
+		"Upload-Metadata": "filename YS50eHQ=,private",
+	}))
+	require.Equal(t, http.StatusCreated, resp.Code)
+	assert.Equal(t, Version, resp.Header().Get("Tus-Resumable"))
+	location := resp.Header().Get("Location")
+	require.True(t, strings.HasPrefix(location, "/files/"), location)
+	id := location[strings.LastIndexByte(location, '/')+1:]
+	assert.Empty(t, resp.Header().Get("Upload-Expires"))
+
+	patch := func(offset, body string) *httptest.ResponseRecorder {
+		return serve(server, tusRequest(http.MethodPatch, location, body, map[string]string{
+			"Content-Type":  offsetContentType,
+			"Upload-Offset": offset,
+		}))
+	}
+
+	resp = patch("0", "0123")
+	require.Equal(t, http.StatusNoContent, resp.Code)
+	assert.Equal(t, "4", resp.Header().Get("Upload-Offset"))
+
+	// 偏移量不一致
+	resp = patch("0", "0123")
+	assert.Equal(t, http.StatusConflict, resp.Code)
+
+	// 恢复上传前先查询偏移量
+	resp = serve(server, tusRequest(http.MethodHead, location, "", nil))
+	require.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, "4", resp.Header().Get("Upload-Offset"))
+	assert.Equal(t, "10", resp.Header().Get("Upload-Length"))
+	assert.Equal(t, "filename YS50eHQ=,private", resp.Header().Get("Upload-Metadata"))
+	assert.Equal(t, "no-store", resp.Header().Get("Cache-Control"))
+
+	// 超过Upload-Length
+	resp = patch("4", "456789abc")
+	assert.Equal(t, http.StatusRequestEntityTooLarge, resp.Code)
+	assert.Empty(t, completed)
+
+	resp = patch("4", "456789")
+	require.Equal(t, http.StatusNoContent, resp.Code)
+	assert.Equal(t, "10", resp.Header().Get("Upload-Offset"))
+
+	require.Len(t, completed, 1)
+	assert.Equal(t, id, completed[0].ID)
+	assert.Equal(t, map[string]string{"filename": "a.txt", "private": ""}, completed[0].Metadata)
+	rc, err := store.Reader(context.Background(), id)
+	require.NoError(t, err)
+	data, err := io.ReadAll(rc)
+	require.NoError(t, err)
+	assert.Equal(t, "0123456789", string(data))
+
+	// 删除
+	resp = serve(server, tusRequest(http.MethodDelete, location, "", nil))
+	assert.Equal(t, http.StatusNoContent, resp.Code)
+	resp = serve(server, tusRequest(http.MethodHead, location, "", nil))
+	assert.Equal(t, http.StatusNotFound, resp.Code)
+	resp = serve(server, tusRequest(http.MethodDelete, location, "", nil))
+	assert.Equal(t, http.StatusNotFound, resp.Code)
+}
+
+func TestHandler_Errors(t *testing.T) {
+	store := NewMemoryStore()
+	handler := NewHandler(store, MaxSize(100))
+	server := newTestServer(handler)
+	require.NoError(t, store.Create(context.Background(), Info{ID: "abc", Size: 10}))
+
+	testCases := []struct {
+		name     string
+		req      *http.Request
+		wantCode int
+	}{
+		{
+			name:     "缺少Tus-Resumable",
+			req:      tusRequest(http.MethodPost, "/files", "", map[string]string{"Tus-Resumable": "", "Upload-Length": "1"}),
+			wantCode: http.StatusPreconditionFailed,
+		},
+		{
+			name:     "不支持的版本",
+			req:      tusRequest(http.MethodHead, "/files/abc", "", map[string]string{"Tus-Resumable": "0.2.2"}),
+			wantCode: http.StatusPreconditionFailed,
+		},
+		{
+			name:     "缺少Upload-Length",
+			req:      tusRequest(http.MethodPost, "/files", "", nil),
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "不支持Upload-Defer-Length",
+			req:      tusRequest(http.MethodPost, "/files", "", map[string]string{"Upload-Defer-Length": "1"}),
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "超过MaxSize",
+			req:      tusRequest(http.MethodPost, "/files", "", map[string]string{"Upload-Length": "101"}),
+			wantCode: http.StatusRequestEntityTooLarge,
+		},
+		{
+			name:     "Upload-Metadata不是base64",
+			req:      tusRequest(http.MethodPost, "/files", "", map[string]string{"Upload-Length": "1", "Upload-Metadata": "filename !!!"}),
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "Content-Type不对",
+			req:      tusRequest(http.MethodPatch, "/files/abc", "0", map[string]string{"Upload-Offset": "0", "Content-Type": "application/octet-stream"}),
+			wantCode: http.StatusUnsupportedMediaType,
+		},
+		{
+			name:     "缺少Upload-Offset",
+			req:      tusRequest(http.MethodPatch, "/files/abc", "0", map[string]string{"Content-Type": offsetContentType}),
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "上传不存在",
+			req:      tusRequest(http.MethodPatch, "/files/missing", "0", map[string]string{"Upload-Offset": "0", "Content-Type": offsetContentType}),
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "不支持的Override",
+			req:      tusRequest(http.MethodPost, "/files/abc", "", map[string]string{"X-HTTP-Method-Override": "GET"}),
+			wantCode: http.StatusMethodNotAllowed,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp := serve(server, tc.req)
+			assert.Equal(t, tc.wantCode, resp.Code)
+			assert.Equal(t, Version, resp.Header().Get("Tus-Resumable"))
+		})
+	}
+	// 上传没有被错误的请求改变
+	info, err := store.Info(context.Background(), "abc")
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), info.Offset)
+}
+
+func TestHandler_Options(t *testing.T) {
+	server := newTestServer(NewHandler(NewMemoryStore(), MaxSize(100)))
+	for _, target := range []string{"/files", "/files/abc"} {
+		req := httptest.NewRequest(http.MethodOptions, target, nil)
+		resp := serve(server, req)
+		assert.Equal(t, http.StatusNoContent, resp.Code)
+		assert.Equal(t, Version, resp.Header().Get("Tus-Version"))
+		assert.Equal(t, Extensions, resp.Header().Get("Tus-Extension"))
+		assert.Equal(t, "100", resp.Header().Get("Tus-Max-Size"))
+	}
+}
+
+func TestHandler_CreationWithUpload(t *testing.T) {
+	store := NewFileStore(t.TempDir())
+	completed := 0
+	handler := NewHandler(store, OnComplete(func(ctx *lr.Context, info Info) {
+		completed++
+	}))
+	server := newTestServer(handler)
+
+	resp := serve(server, tusRequest(http.MethodPost, "/files", "hello", map[string]string{
+		"Upload-Length": "5",
+		"Content-Type":  offsetContentType,
+	}))
+	require.Equal(t, http.StatusCreated, resp.Code)
+	assert.Equal(t, "5", resp.Header().Get("Upload-Offset"))
+	assert.Equal(t, 1, completed)
+
+	// 内容超过Upload-Length时不创建
+	resp = serve(server, tusRequest(http.MethodPost, "/files", "hello!", map[string]string{
+		"Upload-Length": "5",
+		"Content-Type":  offsetContentType,
+	}))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, resp.Code)
+	infos, err := store.List(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, infos, 1)
+
+	// 存储写入失败时返回错误，不返回Upload-Offset
+	server = newTestServer(NewHandler(failingAppendStore{NewMemoryStore()}))
+	resp = serve(server, tusRequest(http.MethodPost, "/files", "hello", map[string]string{
+		"Upload-Length": "5",
+		"Content-Type":  offsetContentType,
+	}))
+	assert.Equal(t, http.StatusInternalServerError, resp.Code)
+	assert.Empty(t, resp.Header().Get("Upload-Offset"))
+	assert.NotEmpty(t, resp.Header().Get("Location"))
+}
+
+type failingAppendStore struct {
+	Store
+}
+
+func (failingAppendStore) Append(ctx context.Context, id string, offset int64, r io.Reader) (int64, error) {
+	return 0, errors.New("磁盘已满")
+}
+
+func TestHandler_MethodOverride(t *testing.T) {
+	store := NewMemoryStore()
+	server := newTestServer(NewHandler(store))
+	require.NoError(t, store.Create(context.Background(), Info{ID: "abc", Size: 3}))
+
+	resp := serve(server, tusRequest(http.MethodPost, "/files/abc", "abc", map[string]string{
+		"X-HTTP-Method-Override": "PATCH",
+		"Content-Type":           offsetContentType,
+		"Upload-Offset":          "0",
+	}))
+	require.Equal(t, http.StatusNoContent, resp.Code)
+	assert.Equal(t, "3", resp.Header().Get("Upload-Offset"))
+
+	// 结尾的/不影响id
+	resp = serve(server, tusRequest(http.MethodHead, "/files/abc/", "", nil))
+	require.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, "3", resp.Header().Get("Upload-Offset"))
+
+	resp = serve(server, tusRequest(http.MethodPost, "/files/abc", "", map[string]string{"X-HTTP-Method-Override": "DELETE"}))
+	require.Equal(t, http.StatusNoContent, resp.Code)
+	_, err := store.Info(context.Background(), "abc")
+	assert.Equal(t, ErrNotFound, err)
+}
+
+func TestHandler_Expiration(t *testing.T) {
+	store := NewMemoryStore()
+	now := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	handler := NewHandler(store, Expiration(time.Hour))
+	handler.now = func() time.Time { return now }
+	server := newTestServer(handler)
+
+	create := func(body string) string {
+		resp := serve(server, tusRequest(http.MethodPost, "/files", body, map[string]string{
+			"Upload-Length": "5",
+			"Content-Type":  offsetContentType,
+		}))
+		require.Equal(t, http.StatusCreated, resp.Code)
+		if body != "hello" {
+			assert.Equal(t, "Sun, 01 Jan 2023 01:00:00 GMT", resp.Header().Get("Upload-Expires"))
+		} else {
+			// 完成的上传不过期
+			assert.Empty(t, resp.Header().Get("Upload-Expires"))
+		}
+		return resp.Header().Get("Location")
+	}
+	unfinished := create("he")
+	finished := create("hello")
+
+	now = now.Add(time.Hour)
+	resp := serve(server, tusRequest(http.MethodHead, unfinished, "", nil))
+	assert.Equal(t, http.StatusGone, resp.Code)
+	resp = serve(server, tusRequest(http.MethodPatch, unfinished, "llo", map[string]string{
+		"Content-Type":  offsetContentType,
+		"Upload-Offset": "2",
+	}))
+	assert.Equal(t, http.StatusGone, resp.Code)
+	resp = serve(server, tusRequest(http.MethodHead, finished, "", nil))
+	assert.Equal(t, http.StatusOK, resp.Code)
+
+	cnt, err := handler.CleanupExpired(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, cnt)
+	infos, err := store.List(context.Background())
+	require.NoError(t, err)
+	require.Len(t, infos, 1)
+	assert.True(t, strings.HasSuffix(finished, infos[0].ID))
+}
+
+func TestHandler_Locked(t *testing.T) {
+	store := NewMemoryStore()
+	handler := NewHandler(store)
+	server := newTestServer(handler)
+	require.NoError(t, store.Create(context.Background(), Info{ID: "abc", Size: 3}))
+
+	require.True(t, handler.lock("abc"))
+	resp := serve(server, tusRequest(http.MethodPatch, "/files/abc", "abc", map[string]string{
+		"Content-Type":  offsetContentType,
+		"Upload-Offset": "0",
+	}))
+	assert.Equal(t, http.StatusLocked, resp.Code)
+	handler.unlock("abc")
+}
+
+func TestMetadata(t *testing.T) {
+	metadata, err := ParseMetadata("filename d29ybGRfZG9taW5hdGlvbl9wbGFuLnBkZg==, is_confidential")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"filename": "world_domination_plan.pdf", "is_confidential": ""}, metadata)
+	assert.Equal(t, "filename d29ybGRfZG9taW5hdGlvbl9wbGFuLnBkZg==,is_confidential", EncodeMetadata(metadata))
+
+	for _, header := range []string{"a YQ==,a YQ==", "a YQ== b", ",", "a !"} {
+		_, err = ParseMetadata(header)
+		assert.Error(t, err, header)
+	}
+	metadata, err = ParseMetadata("")
+	require.NoError(t, err)
+	assert.Nil(t, metadata)
+}
@@ -0,0 +1,104 @@
+package tus
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"sort"
+	"sync"
+)
+
+// MemoryStore 保存在内存中的上传，适合测试
+type MemoryStore struct {
+	mu      sync.RWMutex
+	uploads map[string]*memoryUpload
+}
+
+type memoryUpload struct {
+	info Info
+	data []byte
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		uploads: make(map[string]*memoryUpload, 8),
+	}
+}
+
+func (m *MemoryStore) Create(ctx context.Context, info Info) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	info.Offset = 0
+	m.uploads[info.ID] = &memoryUpload{info: info}
+	return nil
+}
+
+func (m *MemoryStore) Info(ctx context.Context, id string) (Info, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	upload, ok := m.uploads[id]
+	if !ok {
+		return Info{}, ErrNotFound
+	}
+	return upload.info, nil
+}
+
+// Append 先读取全部内容再加锁写入，读取失败时保存已经读取的部分
+func (m *MemoryStore) Append(ctx context.Context, id string, offset int64, r io.Reader) (int64, error) {
+	info, err := m.Info(ctx, id)
+	if err != nil {
+		return 0, err
+	}
+	if info.Offset != offset {
+		return 0, ErrOffsetMismatch
+	}
+	buf := &bytes.Buffer{}
+	_, readErr := buf.ReadFrom(r)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	upload, ok := m.uploads[id]
+	if !ok {
+		return 0, ErrNotFound
+	}
+	if upload.info.Offset != offset {
+		return 0, ErrOffsetMismatch
+	}
+	upload.data = append(upload.data, buf.Bytes()...)
+	upload.info.Offset += int64(buf.Len())
+	return int64(buf.Len()), readErr
+}
+
+func (m *MemoryStore) Reader(ctx context.Context, id string) (io.ReadCloser, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	upload, ok := m.uploads[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	// 之后的Append可能会重新分配data，这里只读取当前的长度
+	return io.NopCloser(bytes.NewReader(upload.data[:upload.info.Offset])), nil
+}
+
+func (m *MemoryStore) Terminate(ctx context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.uploads[id]; !ok {
+		return ErrNotFound
+	}
+	delete(m.uploads, id)
+	return nil
+}
+
+func (m *MemoryStore) List(ctx context.Context) ([]Info, error) {
+	m.mu.RLock()
+	res := make([]Info, 0, len(m.uploads))
+	for _, upload := range m.uploads {
+		res = append(res, upload.info)
+	}
+	m.mu.RUnlock()
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].ID < res[j].ID
+	})
+	return res, nil
+}
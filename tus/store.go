@@ -0,0 +1,55 @@
+package tus
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+)
+
+var (
+	ErrNotFound       = errors.New("tus: 上传不存在")
+	ErrOffsetMismatch = errors.New("tus: 偏移量和已经上传的长度不一致")
+)
+
+// Info 一次上传的信息
+type Info struct {
+	ID string `json:"id"`
+	// 文件的总长度
+	Size int64 `json:"size"`
+	// 已经接收的长度，Store根据保存的内容计算，不需要持久化
+	Offset int64 `json:"-"`
+	// 客户端通过Upload-Metadata传递的信息，例如文件名
+	Metadata  map[string]string `json:"metadata,omitempty"`
+	CreatedAt time.Time         `json:"created_at"`
+	// 未完成的上传在这个时间之后过期，零值表示不过期
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// Done 是否已经接收了全部内容
+func (i Info) Done() bool {
+	return i.Offset >= i.Size
+}
+
+// Expired 未完成的上传是否已经过期
+func (i Info) Expired(now time.Time) bool {
+	return !i.Done() && !i.ExpiresAt.IsZero() && !now.Before(i.ExpiresAt)
+}
+
+// Store 保存上传中的文件，Handler保证同一个进程中同一个上传不会并发写入
+type Store interface {
+	// Create 创建一个空的上传，info.ID由Handler生成
+	Create(ctx context.Context, info Info) error
+	// Info 上传的信息，不存在时返回ErrNotFound
+	Info(ctx context.Context, id string) (Info, error)
+	// Append 把r的内容追加到上传的末尾，返回写入的字节数
+	// offset和已经上传的长度不一致时返回ErrOffsetMismatch；
+	// r读取失败(例如客户端断开)时，已经读取的部分也要保存下来，客户端恢复上传时从这里继续
+	Append(ctx context.Context, id string, offset int64, r io.Reader) (int64, error)
+	// Reader 读取已经上传的内容，调用者负责关闭
+	Reader(ctx context.Context, id string) (io.ReadCloser, error)
+	// Terminate 删除上传和已经上传的内容，不存在时返回ErrNotFound
+	Terminate(ctx context.Context, id string) error
+	// List 所有的上传，用于清理过期的上传
+	List(ctx context.Context) ([]Info, error)
+}
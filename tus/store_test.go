@@ -0,0 +1,78 @@
+package tus
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore(t *testing.T) {
+	testCases := map[string]func(t *testing.T) Store{
+		"FileStore": func(t *testing.T) Store {
+			return NewFileStore(t.TempDir())
+		},
+		"MemoryStore": func(t *testing.T) Store {
+			return NewMemoryStore()
+		},
+	}
+
+	for name, newStore := range testCases {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			store := newStore(t)
+			created := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+			require.NoError(t, store.Create(ctx, Info{ID: "b", Size: 10, Metadata: map[string]string{"filename": "a.txt"}, CreatedAt: created}))
+			require.NoError(t, store.Create(ctx, Info{ID: "a", Size: 1}))
+
+			n, err := store.Append(ctx, "b", 0, strings.NewReader("0123"))
+			require.NoError(t, err)
+			assert.Equal(t, int64(4), n)
+
+			_, err = store.Append(ctx, "b", 0, strings.NewReader("0123"))
+			assert.Equal(t, ErrOffsetMismatch, err)
+
+			// 读取失败时保留已经读取的部分
+			n, err = store.Append(ctx, "b", 4, io.MultiReader(strings.NewReader("45"), errReader{}))
+			assert.Error(t, err)
+			assert.Equal(t, int64(2), n)
+
+			info, err := store.Info(ctx, "b")
+			require.NoError(t, err)
+			assert.Equal(t, Info{ID: "b", Size: 10, Offset: 6, Metadata: map[string]string{"filename": "a.txt"}, CreatedAt: created}, info)
+
+			rc, err := store.Reader(ctx, "b")
+			require.NoError(t, err)
+			data, err := io.ReadAll(rc)
+			require.NoError(t, err)
+			require.NoError(t, rc.Close())
+			assert.Equal(t, "012345", string(data))
+
+			infos, err := store.List(ctx)
+			require.NoError(t, err)
+			require.Len(t, infos, 2)
+			assert.Equal(t, "a", infos[0].ID)
+			assert.Equal(t, "b", infos[1].ID)
+
+			require.NoError(t, store.Terminate(ctx, "b"))
+			assert.Equal(t, ErrNotFound, store.Terminate(ctx, "b"))
+			_, err = store.Info(ctx, "b")
+			assert.Equal(t, ErrNotFound, err)
+			_, err = store.Append(ctx, "b", 6, strings.NewReader("6"))
+			assert.Equal(t, ErrNotFound, err)
+			_, err = store.Reader(ctx, "../b")
+			assert.Equal(t, ErrNotFound, err)
+		})
+	}
+}
+
+type errReader struct{}
+
+func (errReader) Read([]byte) (int, error) {
+	return 0, errors.New("客户端断开")
+}
@@ -0,0 +1,91 @@
+package lr
+
+import (
+	"net/http"
+	"reflect"
+)
+
+// TypedHandlerInfo Typed创建的handler的类型信息，用于生成文档之类的内省场景
+type TypedHandlerInfo struct {
+	// 请求的类型
+	Req reflect.Type
+	// 响应的类型
+	Resp reflect.Type
+}
+
+// Typed 把强类型的业务函数适配为HandleFunc
+// 先通过Bind把path、query、header和body绑定到Req，再按照validate标签校验，然后调用fn，
+// 返回值通过Negotiate按照Accept渲染，Resp为nil时返回204，错误交给ErrorHandler处理
+// 需要在路由上记录类型信息(例如生成OpenAPI文档)时，使用HandleTyped注册
+//
+//	update := func(ctx *lr.Context, req UpdateUserReq) (*User, error) {
+//		return svc.Update(ctx.Req.Context(), req)
+//	}
+//	h.POST("/user/:id", lr.Typed(update))
+func Typed[Req, Resp any](fn func(ctx *Context, req Req) (Resp, error)) HandleFunc {
+	return func(ctx *Context) {
+		var req Req
+		if err := bindTyped(ctx, &req); err != nil {
+			ctx.handleError(err)
+			return
+		}
+
+		resp, err := fn(ctx, req)
+		if err != nil {
+			ctx.handleError(err)
+			return
+		}
+
+		if isNilValue(resp) {
+			_ = ctx.NoContent()
+			return
+		}
+		if err = ctx.Negotiate(http.StatusOK, resp); err != nil {
+			ctx.handleError(err)
+		}
+	}
+}
+
+// HandleTyped 通过Typed注册fn，同时在路由上记录fn的请求和响应类型，可以通过HTTPServer.Routes获取
+// 类型信息和handler来自同一个fn，不会出现文档和实际处理逻辑不一致的情况
+//
+//	lr.HandleTyped(h, http.MethodPost, "/user/:id", update)
+func HandleTyped[Req, Resp any](server Server, method, path string, fn func(ctx *Context, req Req) (Resp, error), opts ...RouteOption) {
+	types := WithTypes(TypedHandlerInfo{
+		Req:  reflect.TypeOf((*Req)(nil)).Elem(),
+		Resp: reflect.TypeOf((*Resp)(nil)).Elem(),
+	})
+	server.AddRoute(method, path, Typed(fn), append([]RouteOption{types}, opts...)...)
+}
+
+// WithTypes 注册路由时记录请求和响应类型
+func WithTypes(info TypedHandlerInfo) RouteOption {
+	return func(r *RouteInfo) {
+		r.Types = &info
+	}
+}
+
+// bindTyped Req是结构体或者结构体指针时才绑定和校验
+func bindTyped(ctx *Context, req any) error {
+	rv := reflect.ValueOf(req).Elem()
+	switch {
+	case rv.Kind() == reflect.Struct:
+		return ctx.BindAndValidate(req)
+	case rv.Kind() == reflect.Pointer && rv.Type().Elem().Kind() == reflect.Struct:
+		rv.Set(reflect.New(rv.Type().Elem()))
+		return ctx.BindAndValidate(rv.Interface())
+	}
+	return nil
+}
+
+func isNilValue(val any) bool {
+	if val == nil {
+		return true
+	}
+	rv := reflect.ValueOf(val)
+	switch rv.Kind() {
+	case reflect.Pointer, reflect.Interface, reflect.Map, reflect.Slice, reflect.Func, reflect.Chan:
+		return rv.IsNil()
+	}
+	return false
+}
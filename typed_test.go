@@ -0,0 +1,97 @@
+package lr
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type typedUpdateReq struct {
+	ID   int    `path:"id"`
+	Name string `json:"name" validate:"required"`
+}
+
+type typedUser struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+func TestTyped(t *testing.T) {
+	update := func(ctx *Context, req *typedUpdateReq) (*typedUser, error) {
+		if req.ID == 404 {
+			return nil, NewHTTPError(http.StatusNotFound, 10404, "用户不存在")
+		}
+		if req.ID == 204 {
+			return nil, nil
+		}
+		return &typedUser{ID: req.ID, Name: req.Name}, nil
+	}
+	h := NewHTTPServer("tcp", ":8081")
+	HandleTyped(h, http.MethodPut, "/user/:id", update)
+
+	testCases := []struct {
+		name       string
+		path       string
+		body       string
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name:       "成功",
+			path:       "/user/1",
+			body:       `{"name":"Tom"}`,
+			wantStatus: http.StatusOK,
+			wantBody:   `{"id":1,"name":"Tom"}`,
+		},
+		{
+			name:       "校验失败",
+			path:       "/user/1",
+			body:       `{}`,
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `{"message":"请求参数错误","errors":[{"field":"name","rule":"required","message":"不能为空"}]}`,
+		},
+		{
+			name:       "业务错误",
+			path:       "/user/404",
+			body:       `{"name":"Tom"}`,
+			wantStatus: http.StatusNotFound,
+			wantBody:   `{"code":10404,"message":"用户不存在"}`,
+		},
+		{
+			name:       "没有返回值",
+			path:       "/user/204",
+			body:       `{"name":"Tom"}`,
+			wantStatus: http.StatusNoContent,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPut, tc.path, strings.NewReader(tc.body))
+			req.Header.Set("Content-Type", "application/json")
+			recorder := httptest.NewRecorder()
+			h.ServeHTTP(recorder, req)
+
+			assert.Equal(t, tc.wantStatus, recorder.Code)
+			assert.Equal(t, tc.wantBody, recorder.Body.String())
+		})
+	}
+
+	// 类型信息记录在路由上
+	other := func(ctx *Context, req struct{}) (string, error) {
+		return "ok", nil
+	}
+	HandleTyped(h, http.MethodGet, "/other", other)
+	h.GET("/plain", func(ctx *Context) {})
+	types := make(map[string]*TypedHandlerInfo)
+	for _, route := range h.Routes() {
+		types[route.Method+" "+route.Path] = route.Types
+	}
+	assert.Equal(t, &TypedHandlerInfo{Req: reflect.TypeOf(&typedUpdateReq{}), Resp: reflect.TypeOf(&typedUser{})}, types["PUT /user/:id"])
+	assert.Equal(t, reflect.TypeOf(""), types["GET /other"].Resp)
+	assert.Nil(t, types["GET /plain"])
+}
@@ -0,0 +1,535 @@
+package lr
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// ValidationFunc 自定义校验规则，val是字段的值(指针已经解引用)，param是规则=后面的参数
+type ValidationFunc func(val reflect.Value, param string) bool
+
+// ValidationFieldError 单个字段校验失败的信息
+type ValidationFieldError struct {
+	// 字段路径，优先使用json标签的名字，嵌套字段用.连接，切片元素用[i]
+	Field string `json:"field"`
+	// 失败的规则名
+	Rule string `json:"rule"`
+	// 规则的参数
+	Param string `json:"param,omitempty"`
+	// 可读的错误信息
+	Message string `json:"message"`
+}
+
+func (e *ValidationFieldError) Error() string {
+	return e.Field + ": " + e.Message
+}
+
+// ValidationErrors 所有校验失败的字段，可以通过Context.RespBadRequest渲染为400响应
+type ValidationErrors []*ValidationFieldError
+
+func (e ValidationErrors) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, fe := range e {
+		msgs = append(msgs, fe.Error())
+	}
+	return "lr: 参数校验失败 " + strings.Join(msgs, "; ")
+}
+
+// ErrUnknownValidationRule validate标签中使用了没有注册的规则，一般是标签写错了
+var ErrUnknownValidationRule = errors.New("lr: 未注册的校验规则")
+
+var (
+	validationMu sync.RWMutex
+	// validationRules 所有的校验规则，key是规则名
+	validationRules = map[string]ValidationFunc{
+		"min":      validateMin,
+		"max":      validateMax,
+		"len":      validateLen,
+		"gt":       validateGt,
+		"gte":      validateMin,
+		"lt":       validateLt,
+		"lte":      validateMax,
+		"eq":       validateEq,
+		"ne":       validateNe,
+		"oneof":    validateOneOf,
+		"email":    validateEmail,
+		"url":      validateURL,
+		"alpha":    validateRegexp(regexp.MustCompile(`^[a-zA-Z]+$`)),
+		"alphanum": validateRegexp(regexp.MustCompile(`^[a-zA-Z0-9]+$`)),
+		"numeric":  validateRegexp(regexp.MustCompile(`^[-+]?[0-9]+(\.[0-9]+)?$`)),
+		"uuid":     validateRegexp(regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)),
+	}
+	// validationMessages 规则对应的错误信息，{param}会被替换为规则的参数
+	validationMessages = map[string]string{
+		"required": "不能为空",
+		"min":      "不能小于{param}",
+		"max":      "不能大于{param}",
+		"len":      "长度必须为{param}",
+		"gt":       "必须大于{param}",
+		"gte":      "不能小于{param}",
+		"lt":       "必须小于{param}",
+		"lte":      "不能大于{param}",
+		"eq":       "必须等于{param}",
+		"ne":       "不能等于{param}",
+		"oneof":    "必须是[{param}]中的一个",
+		"email":    "不是合法的邮箱地址",
+		"url":      "不是合法的URL",
+		"alpha":    "只能包含字母",
+		"alphanum": "只能包含字母和数字",
+		"numeric":  "必须是数字",
+		"uuid":     "不是合法的UUID",
+	}
+)
+
+// RegisterValidation 注册自定义校验规则，已经存在的同名规则会被覆盖
+// message是校验失败时的错误信息，可以使用{param}引用规则参数，为空时使用默认信息
+func RegisterValidation(name string, fn ValidationFunc, message string) {
+	if name == "" || name == "required" || name == "omitempty" || name == "dive" {
+		panic(fmt.Sprintf("lr: 不能注册校验规则[%s]", name))
+	}
+	validationMu.Lock()
+	defer validationMu.Unlock()
+	validationRules[name] = fn
+	if message != "" {
+		validationMessages[name] = message
+	}
+}
+
+// Validate 按照validate标签校验结构体，嵌套的结构体和结构体切片会被递归校验
+// 支持的特殊规则：required 必填；omitempty 零值时跳过后面的规则；dive 后面的规则作用于切片或者map的每个元素
+// 标签中有没有注册的规则时返回ErrUnknownValidationRule
+//
+//	type Req struct {
+//		Name  string   `json:"name" validate:"required,min=1,max=64"`
+//		Email string   `json:"email" validate:"omitempty,email"`
+//		Level string   `json:"level" validate:"oneof=low high"`
+//		Tags  []string `json:"tags" validate:"max=10,dive,min=1"`
+//	}
+func Validate(val any) error {
+	var errs ValidationErrors
+	if err := validateValue(reflect.ValueOf(val), "", &errs); err != nil {
+		return err
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// BindAndValidate 绑定请求数据之后按照validate标签校验
+func (c *Context) BindAndValidate(val any) error {
+	if err := c.Bind(val); err != nil {
+		return err
+	}
+	return Validate(val)
+}
+
+// badRequestResp 参数错误时的响应体
+type badRequestResp struct {
+	Message string                  `json:"message"`
+	Errors  []*ValidationFieldError `json:"errors,omitempty"`
+}
+
+// RespBadRequest 把绑定或者校验的错误渲染为400的json响应，每个失败的字段都会列出来
+func (c *Context) RespBadRequest(err error) error {
+	resp := badRequestResp{Message: c.localize("lr.bad_request", "请求参数错误")}
+
+	var (
+		validationErrs ValidationErrors
+		bindErrs       BindErrors
+	)
+	switch {
+	case errors.As(err, &validationErrs):
+		// 开启I18n时按照请求的语言替换错误信息，自定义规则可以在消息目录中添加lr.validation.规则名
+		resp.Errors = make([]*ValidationFieldError, 0, len(validationErrs))
+		for _, fe := range validationErrs {
+			localized := *fe
+			localized.Message = c.localize("lr.validation."+fe.Rule, fe.Message, "param", fe.Param)
+			resp.Errors = append(resp.Errors, &localized)
+		}
+	case errors.As(err, &bindErrs):
+		for _, fe := range bindErrs {
+			resp.Errors = append(resp.Errors, &ValidationFieldError{
+				Field:   fe.Field,
+				Rule:    "bind",
+				Message: c.LocalizeError(fe.Err),
+			})
+		}
+	case errors.Is(err, ErrRequestTooLarge):
+		resp.Message = c.LocalizeError(err)
+		return c.respJson(resp, http.StatusRequestEntityTooLarge)
+	case err != nil:
+		resp.Message = c.LocalizeError(err)
+	}
+
+	return c.respJson(resp, http.StatusBadRequest)
+}
+
+type validationRule struct {
+	name  string
+	param string
+}
+
+type validationField struct {
+	index int
+	// 错误信息中使用的字段名
+	name  string
+	rules []validationRule
+}
+
+var validationFieldCache sync.Map
+
+// cachedValidationFields 解析标签时检查规则是否已经注册，有错误时不缓存，注册规则之后可以重新解析
+func cachedValidationFields(typ reflect.Type) ([]validationField, error) {
+	if fields, ok := validationFieldCache.Load(typ); ok {
+		return fields.([]validationField), nil
+	}
+
+	var fields []validationField
+	for i := 0; i < typ.NumField(); i++ {
+		sf := typ.Field(i)
+		if !sf.IsExported() && !sf.Anonymous {
+			continue
+		}
+
+		field := validationField{index: i, name: sf.Name}
+		if name, _, _ := strings.Cut(sf.Tag.Get("json"), ","); name != "" && name != "-" {
+			field.name = name
+		}
+		tag := sf.Tag.Get("validate")
+		if tag == "-" {
+			continue
+		}
+		if tag != "" {
+			for _, r := range strings.Split(tag, ",") {
+				name, param, _ := strings.Cut(strings.TrimSpace(r), "=")
+				if _, ok := lookupValidationRule(name); !ok {
+					return nil, fmt.Errorf("%w [%s]: %s.%s", ErrUnknownValidationRule, name, typ, sf.Name)
+				}
+				field.rules = append(field.rules, validationRule{name: name, param: param})
+			}
+		}
+		fields = append(fields, field)
+	}
+
+	validationFieldCache.Store(typ, fields)
+	return fields, nil
+}
+
+// lookupValidationRule required、omitempty和dive没有对应的函数，只返回true
+func lookupValidationRule(name string) (ValidationFunc, bool) {
+	switch name {
+	case "required", "omitempty", "dive":
+		return nil, true
+	}
+	validationMu.RLock()
+	defer validationMu.RUnlock()
+	fn, ok := validationRules[name]
+	return fn, ok
+}
+
+// validateValue 递归校验结构体、结构体指针和结构体切片
+func validateValue(v reflect.Value, path string, errs *ValidationErrors) error {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		if v.Type() == timeType {
+			return nil
+		}
+		fields, err := cachedValidationFields(v.Type())
+		if err != nil {
+			return err
+		}
+		for _, f := range fields {
+			fv := v.Field(f.index)
+			name := joinValidationPath(path, f.name)
+			if v.Type().Field(f.index).Anonymous && len(f.rules) == 0 {
+				// 嵌入结构体的字段直接提升到当前层级
+				name = path
+			}
+			ok, err := applyValidationRules(fv, name, f.rules, errs)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				continue
+			}
+			if err = validateValue(fv, name, errs); err != nil {
+				return err
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if err := validateValue(v.Index(i), fmt.Sprintf("%s[%d]", path, i), errs); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		iter := v.MapRange()
+		for iter.Next() {
+			if err := validateValue(iter.Value(), fmt.Sprintf("%s[%v]", path, iter.Key()), errs); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// applyValidationRules 依次执行字段上的规则，每个字段只记录第一个失败的规则
+// 返回false表示字段已经失败，不需要再递归校验
+func applyValidationRules(v reflect.Value, path string, rules []validationRule, errs *ValidationErrors) (bool, error) {
+	for i, r := range rules {
+		switch r.name {
+		case "required":
+			if !hasValue(v) {
+				*errs = append(*errs, newValidationFieldError(path, r))
+				return false, nil
+			}
+			continue
+		case "omitempty":
+			if !hasValue(v) {
+				return true, nil
+			}
+			continue
+		case "dive":
+			elem := indirectValue(v)
+			if !elem.IsValid() {
+				return true, nil
+			}
+			switch elem.Kind() {
+			case reflect.Slice, reflect.Array:
+				for j := 0; j < elem.Len(); j++ {
+					if err := applyElemRules(elem.Index(j), fmt.Sprintf("%s[%d]", path, j), rules[i+1:], errs); err != nil {
+						return false, err
+					}
+				}
+			case reflect.Map:
+				iter := elem.MapRange()
+				for iter.Next() {
+					if err := applyElemRules(iter.Value(), fmt.Sprintf("%s[%v]", path, iter.Key()), rules[i+1:], errs); err != nil {
+						return false, err
+					}
+				}
+			}
+			// dive之后已经处理过元素，不再重复递归
+			return false, nil
+		}
+
+		fn, ok := lookupValidationRule(r.name)
+		if !ok {
+			return false, fmt.Errorf("%w [%s]: %s", ErrUnknownValidationRule, r.name, path)
+		}
+
+		elem := indirectValue(v)
+		// nil指针没有值可以校验，是否必填由required决定
+		if !elem.IsValid() {
+			return true, nil
+		}
+		if !fn(elem, r.param) {
+			*errs = append(*errs, newValidationFieldError(path, r))
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// applyElemRules dive之后对每个元素执行剩下的规则，再递归校验元素
+func applyElemRules(v reflect.Value, path string, rules []validationRule, errs *ValidationErrors) error {
+	ok, err := applyValidationRules(v, path, rules, errs)
+	if err != nil || !ok {
+		return err
+	}
+	return validateValue(v, path, errs)
+}
+
+func newValidationFieldError(path string, r validationRule) *ValidationFieldError {
+	validationMu.RLock()
+	msg, ok := validationMessages[r.name]
+	validationMu.RUnlock()
+	if !ok {
+		msg = "不满足" + r.name + "规则"
+	}
+	return &ValidationFieldError{
+		Field:   path,
+		Rule:    r.name,
+		Param:   r.param,
+		Message: strings.ReplaceAll(msg, "{param}", r.param),
+	}
+}
+
+func joinValidationPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func indirectValue(v reflect.Value) reflect.Value {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return reflect.Value{}
+		}
+		v = v.Elem()
+	}
+	return v
+}
+
+// hasValue required使用的判断，字符串、切片、map需要长度大于0，其他类型不能是零值
+func hasValue(v reflect.Value) bool {
+	v = indirectValue(v)
+	if !v.IsValid() {
+		return false
+	}
+	switch v.Kind() {
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		return v.Len() > 0
+	}
+	return !v.IsZero()
+}
+
+// compareValue 数字比较数值，字符串比较字符个数，切片和map比较长度
+// 返回值为-1、0、1，参数不合法时ok为false
+func compareValue(v reflect.Value, param string) (int, bool) {
+	var cur float64
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if v.Type() == durationType {
+			d, err := time.ParseDuration(param)
+			if err != nil {
+				return 0, false
+			}
+			return compareFloat(float64(v.Int()), float64(d)), true
+		}
+		cur = float64(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		cur = float64(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		cur = v.Float()
+	case reflect.String:
+		cur = float64(utf8.RuneCountInString(v.String()))
+	case reflect.Slice, reflect.Map, reflect.Array:
+		cur = float64(v.Len())
+	default:
+		return 0, false
+	}
+
+	target, err := strconv.ParseFloat(param, 64)
+	if err != nil {
+		return 0, false
+	}
+	return compareFloat(cur, target), true
+}
+
+func compareFloat(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func validateMin(v reflect.Value, param string) bool {
+	res, ok := compareValue(v, param)
+	return ok && res >= 0
+}
+
+func validateMax(v reflect.Value, param string) bool {
+	res, ok := compareValue(v, param)
+	return ok && res <= 0
+}
+
+func validateGt(v reflect.Value, param string) bool {
+	res, ok := compareValue(v, param)
+	return ok && res > 0
+}
+
+func validateLt(v reflect.Value, param string) bool {
+	res, ok := compareValue(v, param)
+	return ok && res < 0
+}
+
+func validateLen(v reflect.Value, param string) bool {
+	switch v.Kind() {
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		res, ok := compareValue(v, param)
+		return ok && res == 0
+	}
+	return false
+}
+
+func validateEq(v reflect.Value, param string) bool {
+	if v.Kind() == reflect.String {
+		return v.String() == param
+	}
+	if v.Kind() == reflect.Bool {
+		b, err := strconv.ParseBool(param)
+		return err == nil && v.Bool() == b
+	}
+	res, ok := compareValue(v, param)
+	return ok && res == 0
+}
+
+func validateNe(v reflect.Value, param string) bool {
+	return !validateEq(v, param)
+}
+
+func validateOneOf(v reflect.Value, param string) bool {
+	var cur string
+	switch v.Kind() {
+	case reflect.String:
+		cur = v.String()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		cur = strconv.FormatInt(v.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		cur = strconv.FormatUint(v.Uint(), 10)
+	default:
+		return false
+	}
+	for _, opt := range strings.Fields(param) {
+		if opt == cur {
+			return true
+		}
+	}
+	return false
+}
+
+func validateEmail(v reflect.Value, _ string) bool {
+	if v.Kind() != reflect.String {
+		return false
+	}
+	addr, err := mail.ParseAddress(v.String())
+	// 不允许带显示名称的地址，例如 "Tom <tom@example.com>"
+	return err == nil && addr.Address == v.String()
+}
+
+func validateURL(v reflect.Value, _ string) bool {
+	if v.Kind() != reflect.String {
+		return false
+	}
+	u, err := url.ParseRequestURI(v.String())
+	return err == nil && u.Scheme != "" && u.Host != ""
+}
+
+func validateRegexp(re *regexp.Regexp) ValidationFunc {
+	return func(v reflect.Value, _ string) bool {
+		return v.Kind() == reflect.String && re.MatchString(v.String())
+	}
+}
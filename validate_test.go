@@ -0,0 +1,149 @@
+package lr
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type validateAddress struct {
+	City string `json:"city" validate:"required"`
+	Zip  string `json:"zip" validate:"omitempty,numeric,len=6"`
+}
+
+type validateUserReq struct {
+	Name    string            `json:"name" validate:"required,min=1,max=8"`
+	Email   string            `json:"email" validate:"omitempty,email"`
+	Age     int               `json:"age" validate:"gte=0,lt=150"`
+	Level   string            `json:"level" validate:"oneof=low high"`
+	Tags    []string          `json:"tags" validate:"max=3,dive,min=2"`
+	Home    *validateAddress  `json:"home"`
+	Others  []validateAddress `json:"others"`
+	Website *string           `json:"website" validate:"omitempty,url"`
+}
+
+func TestValidate(t *testing.T) {
+	website := "not a url"
+	testCases := []struct {
+		name       string
+		val        any
+		wantFields map[string]string
+	}{
+		{
+			name: "通过",
+			val: &validateUserReq{
+				Name:  "Tom",
+				Email: "tom@example.com",
+				Level: "low",
+				Tags:  []string{"go", "web"},
+				Home:  &validateAddress{City: "北京", Zip: "100000"},
+			},
+		},
+		{
+			name: "失败",
+			val: validateUserReq{
+				Name:    "一二三四五六七八九",
+				Email:   "Tom <tom@example.com>",
+				Age:     200,
+				Level:   "mid",
+				Tags:    []string{"go", "a"},
+				Home:    &validateAddress{Zip: "abc"},
+				Others:  []validateAddress{{City: "上海"}, {}},
+				Website: &website,
+			},
+			wantFields: map[string]string{
+				"name":           "max",
+				"email":          "email",
+				"age":            "lt",
+				"level":          "oneof",
+				"tags[1]":        "min",
+				"home.city":      "required",
+				"home.zip":       "numeric",
+				"others[1].city": "required",
+				"website":        "url",
+			},
+		},
+		{
+			name: "必填",
+			val:  validateUserReq{Level: "high"},
+			wantFields: map[string]string{
+				"name": "required",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := Validate(tc.val)
+			if tc.wantFields == nil {
+				assert.NoError(t, err)
+				return
+			}
+			var errs ValidationErrors
+			require.ErrorAs(t, err, &errs)
+			got := map[string]string{}
+			for _, fe := range errs {
+				got[fe.Field] = fe.Rule
+			}
+			assert.Equal(t, tc.wantFields, got)
+		})
+	}
+}
+
+func TestRegisterValidation(t *testing.T) {
+	RegisterValidation("prefix", func(val reflect.Value, param string) bool {
+		return strings.HasPrefix(val.String(), param)
+	}, "必须以{param}开头")
+
+	err := Validate(struct {
+		Code string `validate:"prefix=lr-"`
+	}{Code: "go-1"})
+	var errs ValidationErrors
+	require.ErrorAs(t, err, &errs)
+	assert.Equal(t, "Code", errs[0].Field)
+	assert.Equal(t, "必须以lr-开头", errs[0].Message)
+
+	// 标签写错时返回错误，不会panic
+	err = Validate(struct {
+		Code string `validate:"required,unknown"`
+	}{Code: "lr-1"})
+	assert.ErrorIs(t, err, ErrUnknownValidationRule)
+	err = Validate(struct {
+		Tags []string `validate:"dive,unknown"`
+	}{})
+	assert.ErrorIs(t, err, ErrUnknownValidationRule)
+}
+
+func TestContext_RespBadRequest(t *testing.T) {
+	h := NewHTTPServer("tcp", ":8081")
+	h.POST("/user", func(ctx *Context) {
+		var req validateUserReq
+		if err := ctx.BindAndValidate(&req); err != nil {
+			require.NoError(t, ctx.RespBadRequest(err))
+			return
+		}
+		_ = ctx.RespJsonOK(req)
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/user", strings.NewReader(`{"level":"low"}`))
+	req.Header.Set("Content-Type", "application/json")
+	recorder := httptest.NewRecorder()
+	h.ServeHTTP(recorder, req)
+
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	assert.Equal(t, "application/json", recorder.Header().Get("Content-Type"))
+	var body badRequestResp
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &body))
+	assert.Equal(t, badRequestResp{
+		Message: "请求参数错误",
+		Errors: []*ValidationFieldError{
+			{Field: "name", Rule: "required", Message: "不能为空"},
+		},
+	}, body)
+}
@@ -0,0 +1,702 @@
+package lr
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// websocketGUID RFC 6455中计算Sec-WebSocket-Accept使用的固定值
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// defaultWSReadLimit 默认单条消息的最大字节数
+const defaultWSReadLimit = 1 << 20
+
+// WSMessageType WebSocket帧的操作码
+type WSMessageType int
+
+const (
+	wsContinuation WSMessageType = 0
+	WSText         WSMessageType = 1
+	WSBinary       WSMessageType = 2
+	WSClose        WSMessageType = 8
+	WSPing         WSMessageType = 9
+	WSPong         WSMessageType = 10
+)
+
+// 关闭状态码，见RFC 6455 7.4.1
+const (
+	WSCloseNormal             = 1000
+	WSCloseGoingAway          = 1001
+	WSCloseProtocolError      = 1002
+	WSCloseUnsupportedData    = 1003
+	WSCloseNoStatus           = 1005
+	WSCloseAbnormal           = 1006
+	WSCloseInvalidPayload     = 1007
+	WSClosePolicyViolation    = 1008
+	WSCloseMessageTooBig      = 1009
+	WSCloseMandatoryExtension = 1010
+	WSCloseInternalError      = 1011
+)
+
+var (
+	ErrWSHijackNotSupported = errors.New("lr: ResponseWriter不支持Hijack，无法升级为WebSocket")
+	ErrWSBadHandshake       = errors.New("lr: 不是合法的WebSocket握手请求")
+	ErrWSBadVersion         = errors.New("lr: 不支持的WebSocket版本")
+	ErrWSBadOrigin          = errors.New("lr: WebSocket请求的Origin不被允许")
+	ErrWSClosed             = errors.New("lr: WebSocket连接已关闭")
+	ErrWSInvalidMessageType = errors.New("lr: 不支持的WebSocket消息类型")
+)
+
+// WSCloseError 连接被关闭时返回的错误，Code是对端或者本端发送的关闭状态码
+type WSCloseError struct {
+	Code int
+	Text string
+}
+
+func (e *WSCloseError) Error() string {
+	return fmt.Sprintf("lr: WebSocket连接关闭 %d %s", e.Code, e.Text)
+}
+
+// WSOption 升级WebSocket的配置
+type WSOption func(cfg *wsConfig)
+
+type wsConfig struct {
+	// 单条消息的最大字节数，压缩消息按照解压后的大小计算
+	readLimit int64
+	// 是否允许协商permessage-deflate
+	compression bool
+	// 压缩级别
+	compressionLevel int
+	// 服务端支持的子协议，按照优先级排列
+	subprotocols []string
+	// 校验Origin，默认只允许同源请求
+	checkOrigin func(r *http.Request) bool
+}
+
+// WSReadLimit 设置单条消息的最大字节数，超过时以1009关闭连接
+func WSReadLimit(limit int64) WSOption {
+	return func(cfg *wsConfig) {
+		cfg.readLimit = limit
+	}
+}
+
+// WSCompression 客户端支持时启用permessage-deflate压缩
+func WSCompression(level int) WSOption {
+	return func(cfg *wsConfig) {
+		cfg.compression = true
+		cfg.compressionLevel = level
+	}
+}
+
+// WSSubprotocols 服务端支持的子协议，选取客户端也支持的第一个
+func WSSubprotocols(protocols ...string) WSOption {
+	return func(cfg *wsConfig) {
+		cfg.subprotocols = protocols
+	}
+}
+
+// WSCheckOrigin 自定义Origin校验，返回false时拒绝升级
+func WSCheckOrigin(fn func(r *http.Request) bool) WSOption {
+	return func(cfg *wsConfig) {
+		cfg.checkOrigin = fn
+	}
+}
+
+// WSConn WebSocket连接
+// 同一时间只允许一个goroutine读，写操作是并发安全的
+type WSConn struct {
+	conn net.Conn
+	br   *bufio.Reader
+
+	// 写锁，保护bw和closeSent
+	wmu       sync.Mutex
+	bw        *bufio.Writer
+	closeSent bool
+
+	readLimit        int64
+	compression      bool
+	compressionLevel int
+	subprotocol      string
+	pongHandler      func(data []byte)
+}
+
+// Upgrade 把当前请求升级为WebSocket连接
+// 握手失败时会设置Status和RespData并返回错误，成功之后框架不会再写入响应
+// 中间件在Resp上设置的响应头(例如session的Cookie)会随着101响应一起返回
+func (c *Context) Upgrade(opts ...WSOption) (*WSConn, error) {
+	cfg := &wsConfig{
+		readLimit:        defaultWSReadLimit,
+		compressionLevel: flate.BestSpeed,
+		checkOrigin:      wsSameOrigin,
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	if status, err := checkWSHandshake(c.Req, cfg); err != nil {
+		if status == http.StatusUpgradeRequired {
+			c.Resp.Header().Set("Sec-WebSocket-Version", "13")
+		}
+		c.Status = status
+		c.RespData = []byte(err.Error())
+		return nil, err
+	}
+
+	hj, ok := c.Resp.(http.Hijacker)
+	if !ok {
+		c.Status = http.StatusInternalServerError
+		return nil, ErrWSHijackNotSupported
+	}
+
+	subprotocol := selectWSSubprotocol(c.Req, cfg.subprotocols)
+	compression := cfg.compression && acceptWSDeflate(c.Req)
+
+	conn, brw, err := hj.Hijack()
+	if err != nil {
+		c.Status = http.StatusInternalServerError
+		return nil, err
+	}
+	// 连接已经被接管，之后不能再通过ResponseWriter写响应
+	c.committed = true
+	c.Status = http.StatusSwitchingProtocols
+
+	var sb strings.Builder
+	sb.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n")
+	sb.WriteString("Sec-WebSocket-Accept: " + wsAcceptKey(c.Req.Header.Get("Sec-WebSocket-Key")) + "\r\n")
+	if subprotocol != "" {
+		sb.WriteString("Sec-WebSocket-Protocol: " + subprotocol + "\r\n")
+	}
+	if compression {
+		sb.WriteString("Sec-WebSocket-Extensions: permessage-deflate; server_no_context_takeover; client_no_context_takeover\r\n")
+	}
+	for key, vals := range c.Resp.Header() {
+		if key == "Content-Length" || key == "Content-Type" {
+			continue
+		}
+		for _, val := range vals {
+			sb.WriteString(key + ": " + val + "\r\n")
+		}
+	}
+	sb.WriteString("\r\n")
+
+	// 握手阶段不能无限阻塞
+	_ = conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	if _, err = brw.WriteString(sb.String()); err == nil {
+		err = brw.Flush()
+	}
+	_ = conn.SetWriteDeadline(time.Time{})
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
+	return &WSConn{
+		conn:             conn,
+		br:               brw.Reader,
+		bw:               brw.Writer,
+		readLimit:        cfg.readLimit,
+		compression:      compression,
+		compressionLevel: cfg.compressionLevel,
+		subprotocol:      subprotocol,
+	}, nil
+}
+
+// Subprotocol 协商出来的子协议，没有协商时为空
+func (w *WSConn) Subprotocol() string {
+	return w.subprotocol
+}
+
+// RemoteAddr 对端地址
+func (w *WSConn) RemoteAddr() net.Addr {
+	return w.conn.RemoteAddr()
+}
+
+// SetReadDeadline 设置读超时
+func (w *WSConn) SetReadDeadline(t time.Time) error {
+	return w.conn.SetReadDeadline(t)
+}
+
+// SetWriteDeadline 设置写超时
+func (w *WSConn) SetWriteDeadline(t time.Time) error {
+	return w.conn.SetWriteDeadline(t)
+}
+
+// SetPongHandler 收到pong帧时的回调，在ReadMessage所在的goroutine中执行
+func (w *WSConn) SetPongHandler(fn func(data []byte)) {
+	w.pongHandler = fn
+}
+
+// ReadMessage 读取一条完整的消息
+// ping会被自动回复pong，收到close帧时回复close并关闭连接，返回*WSCloseError
+func (w *WSConn) ReadMessage() (WSMessageType, []byte, error) {
+	var (
+		msgType    WSMessageType
+		compressed bool
+		buf        []byte
+	)
+
+	for {
+		frame, err := w.readFrame(int64(len(buf)))
+		if err != nil {
+			return 0, nil, w.fail(err)
+		}
+
+		switch frame.opcode {
+		case WSPing:
+			if err = w.writeFrame(WSPong, frame.payload, false); err != nil {
+				return 0, nil, err
+			}
+			continue
+		case WSPong:
+			if w.pongHandler != nil {
+				w.pongHandler(frame.payload)
+			}
+			continue
+		case WSClose:
+			return 0, nil, w.handleClose(frame.payload)
+		case wsContinuation:
+			if msgType == 0 {
+				return 0, nil, w.fail(wsProtocolError(WSCloseProtocolError, "没有开始的分片消息"))
+			}
+			buf = append(buf, frame.payload...)
+		default:
+			if msgType != 0 {
+				return 0, nil, w.fail(wsProtocolError(WSCloseProtocolError, "上一条分片消息尚未结束"))
+			}
+			msgType = frame.opcode
+			compressed = frame.rsv1
+			buf = frame.payload
+		}
+
+		if frame.fin {
+			break
+		}
+	}
+
+	if compressed {
+		data, err := wsDecompress(buf, w.readLimit)
+		if err != nil {
+			return 0, nil, w.fail(err)
+		}
+		buf = data
+	}
+
+	if msgType == WSText && !utf8.Valid(buf) {
+		return 0, nil, w.fail(wsProtocolError(WSCloseInvalidPayload, "文本消息不是合法的UTF-8"))
+	}
+
+	return msgType, buf, nil
+}
+
+// ReadJSON 读取一条消息并按照json解码
+func (w *WSConn) ReadJSON(val any) error {
+	_, data, err := w.ReadMessage()
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, val)
+}
+
+// WriteMessage 发送一条文本或者二进制消息
+func (w *WSConn) WriteMessage(msgType WSMessageType, data []byte) error {
+	if msgType != WSText && msgType != WSBinary {
+		return ErrWSInvalidMessageType
+	}
+
+	if !w.compression {
+		return w.writeFrame(msgType, data, false)
+	}
+
+	compressed, err := wsCompress(data, w.compressionLevel)
+	if err != nil {
+		return err
+	}
+	return w.writeFrame(msgType, compressed, true)
+}
+
+// WriteJSON 把val编码为json之后以文本消息发送
+func (w *WSConn) WriteJSON(val any) error {
+	data, err := json.Marshal(val)
+	if err != nil {
+		return err
+	}
+	return w.WriteMessage(WSText, data)
+}
+
+// Ping 发送ping帧，data不能超过125字节
+func (w *WSConn) Ping(data []byte) error {
+	if len(data) > 125 {
+		return errors.New("lr: 控制帧的数据不能超过125字节")
+	}
+	return w.writeFrame(WSPing, data, false)
+}
+
+// Close 发送关闭帧并关闭底层连接
+func (w *WSConn) Close(code int, reason string) error {
+	err := w.writeFrame(WSClose, wsClosePayload(code, reason), false)
+	if closeErr := w.conn.Close(); err == nil || errors.Is(err, ErrWSClosed) {
+		err = closeErr
+	}
+	return err
+}
+
+// handleClose 处理对端发来的关闭帧，回复相同的状态码之后关闭连接
+func (w *WSConn) handleClose(payload []byte) error {
+	code, text := WSCloseNoStatus, ""
+	switch {
+	case len(payload) == 1:
+		return w.fail(wsProtocolError(WSCloseProtocolError, "关闭帧格式错误"))
+	case len(payload) >= 2:
+		code = int(binary.BigEndian.Uint16(payload))
+		text = string(payload[2:])
+		if !validWSCloseCode(code) {
+			return w.fail(wsProtocolError(WSCloseProtocolError, "不合法的关闭状态码"))
+		}
+		if !utf8.ValidString(text) {
+			return w.fail(wsProtocolError(WSCloseInvalidPayload, "关闭原因不是合法的UTF-8"))
+		}
+	}
+
+	var reply []byte
+	if code != WSCloseNoStatus {
+		reply = wsClosePayload(code, "")
+	}
+	_ = w.writeFrame(WSClose, reply, false)
+	_ = w.conn.Close()
+	return &WSCloseError{Code: code, Text: text}
+}
+
+// fail 协议错误时发送对应的关闭帧并断开连接
+func (w *WSConn) fail(err error) error {
+	var closeErr *WSCloseError
+	if errors.As(err, &closeErr) {
+		_ = w.writeFrame(WSClose, wsClosePayload(closeErr.Code, closeErr.Text), false)
+		_ = w.conn.Close()
+		return closeErr
+	}
+
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		_ = w.conn.Close()
+		return &WSCloseError{Code: WSCloseAbnormal, Text: err.Error()}
+	}
+	return err
+}
+
+type wsFrame struct {
+	fin     bool
+	rsv1    bool
+	opcode  WSMessageType
+	payload []byte
+}
+
+// readFrame 读取一个帧，buffered是当前分片消息已经读取的字节数
+func (w *WSConn) readFrame(buffered int64) (wsFrame, error) {
+	var header [2]byte
+	if _, err := io.ReadFull(w.br, header[:]); err != nil {
+		return wsFrame{}, err
+	}
+
+	frame := wsFrame{
+		fin:    header[0]&0x80 != 0,
+		rsv1:   header[0]&0x40 != 0,
+		opcode: WSMessageType(header[0] & 0x0f),
+	}
+	if header[0]&0x30 != 0 {
+		return frame, wsProtocolError(WSCloseProtocolError, "RSV2和RSV3必须为0")
+	}
+
+	isControl := frame.opcode >= WSClose
+	switch frame.opcode {
+	case wsContinuation, WSText, WSBinary, WSClose, WSPing, WSPong:
+	default:
+		return frame, wsProtocolError(WSCloseProtocolError, "未知的操作码")
+	}
+	if frame.rsv1 && (!w.compression || isControl || frame.opcode == wsContinuation) {
+		return frame, wsProtocolError(WSCloseProtocolError, "RSV1只能出现在压缩消息的第一帧")
+	}
+	// 客户端发来的帧必须掩码
+	if header[1]&0x80 == 0 {
+		return frame, wsProtocolError(WSCloseProtocolError, "客户端的帧必须掩码")
+	}
+
+	length := int64(header[1] & 0x7f)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(w.br, ext[:]); err != nil {
+			return frame, err
+		}
+		length = int64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(w.br, ext[:]); err != nil {
+			return frame, err
+		}
+		if ext[0]&0x80 != 0 {
+			return frame, wsProtocolError(WSCloseProtocolError, "帧长度不合法")
+		}
+		length = int64(binary.BigEndian.Uint64(ext[:]))
+	}
+
+	if isControl && (length > 125 || !frame.fin) {
+		return frame, wsProtocolError(WSCloseProtocolError, "控制帧不能分片且不能超过125字节")
+	}
+	// 用减法比较，length接近int64上限时相加会溢出
+	if !isControl && w.readLimit > 0 && length > w.readLimit-buffered {
+		return frame, wsProtocolError(WSCloseMessageTooBig, "消息超过了大小限制")
+	}
+
+	var mask [4]byte
+	if _, err := io.ReadFull(w.br, mask[:]); err != nil {
+		return frame, err
+	}
+
+	payload, err := readWSPayload(w.br, length)
+	if err != nil {
+		return frame, err
+	}
+	frame.payload = payload
+	for i := range frame.payload {
+		frame.payload[i] ^= mask[i%4]
+	}
+
+	return frame, nil
+}
+
+// wsPreallocLimit 帧长度不超过这个值时一次分配好缓冲区
+const wsPreallocLimit = 64 << 10
+
+// readWSPayload 长度来自对端，不能直接按照长度分配内存，超过wsPreallocLimit时随着收到的数据增长，
+// 没有WSReadLimit时伪造的长度也不会一次分配大量内存
+func readWSPayload(r io.Reader, length int64) ([]byte, error) {
+	if length <= wsPreallocLimit {
+		payload := make([]byte, length)
+		_, err := io.ReadFull(r, payload)
+		return payload, err
+	}
+	var buf bytes.Buffer
+	buf.Grow(wsPreallocLimit)
+	n, err := io.CopyN(&buf, r, length)
+	if err == io.EOF && n > 0 {
+		err = io.ErrUnexpectedEOF
+	}
+	return buf.Bytes(), err
+}
+
+// writeFrame 写入一个完整的帧，服务端发送的帧不需要掩码
+func (w *WSConn) writeFrame(opcode WSMessageType, payload []byte, rsv1 bool) error {
+	w.wmu.Lock()
+	defer w.wmu.Unlock()
+	if w.closeSent {
+		return ErrWSClosed
+	}
+
+	b0 := byte(0x80) | byte(opcode)
+	if rsv1 {
+		b0 |= 0x40
+	}
+	header := []byte{b0, 0}
+	switch length := len(payload); {
+	case length <= 125:
+		header[1] = byte(length)
+	case length <= 0xffff:
+		header[1] = 126
+		header = binary.BigEndian.AppendUint16(header, uint16(length))
+	default:
+		header[1] = 127
+		header = binary.BigEndian.AppendUint64(header, uint64(length))
+	}
+
+	if _, err := w.bw.Write(header); err != nil {
+		return err
+	}
+	if _, err := w.bw.Write(payload); err != nil {
+		return err
+	}
+	if err := w.bw.Flush(); err != nil {
+		return err
+	}
+
+	if opcode == WSClose {
+		w.closeSent = true
+	}
+	return nil
+}
+
+func wsProtocolError(code int, text string) error {
+	return &WSCloseError{Code: code, Text: text}
+}
+
+func wsClosePayload(code int, reason string) []byte {
+	if code == WSCloseNoStatus {
+		return nil
+	}
+	// 控制帧最多125字节，去掉状态码的2字节
+	if len(reason) > 123 {
+		reason = reason[:123]
+		// 截断时不能留下半个字符
+		for !utf8.ValidString(reason) {
+			reason = reason[:len(reason)-1]
+		}
+	}
+	payload := binary.BigEndian.AppendUint16(nil, uint16(code))
+	return append(payload, reason...)
+}
+
+func validWSCloseCode(code int) bool {
+	switch {
+	case code >= 1000 && code <= 1003, code >= 1007 && code <= 1011:
+		return true
+	case code >= 3000 && code <= 4999:
+		return true
+	}
+	return false
+}
+
+// checkWSHandshake 校验握手请求，返回失败时应该响应的状态码
+func checkWSHandshake(r *http.Request, cfg *wsConfig) (int, error) {
+	if r.Method != http.MethodGet {
+		return http.StatusMethodNotAllowed, ErrWSBadHandshake
+	}
+	if !headerContainsToken(r.Header, "Connection", "upgrade") ||
+		!headerContainsToken(r.Header, "Upgrade", "websocket") {
+		return http.StatusBadRequest, ErrWSBadHandshake
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		return http.StatusUpgradeRequired, ErrWSBadVersion
+	}
+	key, err := base64.StdEncoding.DecodeString(r.Header.Get("Sec-WebSocket-Key"))
+	if err != nil || len(key) != 16 {
+		return http.StatusBadRequest, ErrWSBadHandshake
+	}
+	if cfg.checkOrigin != nil && !cfg.checkOrigin(r) {
+		return http.StatusForbidden, ErrWSBadOrigin
+	}
+	return 0, nil
+}
+
+func wsAcceptKey(key string) string {
+	h := sha1.New()
+	h.Write([]byte(key + websocketGUID))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// wsSameOrigin 默认的Origin校验，没有Origin的请求(非浏览器)直接放行
+func wsSameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(u.Host, r.Host)
+}
+
+func selectWSSubprotocol(r *http.Request, supported []string) string {
+	offered := headerTokens(r.Header, "Sec-WebSocket-Protocol")
+	for _, s := range supported {
+		for _, o := range offered {
+			if s == o {
+				return s
+			}
+		}
+	}
+	return ""
+}
+
+// acceptWSDeflate 客户端是否提供了可以接受的permessage-deflate参数
+// 标准库的flate固定使用32K的窗口，所以不能接受server_max_window_bits小于15的请求
+func acceptWSDeflate(r *http.Request) bool {
+	for _, ext := range headerTokens(r.Header, "Sec-WebSocket-Extensions") {
+		params := strings.Split(ext, ";")
+		if strings.TrimSpace(params[0]) != "permessage-deflate" {
+			continue
+		}
+		ok := true
+		for _, p := range params[1:] {
+			p = strings.TrimSpace(p)
+			if strings.HasPrefix(p, "server_max_window_bits=") && strings.Trim(p[len("server_max_window_bits="):], `"`) != "15" {
+				ok = false
+			}
+		}
+		if ok {
+			return true
+		}
+	}
+	return false
+}
+
+func wsCompress(data []byte, level int) ([]byte, error) {
+	var buf bytes.Buffer
+	fw, err := flate.NewWriter(&buf, level)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = fw.Write(data); err != nil {
+		return nil, err
+	}
+	if err = fw.Flush(); err != nil {
+		return nil, err
+	}
+	// 去掉Flush产生的空块结尾，见RFC 7692 7.2.1
+	return bytes.TrimSuffix(buf.Bytes(), []byte{0x00, 0x00, 0xff, 0xff}), nil
+}
+
+func wsDecompress(data []byte, limit int64) ([]byte, error) {
+	// 补上被对端去掉的结尾，再追加一个最终空块避免读到unexpected EOF
+	tail := []byte{0x00, 0x00, 0xff, 0xff, 0x01, 0x00, 0x00, 0xff, 0xff}
+	fr := flate.NewReader(io.MultiReader(bytes.NewReader(data), bytes.NewReader(tail)))
+	defer fr.Close()
+
+	reader := io.Reader(fr)
+	if limit > 0 {
+		reader = io.LimitReader(fr, limit+1)
+	}
+	out, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, wsProtocolError(WSCloseInvalidPayload, "压缩数据不合法")
+	}
+	if limit > 0 && int64(len(out)) > limit {
+		return nil, wsProtocolError(WSCloseMessageTooBig, "消息超过了大小限制")
+	}
+	return out, nil
+}
+
+// headerTokens 按照逗号拆分请求头中的多个值
+func headerTokens(header http.Header, key string) []string {
+	var tokens []string
+	for _, val := range header.Values(key) {
+		for _, token := range strings.Split(val, ",") {
+			if token = strings.TrimSpace(token); token != "" {
+				tokens = append(tokens, token)
+			}
+		}
+	}
+	return tokens
+}
+
+func headerContainsToken(header http.Header, key, token string) bool {
+	for _, t := range headerTokens(header, key) {
+		if strings.EqualFold(t, token) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,259 @@
+package lr
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// wsTestClient 测试用的最小WebSocket客户端
+type wsTestClient struct {
+	conn net.Conn
+	br   *bufio.Reader
+	resp *http.Response
+}
+
+func dialWSTest(t *testing.T, serverURL, path string, header http.Header) *wsTestClient {
+	conn, err := net.Dial("tcp", strings.TrimPrefix(serverURL, "http://"))
+	require.NoError(t, err)
+	_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	req, err := http.NewRequest(http.MethodGet, serverURL+path, nil)
+	require.NoError(t, err)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	for key, vals := range header {
+		req.Header[key] = vals
+	}
+	require.NoError(t, req.Write(conn))
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	require.NoError(t, err)
+	return &wsTestClient{conn: conn, br: br, resp: resp}
+}
+
+func (c *wsTestClient) writeFrame(t *testing.T, fin bool, rsv1 bool, opcode WSMessageType, payload []byte) {
+	b0 := byte(opcode)
+	if fin {
+		b0 |= 0x80
+	}
+	if rsv1 {
+		b0 |= 0x40
+	}
+	frame := []byte{b0}
+	switch {
+	case len(payload) <= 125:
+		frame = append(frame, 0x80|byte(len(payload)))
+	case len(payload) <= 0xffff:
+		frame = append(frame, 0x80|126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(len(payload)))
+	default:
+		frame = append(frame, 0x80|127)
+		frame = binary.BigEndian.AppendUint64(frame, uint64(len(payload)))
+	}
+	mask := []byte{1, 2, 3, 4}
+	frame = append(frame, mask...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+	_, err := c.conn.Write(frame)
+	require.NoError(t, err)
+}
+
+func (c *wsTestClient) readFrame(t *testing.T) (WSMessageType, bool, []byte) {
+	var header [2]byte
+	_, err := io.ReadFull(c.br, header[:])
+	require.NoError(t, err)
+	length := int(header[1] & 0x7f)
+	if length == 126 {
+		var ext [2]byte
+		_, err = io.ReadFull(c.br, ext[:])
+		require.NoError(t, err)
+		length = int(binary.BigEndian.Uint16(ext[:]))
+	}
+	payload := make([]byte, length)
+	_, err = io.ReadFull(c.br, payload)
+	require.NoError(t, err)
+	return WSMessageType(header[0] & 0x0f), header[0]&0x40 != 0, payload
+}
+
+func TestContext_Upgrade(t *testing.T) {
+	h := NewHTTPServer("tcp", ":8081")
+	closed := make(chan *WSCloseError, 1)
+	h.GET("/ws/:room", func(ctx *Context) {
+		ctx.Resp.Header().Set("Set-Cookie", "sess=1")
+		conn, err := ctx.Upgrade(WSSubprotocols("chat.v2", "chat.v1"))
+		require.NoError(t, err)
+		for {
+			mt, data, err := conn.ReadMessage()
+			if err != nil {
+				closed <- err.(*WSCloseError)
+				return
+			}
+			room, _ := ctx.PathValue("room").String()
+			require.NoError(t, conn.WriteMessage(mt, append([]byte(room+":"), data...)))
+		}
+	})
+	server := httptest.NewServer(h)
+	defer server.Close()
+
+	client := dialWSTest(t, server.URL, "/ws/lobby", http.Header{
+		"Sec-Websocket-Protocol": {"chat.v1, chat.v2"},
+	})
+	assert.Equal(t, http.StatusSwitchingProtocols, client.resp.StatusCode)
+	assert.Equal(t, "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=", client.resp.Header.Get("Sec-WebSocket-Accept"))
+	assert.Equal(t, "chat.v2", client.resp.Header.Get("Sec-WebSocket-Protocol"))
+	assert.Equal(t, "sess=1", client.resp.Header.Get("Set-Cookie"))
+
+	// 普通消息
+	client.writeFrame(t, true, false, WSText, []byte("hello"))
+	mt, _, data := client.readFrame(t)
+	assert.Equal(t, WSText, mt)
+	assert.Equal(t, "lobby:hello", string(data))
+
+	// 分片消息中间夹着ping
+	client.writeFrame(t, false, false, WSBinary, []byte("ab"))
+	client.writeFrame(t, true, false, WSPing, []byte("p"))
+	mt, _, data = client.readFrame(t)
+	assert.Equal(t, WSPong, mt)
+	assert.Equal(t, "p", string(data))
+	client.writeFrame(t, true, false, wsContinuation, []byte("cd"))
+	mt, _, data = client.readFrame(t)
+	assert.Equal(t, WSBinary, mt)
+	assert.Equal(t, "lobby:abcd", string(data))
+
+	// 关闭
+	client.writeFrame(t, true, false, WSClose, wsClosePayload(WSCloseGoingAway, "bye"))
+	mt, _, data = client.readFrame(t)
+	assert.Equal(t, WSClose, mt)
+	assert.Equal(t, uint16(WSCloseGoingAway), binary.BigEndian.Uint16(data))
+	closeErr := <-closed
+	assert.Equal(t, WSCloseGoingAway, closeErr.Code)
+	assert.Equal(t, "bye", closeErr.Text)
+}
+
+func TestContext_UpgradeLimitAndCompression(t *testing.T) {
+	h := NewHTTPServer("tcp", ":8081")
+	closed := make(chan *WSCloseError, 1)
+	h.GET("/ws", func(ctx *Context) {
+		conn, err := ctx.Upgrade(WSReadLimit(16), WSCompression(1))
+		require.NoError(t, err)
+		for {
+			mt, data, err := conn.ReadMessage()
+			if err != nil {
+				closed <- err.(*WSCloseError)
+				return
+			}
+			require.NoError(t, conn.WriteMessage(mt, data))
+		}
+	})
+	server := httptest.NewServer(h)
+	defer server.Close()
+
+	client := dialWSTest(t, server.URL, "/ws", http.Header{
+		"Sec-Websocket-Extensions": {"permessage-deflate; client_max_window_bits"},
+	})
+	require.Equal(t, http.StatusSwitchingProtocols, client.resp.StatusCode)
+	assert.Contains(t, client.resp.Header.Get("Sec-WebSocket-Extensions"), "permessage-deflate")
+
+	compressed, err := wsCompress([]byte("aaaaaaaaaa"), 1)
+	require.NoError(t, err)
+	client.writeFrame(t, true, true, WSText, compressed)
+	mt, rsv1, data := client.readFrame(t)
+	assert.Equal(t, WSText, mt)
+	assert.True(t, rsv1)
+	plain, err := wsDecompress(data, 0)
+	require.NoError(t, err)
+	assert.Equal(t, "aaaaaaaaaa", string(plain))
+
+	// 解压后超过限制
+	compressed, err = wsCompress([]byte(strings.Repeat("a", 64)), 1)
+	require.NoError(t, err)
+	client.writeFrame(t, true, true, WSText, compressed)
+	mt, _, data = client.readFrame(t)
+	assert.Equal(t, WSClose, mt)
+	assert.Equal(t, uint16(WSCloseMessageTooBig), binary.BigEndian.Uint16(data))
+	assert.Equal(t, WSCloseMessageTooBig, (<-closed).Code)
+}
+
+func TestContext_UpgradeHugeFrame(t *testing.T) {
+	h := NewHTTPServer("tcp", ":8081")
+	readErr := make(chan error, 1)
+	h.GET("/ws", func(ctx *Context) {
+		conn, err := ctx.Upgrade(WSReadLimit(0))
+		require.NoError(t, err)
+		_, _, err = conn.ReadMessage()
+		readErr <- err
+	})
+	server := httptest.NewServer(h)
+	defer server.Close()
+
+	client := dialWSTest(t, server.URL, "/ws", nil)
+	require.Equal(t, http.StatusSwitchingProtocols, client.resp.StatusCode)
+	// 声明的长度接近int64上限，实际只发送几个字节，不能按照声明的长度分配内存
+	frame := []byte{0x80 | byte(WSBinary), 0x80 | 127}
+	frame = binary.BigEndian.AppendUint64(frame, 1<<62)
+	frame = append(frame, 1, 2, 3, 4, 'a', 'b')
+	_, err := client.conn.Write(frame)
+	require.NoError(t, err)
+	require.NoError(t, client.conn.Close())
+
+	select {
+	case err = <-readErr:
+		assert.Error(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("读取没有结束")
+	}
+}
+
+func TestContext_UpgradeBadHandshake(t *testing.T) {
+	testCases := []struct {
+		name       string
+		header     http.Header
+		wantStatus int
+	}{
+		{
+			name:       "版本错误",
+			header:     http.Header{"Sec-Websocket-Version": {"8"}},
+			wantStatus: http.StatusUpgradeRequired,
+		},
+		{
+			name:       "key错误",
+			header:     http.Header{"Sec-Websocket-Key": {"abc"}},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "跨域",
+			header:     http.Header{"Origin": {"http://evil.example.com"}},
+			wantStatus: http.StatusForbidden,
+		},
+	}
+
+	h := NewHTTPServer("tcp", ":8081")
+	h.GET("/ws", func(ctx *Context) {
+		_, err := ctx.Upgrade()
+		assert.Error(t, err)
+	})
+	server := httptest.NewServer(h)
+	defer server.Close()
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			client := dialWSTest(t, server.URL, "/ws", tc.header)
+			defer client.conn.Close()
+			assert.Equal(t, tc.wantStatus, client.resp.StatusCode)
+		})
+	}
+}